@@ -8,23 +8,32 @@ import (
 	"github.com/708u/cctidy/internal/set"
 )
 
-// LoadAgentNames scans the agents directory and returns
-// a set of agent names extracted from frontmatter.
-// The frontmatter name field is the sole agent identifier;
-// filenames are not used. Files without a valid name field
-// are skipped.
+// LoadAgentNames scans the agents directory and its
+// subdirectories and returns a set of agent names extracted
+// from frontmatter. The frontmatter name field is the sole
+// agent identifier; filenames and subdirectory names are not
+// used, so nested agents are not namespaced. Files without a
+// valid name field are skipped.
 // Returns an empty set if the directory does not exist.
 func LoadAgentNames(dir string) set.Value[string] {
 	s := set.New[string]()
 	if dir == "" {
 		return s
 	}
+	loadAgentsDir(dir, s)
+	return s
+}
+
+// loadAgentsDir adds the frontmatter names of .md files in dir
+// and its subdirectories to s.
+func loadAgentsDir(dir string, s set.Value[string]) {
 	entries, err := os.ReadDir(dir)
 	if err != nil {
-		return s
+		return
 	}
 	for _, e := range entries {
 		if e.IsDir() {
+			loadAgentsDir(filepath.Join(dir, e.Name()), s)
 			continue
 		}
 		if filepath.Ext(e.Name()) != ".md" {
@@ -38,5 +47,4 @@ func LoadAgentNames(dir string) set.Value[string] {
 			s.Add(name)
 		}
 	}
-	return s
 }
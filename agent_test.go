@@ -93,4 +93,17 @@ func TestLoadAgentNames(t *testing.T) {
 			t.Error("directory should not be in set")
 		}
 	})
+
+	t.Run("nested agents use frontmatter name", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		nested := filepath.Join(dir, "agents", "review", "deep")
+		os.MkdirAll(nested, 0o755)
+		os.WriteFile(filepath.Join(nested, "security.md"), []byte("---\nname: security-reviewer\n---\n"), 0o644)
+
+		names := LoadAgentNames(filepath.Join(dir, "agents"))
+		if !names.Has("security-reviewer") {
+			t.Errorf("expected nested agent in set, got %v", names)
+		}
+	})
 }
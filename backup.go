@@ -0,0 +1,251 @@
+package cctidy
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// DefaultBackupKeep is the number of backups kept per file when
+// the config does not set backup.keep.
+const DefaultBackupKeep = 10
+
+// backupTimeLayout names backup files so that they sort
+// chronologically and do not collide within a run.
+const backupTimeLayout = "20060102T150405.000000000Z"
+
+// backupSourceFile holds the original path inside each per-file
+// backup directory.
+const backupSourceFile = "source"
+
+// RetentionPolicy decides which backups of a file are kept.
+// A zero policy keeps everything. When both fields are set, a
+// backup is removed if either limit is exceeded.
+type RetentionPolicy struct {
+	// Keep is the maximum number of backups kept per file.
+	Keep int
+	// MaxAge is the maximum age of a backup.
+	MaxAge time.Duration
+}
+
+// Backup is a stored copy of a file taken before it was written.
+type Backup struct {
+	// File is the absolute path of the backed up file.
+	File string `json:"file"`
+	// Path is the location of the backup itself.
+	Path string `json:"path"`
+	// Time is when the backup was taken.
+	Time time.Time `json:"time"`
+	// Compressed reports whether the backup is gzip-compressed.
+	Compressed bool `json:"compressed"`
+}
+
+// BackupStore keeps backups of target files in a central directory,
+// one subdirectory per file, and prunes them by a RetentionPolicy.
+type BackupStore struct {
+	dir       string
+	compress  bool
+	retention RetentionPolicy
+}
+
+// DefaultBackupDir returns backups in StateDir.
+func DefaultBackupDir(homeDir string) string {
+	return filepath.Join(StateDir(homeDir), "backups")
+}
+
+// NewBackupStore returns a store rooted at dir.
+// The directory is created on the first save.
+func NewBackupStore(dir string, compress bool, retention RetentionPolicy) *BackupStore {
+	return &BackupStore{dir: dir, compress: compress, retention: retention}
+}
+
+// fileDir returns the backup directory for the absolute path file.
+// The name is derived from a hash so that arbitrarily long paths
+// map to a valid directory name.
+func (s *BackupStore) fileDir(file string) string {
+	sum := sha256.Sum256([]byte(file))
+	return filepath.Join(s.dir, hex.EncodeToString(sum[:8]))
+}
+
+// Save stores data as a backup of file taken at now, prunes older
+// backups of the same file and returns the new backup. The backup
+// is returned even when pruning fails.
+func (s *BackupStore) Save(file string, data []byte, now time.Time) (*Backup, error) {
+	file, err := filepath.Abs(file)
+	if err != nil {
+		return nil, err
+	}
+	dir := s.fileDir(file)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("creating backup directory: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, backupSourceFile), []byte(file+"\n"), 0o600); err != nil {
+		return nil, fmt.Errorf("writing backup source: %w", err)
+	}
+
+	name := now.UTC().Format(backupTimeLayout) + ".json"
+	if s.compress {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		zw.Name = filepath.Base(file)
+		zw.ModTime = now
+		if _, err := zw.Write(data); err != nil {
+			return nil, fmt.Errorf("compressing backup: %w", err)
+		}
+		if err := zw.Close(); err != nil {
+			return nil, fmt.Errorf("compressing backup: %w", err)
+		}
+		data = buf.Bytes()
+		name += ".gz"
+	}
+	b := &Backup{File: file, Path: filepath.Join(dir, name), Time: now.UTC(), Compressed: s.compress}
+	if err := os.WriteFile(b.Path, data, 0o600); err != nil {
+		return nil, fmt.Errorf("creating backup: %w", err)
+	}
+	if err := s.Prune(file, now); err != nil {
+		return b, err
+	}
+	return b, nil
+}
+
+// List returns the backups of file, oldest first.
+// When file is empty, backups of all files are returned,
+// grouped by file.
+func (s *BackupStore) List(file string) ([]Backup, error) {
+	if file != "" {
+		abs, err := filepath.Abs(file)
+		if err != nil {
+			return nil, err
+		}
+		return s.listDir(s.fileDir(abs))
+	}
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("reading backup directory: %w", err)
+	}
+	var all []Backup
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		backups, err := s.listDir(filepath.Join(s.dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, backups...)
+	}
+	slices.SortStableFunc(all, func(a, b Backup) int {
+		return strings.Compare(a.File, b.File)
+	})
+	return all, nil
+}
+
+func (s *BackupStore) listDir(dir string) ([]Backup, error) {
+	source, err := os.ReadFile(filepath.Join(dir, backupSourceFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("reading backup source: %w", err)
+	}
+	file := strings.TrimSpace(string(source))
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading backup directory: %w", err)
+	}
+	var backups []Backup
+	for _, e := range entries {
+		name := e.Name()
+		stamp, compressed := strings.CutSuffix(name, ".json.gz")
+		if !compressed {
+			var ok bool
+			if stamp, ok = strings.CutSuffix(name, ".json"); !ok {
+				continue
+			}
+		}
+		t, err := time.Parse(backupTimeLayout, stamp)
+		if err != nil {
+			continue
+		}
+		backups = append(backups, Backup{
+			File:       file,
+			Path:       filepath.Join(dir, name),
+			Time:       t,
+			Compressed: compressed,
+		})
+	}
+	slices.SortFunc(backups, func(a, b Backup) int { return a.Time.Compare(b.Time) })
+	return backups, nil
+}
+
+// Read returns the original content of b.
+func (s *BackupStore) Read(b Backup) ([]byte, error) {
+	data, err := os.ReadFile(b.Path)
+	if err != nil {
+		return nil, fmt.Errorf("reading backup: %w", err)
+	}
+	if !b.Compressed {
+		return data, nil
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("decompressing backup %s: %w", b.Path, err)
+	}
+	defer zr.Close()
+	out, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf("decompressing backup %s: %w", b.Path, err)
+	}
+	return out, nil
+}
+
+// Prune removes backups of file that fall outside the retention
+// policy. The newest backup is always kept.
+func (s *BackupStore) Prune(file string, now time.Time) error {
+	backups, err := s.List(file)
+	if err != nil {
+		return err
+	}
+	for i, b := range backups {
+		newer := len(backups) - 1 - i
+		if newer == 0 {
+			break
+		}
+		expired := s.retention.MaxAge > 0 && now.Sub(b.Time) > s.retention.MaxAge
+		excess := s.retention.Keep > 0 && newer >= s.retention.Keep
+		if !expired && !excess {
+			continue
+		}
+		if err := os.Remove(b.Path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("removing old backup: %w", err)
+		}
+	}
+	return nil
+}
+
+// Latest returns the newest backup of file taken at or before at.
+// A zero at selects the newest backup. It returns nil when there
+// is no such backup.
+func (s *BackupStore) Latest(file string, at time.Time) (*Backup, error) {
+	backups, err := s.List(file)
+	if err != nil {
+		return nil, err
+	}
+	for i := len(backups) - 1; i >= 0; i-- {
+		if at.IsZero() || !backups[i].Time.After(at) {
+			return &backups[i], nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,186 @@
+package cctidy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBackupStore(t *testing.T) {
+	t.Parallel()
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	t.Run("save and read round trip", func(t *testing.T) {
+		t.Parallel()
+		for _, compress := range []bool{false, true} {
+			dir := t.TempDir()
+			s := NewBackupStore(filepath.Join(dir, "backups"), compress, RetentionPolicy{})
+			file := filepath.Join(dir, "settings.json")
+
+			b, err := s.Save(file, []byte(`{"a":1}`), start)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if b.File != file || b.Compressed != compress || !b.Time.Equal(start) {
+				t.Errorf("unexpected backup: %+v", b)
+			}
+			list, err := s.List(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(list) != 1 || list[0] != *b {
+				t.Fatalf("List = %+v, want [%+v]", list, *b)
+			}
+			data, err := s.Read(list[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != `{"a":1}` {
+				t.Errorf("Read = %q (compress=%v)", data, compress)
+			}
+		}
+	})
+
+	t.Run("list without file returns all files", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		s := NewBackupStore(filepath.Join(dir, "backups"), false, RetentionPolicy{})
+		s.Save(filepath.Join(dir, "b.json"), []byte("{}"), start)
+		s.Save(filepath.Join(dir, "a.json"), []byte("{}"), start)
+
+		list, err := s.List("")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(list) != 2 || list[0].File != filepath.Join(dir, "a.json") {
+			t.Errorf("List = %+v", list)
+		}
+	})
+
+	t.Run("missing directory lists nothing", func(t *testing.T) {
+		t.Parallel()
+		s := NewBackupStore(filepath.Join(t.TempDir(), "none"), false, RetentionPolicy{})
+		list, err := s.List("")
+		if err != nil || len(list) != 0 {
+			t.Errorf("List = %+v, %v", list, err)
+		}
+	})
+}
+
+func TestBackupRetention(t *testing.T) {
+	t.Parallel()
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		retention RetentionPolicy
+		// saves lists the offset of each backup from start.
+		saves []time.Duration
+		// want lists the offsets of the backups left afterwards.
+		want []time.Duration
+	}{
+		{
+			name:  "zero policy keeps all",
+			saves: []time.Duration{0, time.Hour, 2 * time.Hour},
+			want:  []time.Duration{0, time.Hour, 2 * time.Hour},
+		},
+		{
+			name:      "keep newest N",
+			retention: RetentionPolicy{Keep: 2},
+			saves:     []time.Duration{0, time.Hour, 2 * time.Hour},
+			want:      []time.Duration{time.Hour, 2 * time.Hour},
+		},
+		{
+			name:      "max age removes old backups",
+			retention: RetentionPolicy{MaxAge: 24 * time.Hour},
+			saves:     []time.Duration{0, 12 * time.Hour, 30 * time.Hour},
+			want:      []time.Duration{12 * time.Hour, 30 * time.Hour},
+		},
+		{
+			name:      "newest is kept even when expired",
+			retention: RetentionPolicy{MaxAge: time.Hour},
+			saves:     []time.Duration{0},
+			want:      []time.Duration{0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			dir := t.TempDir()
+			s := NewBackupStore(filepath.Join(dir, "backups"), false, tt.retention)
+			file := filepath.Join(dir, "settings.json")
+			for _, off := range tt.saves {
+				if _, err := s.Save(file, []byte("{}"), start.Add(off)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			list, _ := s.List(file)
+			if len(list) != len(tt.want) {
+				t.Fatalf("got %d backups, want %d: %+v", len(list), len(tt.want), list)
+			}
+			for i, off := range tt.want {
+				if !list[i].Time.Equal(start.Add(off)) {
+					t.Errorf("backup %d time = %v, want %v", i, list[i].Time, start.Add(off))
+				}
+			}
+		})
+	}
+}
+
+func TestBackupLatest(t *testing.T) {
+	t.Parallel()
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	dir := t.TempDir()
+	s := NewBackupStore(filepath.Join(dir, "backups"), false, RetentionPolicy{})
+	file := filepath.Join(dir, "settings.json")
+	for i := range 3 {
+		s.Save(file, []byte("{}"), start.Add(time.Duration(i)*time.Hour))
+	}
+
+	tests := []struct {
+		name string
+		at   time.Time
+		want time.Time
+		none bool
+	}{
+		{name: "zero selects newest", want: start.Add(2 * time.Hour)},
+		{name: "between backups", at: start.Add(90 * time.Minute), want: start.Add(time.Hour)},
+		{name: "exact time", at: start.Add(time.Hour), want: start.Add(time.Hour)},
+		{name: "before first", at: start.Add(-time.Minute), none: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			b, err := s.Latest(file, tt.at)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.none {
+				if b != nil {
+					t.Errorf("Latest = %+v, want nil", b)
+				}
+				return
+			}
+			if b == nil || !b.Time.Equal(tt.want) {
+				t.Errorf("Latest = %+v, want time %v", b, tt.want)
+			}
+		})
+	}
+
+	t.Run("unknown file", func(t *testing.T) {
+		t.Parallel()
+		b, err := s.Latest(filepath.Join(dir, "other.json"), time.Time{})
+		if err != nil || b != nil {
+			t.Errorf("Latest = %+v, %v", b, err)
+		}
+	})
+
+	t.Run("source file records original path", func(t *testing.T) {
+		t.Parallel()
+		data, err := os.ReadFile(filepath.Join(s.fileDir(file), backupSourceFile))
+		if err != nil || string(data) != file+"\n" {
+			t.Errorf("source = %q, %v", data, err)
+		}
+	})
+}
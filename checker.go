@@ -0,0 +1,148 @@
+package cctidy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ErrCheckTimeout is returned by TimeoutChecker when an existence
+// check does not finish within its deadline. Callers treat it like
+// any other unknown status and keep the entry.
+var ErrCheckTimeout = errors.New("existence check timed out")
+
+// DefaultCheckTimeout is the per-call deadline used when
+// [paths] check_timeout is not configured.
+const DefaultCheckTimeout = 5 * time.Second
+
+// OSPathChecker checks paths with os.Stat.
+type OSPathChecker struct{}
+
+// Exists reports a path as missing only for ENOENT and ENOTDIR.
+// Other stat errors (EACCES, EIO, ELOOP, ESTALE, ...) are
+// returned so that the entry is kept. A path ending in a separator
+// exists only when it is a directory.
+func (OSPathChecker) Exists(_ context.Context, path string) (bool, error) {
+	fi, err := os.Stat(path)
+	switch {
+	case err == nil:
+		return fi.IsDir() || !strings.HasSuffix(path, string(filepath.Separator)), nil
+	case errors.Is(err, fs.ErrNotExist), errors.Is(err, syscall.ENOTDIR):
+		return false, nil
+	default:
+		return false, err
+	}
+}
+
+// CachingChecker wraps a PathChecker and memoizes its results, so
+// that a path referenced by several entries or files is checked
+// once per run. Concurrent calls for the same path share a single
+// inner call. Results caused by the caller's context ending are not
+// cached. A CachingChecker is meant to live for a single run.
+type CachingChecker struct {
+	inner PathChecker
+
+	mu      sync.Mutex
+	entries map[string]*cacheEntry
+}
+
+// cacheEntry holds the result of one inner call. done is closed
+// once exists and err are set.
+type cacheEntry struct {
+	done   chan struct{}
+	exists bool
+	err    error
+	// canceled marks a result caused by the context of the first
+	// caller; waiters retry with their own context.
+	canceled bool
+}
+
+// NewCachingChecker creates a CachingChecker.
+func NewCachingChecker(inner PathChecker) *CachingChecker {
+	return &CachingChecker{inner: inner, entries: map[string]*cacheEntry{}}
+}
+
+// Exists returns the cached result for path, consulting the inner
+// checker on first use.
+func (c *CachingChecker) Exists(ctx context.Context, path string) (bool, error) {
+	c.mu.Lock()
+	e, ok := c.entries[path]
+	if !ok {
+		e = &cacheEntry{done: make(chan struct{})}
+		c.entries[path] = e
+	}
+	c.mu.Unlock()
+
+	if ok {
+		select {
+		case <-e.done:
+			if e.canceled {
+				return c.Exists(ctx, path)
+			}
+			return e.exists, e.err
+		case <-ctx.Done():
+			return false, ctx.Err()
+		}
+	}
+
+	e.exists, e.err = c.inner.Exists(ctx, path)
+	if e.err != nil && ctx.Err() != nil {
+		e.canceled = true
+		c.mu.Lock()
+		delete(c.entries, path)
+		c.mu.Unlock()
+	}
+	close(e.done)
+	return e.exists, e.err
+}
+
+// TimeoutChecker wraps a PathChecker and bounds each call by a
+// deadline. A call that does not finish in time returns
+// ErrCheckTimeout, so that a hung filesystem (e.g. an unreachable
+// NFS mount) marks the path as unknown instead of blocking the run.
+// The inner call keeps running in the background until it returns.
+type TimeoutChecker struct {
+	inner   PathChecker
+	timeout time.Duration
+}
+
+// NewTimeoutChecker creates a TimeoutChecker. A timeout of zero or
+// less disables the deadline.
+func NewTimeoutChecker(inner PathChecker, timeout time.Duration) *TimeoutChecker {
+	return &TimeoutChecker{inner: inner, timeout: timeout}
+}
+
+// Exists calls the inner checker and waits for at most the
+// configured timeout. It also returns early when ctx ends.
+func (t *TimeoutChecker) Exists(ctx context.Context, path string) (bool, error) {
+	if t.timeout <= 0 {
+		return t.inner.Exists(ctx, path)
+	}
+	type result struct {
+		exists bool
+		err    error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		exists, err := t.inner.Exists(ctx, path)
+		ch <- result{exists: exists, err: err}
+	}()
+
+	timer := time.NewTimer(t.timeout)
+	defer timer.Stop()
+	select {
+	case r := <-ch:
+		return r.exists, r.err
+	case <-timer.C:
+		return false, fmt.Errorf("%w after %s", ErrCheckTimeout, t.timeout)
+	case <-ctx.Done():
+		return false, ctx.Err()
+	}
+}
@@ -0,0 +1,204 @@
+package cctidy
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestOSPathChecker(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file")
+	os.WriteFile(file, []byte("x"), 0o644)
+	loop := filepath.Join(dir, "loop")
+	os.Symlink(loop, loop)
+
+	tests := []struct {
+		name       string
+		path       string
+		wantExists bool
+		wantErr    bool
+	}{
+		{name: "existing file", path: file, wantExists: true},
+		{name: "ENOENT is missing", path: filepath.Join(dir, "gone")},
+		{name: "ENOTDIR is missing", path: filepath.Join(file, "child")},
+		{name: "directory with trailing separator", path: dir + string(filepath.Separator), wantExists: true},
+		{name: "file with trailing separator is missing", path: file + string(filepath.Separator)},
+		{name: "ELOOP is unknown", path: loop, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if tt.wantErr && runtime.GOOS == "windows" {
+				t.Skip("skip on windows")
+			}
+			exists, err := OSPathChecker{}.Exists(t.Context(), tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Exists() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if exists != tt.wantExists {
+				t.Errorf("Exists() = %v, want %v", exists, tt.wantExists)
+			}
+		})
+	}
+}
+
+// countingChecker counts inner calls and reports paths in exist as
+// existing. When block is set, calls wait for it to be closed.
+type countingChecker struct {
+	calls atomic.Int32
+	exist map[string]bool
+	block chan struct{}
+	err   error
+}
+
+func (c *countingChecker) Exists(ctx context.Context, path string) (bool, error) {
+	c.calls.Add(1)
+	if c.block != nil {
+		select {
+		case <-c.block:
+		case <-ctx.Done():
+			return false, ctx.Err()
+		}
+	}
+	return c.exist[path], c.err
+}
+
+func TestCachingChecker(t *testing.T) {
+	t.Parallel()
+
+	t.Run("memoizes results per path", func(t *testing.T) {
+		t.Parallel()
+		inner := &countingChecker{exist: map[string]bool{"/a": true}}
+		c := NewCachingChecker(inner)
+		for range 3 {
+			if ok, err := c.Exists(t.Context(), "/a"); !ok || err != nil {
+				t.Fatalf("Exists(/a) = %v, %v", ok, err)
+			}
+			if ok, err := c.Exists(t.Context(), "/b"); ok || err != nil {
+				t.Fatalf("Exists(/b) = %v, %v", ok, err)
+			}
+		}
+		if n := inner.calls.Load(); n != 2 {
+			t.Errorf("inner calls = %d, want 2", n)
+		}
+	})
+
+	t.Run("memoizes unknown status", func(t *testing.T) {
+		t.Parallel()
+		errIO := errors.New("i/o error")
+		inner := &countingChecker{err: errIO}
+		c := NewCachingChecker(inner)
+		for range 2 {
+			if _, err := c.Exists(t.Context(), "/a"); !errors.Is(err, errIO) {
+				t.Fatalf("err = %v, want %v", err, errIO)
+			}
+		}
+		if n := inner.calls.Load(); n != 1 {
+			t.Errorf("inner calls = %d, want 1", n)
+		}
+	})
+
+	t.Run("concurrent calls share one inner call", func(t *testing.T) {
+		t.Parallel()
+		inner := &countingChecker{exist: map[string]bool{"/a": true}, block: make(chan struct{})}
+		c := NewCachingChecker(inner)
+		var wg sync.WaitGroup
+		for range 8 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if ok, err := c.Exists(t.Context(), "/a"); !ok || err != nil {
+					t.Errorf("Exists(/a) = %v, %v", ok, err)
+				}
+			}()
+		}
+		for inner.calls.Load() == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		close(inner.block)
+		wg.Wait()
+		if n := inner.calls.Load(); n != 1 {
+			t.Errorf("inner calls = %d, want 1", n)
+		}
+	})
+
+	t.Run("canceled context is not cached", func(t *testing.T) {
+		t.Parallel()
+		inner := &countingChecker{exist: map[string]bool{"/a": true}, block: make(chan struct{})}
+		c := NewCachingChecker(inner)
+		ctx, cancel := context.WithCancel(t.Context())
+		cancel()
+		if _, err := c.Exists(ctx, "/a"); !errors.Is(err, context.Canceled) {
+			t.Fatalf("err = %v, want context.Canceled", err)
+		}
+		close(inner.block)
+		if ok, err := c.Exists(t.Context(), "/a"); !ok || err != nil {
+			t.Errorf("Exists(/a) after cancel = %v, %v", ok, err)
+		}
+	})
+}
+
+func TestTimeoutChecker(t *testing.T) {
+	t.Parallel()
+
+	t.Run("hung check returns ErrCheckTimeout", func(t *testing.T) {
+		t.Parallel()
+		inner := &countingChecker{block: make(chan struct{})}
+		defer close(inner.block)
+		c := NewTimeoutChecker(inner, 10*time.Millisecond)
+		_, err := c.Exists(t.Context(), "/nfs/hung")
+		if !errors.Is(err, ErrCheckTimeout) {
+			t.Fatalf("err = %v, want ErrCheckTimeout", err)
+		}
+		if got := unknownReason(err); got != ReasonCheckTimeout {
+			t.Errorf("unknownReason = %q, want %q", got, ReasonCheckTimeout)
+		}
+	})
+
+	t.Run("fast check passes result through", func(t *testing.T) {
+		t.Parallel()
+		inner := &countingChecker{exist: map[string]bool{"/a": true}}
+		c := NewTimeoutChecker(inner, time.Second)
+		if ok, err := c.Exists(t.Context(), "/a"); !ok || err != nil {
+			t.Errorf("Exists(/a) = %v, %v", ok, err)
+		}
+		if ok, err := c.Exists(t.Context(), "/b"); ok || err != nil {
+			t.Errorf("Exists(/b) = %v, %v", ok, err)
+		}
+	})
+
+	t.Run("zero timeout disables deadline", func(t *testing.T) {
+		t.Parallel()
+		inner := &countingChecker{exist: map[string]bool{"/a": true}}
+		c := NewTimeoutChecker(inner, 0)
+		if ok, err := c.Exists(t.Context(), "/a"); !ok || err != nil {
+			t.Errorf("Exists(/a) = %v, %v", ok, err)
+		}
+	})
+
+	t.Run("hung path is kept by formatter", func(t *testing.T) {
+		t.Parallel()
+		inner := &countingChecker{block: make(chan struct{})}
+		defer close(inner.block)
+		f := NewClaudeJSONFormatter(NewTimeoutChecker(inner, 10*time.Millisecond))
+		result, err := f.Format(t.Context(), []byte(`{"projects": {"/nfs/hung": {}}}`))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		s := result.Stats.(*ClaudeJSONFormatterStats)
+		if s.ProjectsAfter != 1 {
+			t.Errorf("ProjectsAfter = %d, want 1", s.ProjectsAfter)
+		}
+		if len(s.Records) != 1 || s.Records[0].Reason != ReasonCheckTimeout {
+			t.Errorf("Records = %+v, want one %s record", s.Records, ReasonCheckTimeout)
+		}
+	})
+}
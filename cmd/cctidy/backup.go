@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/708u/cctidy"
+)
+
+type restoreCmd struct {
+	File string `arg:"" optional:"" type:"path" help:"File to restore. Lists all backups when omitted."`
+	At   string `help:"Restore the newest backup taken at or before a time (RFC 3339) or a duration ago (e.g. 2h)."`
+	List bool   `help:"List backups instead of restoring."`
+}
+
+func (restoreCmd) Run(ctx context.Context, c *CLI) error {
+	return c.RunRestore(ctx)
+}
+
+// newBackupStore returns the store described by cfg, expanding a
+// leading ~/ in the directory against homeDir.
+func newBackupStore(cfg cctidy.BackupConfig, homeDir string) *cctidy.BackupStore {
+	dir := cfg.Dir
+	if dir == "" {
+		dir = cctidy.DefaultBackupDir(homeDir)
+	} else if rest, ok := strings.CutPrefix(dir, "~/"); ok {
+		dir = filepath.Join(homeDir, rest)
+	}
+	return cctidy.NewBackupStore(dir, cfg.Compress, cfg.Retention())
+}
+
+// backup stores data as a backup of path when --backup is set, or
+// when destructive and backup.auto is enabled. It returns the
+// backup location, or "" when no backup was taken.
+func (c *CLI) backup(path string, data []byte, destructive bool) (string, error) {
+	if c.backups == nil {
+		return "", nil
+	}
+	auto := destructive && c.cfg != nil && c.cfg.Backup.Auto
+	if !c.Backup && !auto {
+		return "", nil
+	}
+	b, err := c.backups.Save(path, data, time.Now())
+	if b == nil {
+		return "", err
+	}
+	if err != nil {
+		fmt.Fprintf(c.w, "cctidy: warning: %v\n", err)
+	}
+	return b.Path, nil
+}
+
+// hasSwept reports whether any entry was swept.
+func hasSwept(s cctidy.Summarizer) bool {
+	for _, rec := range statsRecords(s) {
+		if rec.Decision == cctidy.DecisionSwept {
+			return true
+		}
+	}
+	return false
+}
+
+// RunRestore lists backups or restores a file from one.
+func (c *CLI) RunRestore(ctx context.Context) error {
+	r := c.Restore
+	if r.File == "" || r.List {
+		if r.At != "" {
+			return errors.New("--at cannot be combined with listing backups")
+		}
+		return c.listBackups(r.File)
+	}
+
+	var at time.Time
+	if r.At != "" {
+		var err error
+		if at, err = parseTime(r.At, time.Now()); err != nil {
+			return fmt.Errorf("invalid --at: %w", err)
+		}
+	}
+	b, err := c.backups.Latest(r.File, at)
+	if err != nil {
+		return err
+	}
+	if b == nil {
+		if at.IsZero() {
+			return fmt.Errorf("no backup of %s", r.File)
+		}
+		return fmt.Errorf("no backup of %s taken at or before %s", r.File, at.Format(time.RFC3339))
+	}
+	data, err := c.backups.Read(*b)
+	if err != nil {
+		return err
+	}
+
+	when := b.Time.Local().Format(time.DateTime)
+	if c.DryRun {
+		if c.Verbose {
+			fmt.Fprintf(c.w, "%s: would restore backup from %s\n", r.File, when)
+		}
+		return nil
+	}
+
+	if _, err := os.Stat(r.File); os.IsNotExist(err) {
+		if err := writeFile(r.File, data, 0o600); err != nil {
+			return fmt.Errorf("writing %s: %w", r.File, err)
+		}
+	} else {
+		matched := false
+		err := c.updateFile(ctx, r.File, func(current []byte) ([]byte, error) {
+			if matched = bytes.Equal(current, data); matched {
+				return nil, nil
+			}
+			return data, nil
+		}, func(current []byte) error {
+			// Keep the current content so that the restore can be undone.
+			if _, err := c.backups.Save(r.File, current, time.Now()); err != nil {
+				return fmt.Errorf("backing up %s: %w", r.File, err)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if matched {
+			if c.Verbose {
+				fmt.Fprintf(c.w, "%s: already matches backup from %s\n", r.File, when)
+			}
+			return nil
+		}
+	}
+	if c.Verbose {
+		fmt.Fprintf(c.w, "%s: restored backup from %s\n", r.File, when)
+	}
+	return nil
+}
+
+func (c *CLI) listBackups(file string) error {
+	backups, err := c.backups.List(file)
+	if err != nil {
+		return err
+	}
+	if c.jsonOutput() {
+		if backups == nil {
+			backups = []cctidy.Backup{}
+		}
+		enc := json.NewEncoder(c.out)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		return enc.Encode(backups)
+	}
+	if len(backups) == 0 {
+		fmt.Fprintln(c.out, "no backups")
+		return nil
+	}
+	tw := tabwriter.NewWriter(c.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TIME\tFILE\tBACKUP")
+	for _, b := range backups {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", b.Time.Local().Format(time.DateTime), b.File, b.Path)
+	}
+	return tw.Flush()
+}
@@ -4,14 +4,21 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
 	"flag"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/708u/cctidy"
+	"github.com/708u/cctidy/internal/jsonpatch"
 	"github.com/708u/cctidy/internal/set"
 	"github.com/708u/cctidy/internal/testutil"
 )
@@ -149,8 +156,8 @@ func TestIntegrationPathCleaning(t *testing.T) {
 	os.WriteFile(file, []byte(input), 0o644)
 
 	var buf bytes.Buffer
-	cli := &CLI{Target: file, Verbose: true, checker: &osPathChecker{}, homeDir: dir, w: &buf}
-	if err := cli.Run(t.Context()); err != nil {
+	cli := &CLI{Target: file, Verbose: true, checker: cctidy.OSPathChecker{}, homeDir: dir, w: &buf}
+	if err := cli.RunTidy(t.Context()); err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
@@ -197,7 +204,7 @@ func TestRunSingleTarget(t *testing.T) {
 
 		var buf bytes.Buffer
 		cli := &CLI{Target: file, Verbose: true, checker: testutil.AllPathsExist{}, homeDir: dir, w: &buf}
-		if err := cli.Run(t.Context()); err != nil {
+		if err := cli.RunTidy(t.Context()); err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
 
@@ -225,7 +232,7 @@ func TestRunSingleTarget(t *testing.T) {
 
 		var buf bytes.Buffer
 		cli := &CLI{Target: file, DryRun: true, Verbose: true, checker: testutil.AllPathsExist{}, homeDir: dir, w: &buf}
-		if err := cli.Run(t.Context()); err != nil {
+		if err := cli.RunTidy(t.Context()); err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
 
@@ -251,9 +258,11 @@ func TestRunSingleTarget(t *testing.T) {
 		file := filepath.Join(dir, ".claude.json")
 		os.WriteFile(file, []byte(input), 0o644)
 
+		backups := filepath.Join(dir, "backups")
 		var buf bytes.Buffer
-		cli := &CLI{Target: file, Backup: true, Verbose: true, checker: testutil.AllPathsExist{}, homeDir: dir, w: &buf}
-		if err := cli.Run(t.Context()); err != nil {
+		cli := &CLI{Target: file, Backup: true, Verbose: true, checker: testutil.AllPathsExist{}, homeDir: dir, w: &buf,
+			backups: cctidy.NewBackupStore(backups, false, cctidy.RetentionPolicy{})}
+		if err := cli.RunTidy(t.Context()); err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
 
@@ -262,7 +271,10 @@ func TestRunSingleTarget(t *testing.T) {
 			t.Errorf("file content mismatch")
 		}
 
-		matches, _ := filepath.Glob(filepath.Join(dir, ".claude.json.backup.*"))
+		if matches, _ := filepath.Glob(filepath.Join(dir, ".claude.json.backup.*")); len(matches) != 0 {
+			t.Errorf("backup written next to target: %v", matches)
+		}
+		matches, _ := filepath.Glob(filepath.Join(backups, "*", "*.json"))
 		if len(matches) != 1 {
 			t.Fatalf("expected 1 backup file, got %d", len(matches))
 		}
@@ -277,6 +289,33 @@ func TestRunSingleTarget(t *testing.T) {
 		}
 	})
 
+	t.Run("unchanged file is not rewritten or backed up", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		file := filepath.Join(dir, ".claude.json")
+		os.WriteFile(file, []byte(wantJSON), 0o644)
+		before, _ := os.Stat(file)
+
+		backups := filepath.Join(dir, "backups")
+		var buf bytes.Buffer
+		cli := &CLI{Target: file, Backup: true, Verbose: true, checker: testutil.AllPathsExist{}, homeDir: dir, w: &buf,
+			backups: cctidy.NewBackupStore(backups, false, cctidy.RetentionPolicy{})}
+		if err := cli.RunTidy(t.Context()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		after, _ := os.Stat(file)
+		if !os.SameFile(before, after) || !after.ModTime().Equal(before.ModTime()) {
+			t.Error("unchanged file was rewritten")
+		}
+		if _, err := os.Stat(backups); !os.IsNotExist(err) {
+			t.Error("backup created for unchanged file")
+		}
+		if output := buf.String(); !strings.Contains(output, "Unchanged: not written") || strings.Contains(output, "Backup:") {
+			t.Errorf("unexpected output: %s", output)
+		}
+	})
+
 	t.Run("no temp files remain after write", func(t *testing.T) {
 		t.Parallel()
 		dir := t.TempDir()
@@ -285,7 +324,7 @@ func TestRunSingleTarget(t *testing.T) {
 
 		var buf bytes.Buffer
 		cli := &CLI{Target: file, checker: testutil.AllPathsExist{}, homeDir: dir, w: &buf}
-		if err := cli.Run(t.Context()); err != nil {
+		if err := cli.RunTidy(t.Context()); err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
 
@@ -303,7 +342,7 @@ func TestRunSingleTarget(t *testing.T) {
 
 		var buf bytes.Buffer
 		cli := &CLI{Target: file, checker: testutil.AllPathsExist{}, homeDir: dir, w: &buf}
-		if err := cli.Run(t.Context()); err != nil {
+		if err := cli.RunTidy(t.Context()); err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
 
@@ -317,7 +356,7 @@ func TestRunSingleTarget(t *testing.T) {
 		t.Parallel()
 		var buf bytes.Buffer
 		cli := &CLI{Target: "/nonexistent/path/test.json", checker: testutil.AllPathsExist{}, homeDir: "/tmp", w: &buf}
-		err := cli.Run(t.Context())
+		err := cli.RunTidy(t.Context())
 		if err == nil {
 			t.Fatal("expected error for missing file")
 		}
@@ -396,7 +435,7 @@ func TestRunMultipleTargets(t *testing.T) {
 		}
 	})
 
-	t.Run("no changes shown for already formatted file", func(t *testing.T) {
+	t.Run("unchanged shown for already formatted file", func(t *testing.T) {
 		t.Parallel()
 		dir := t.TempDir()
 
@@ -415,8 +454,8 @@ func TestRunMultipleTargets(t *testing.T) {
 		}
 
 		output := buf.String()
-		if !strings.Contains(output, "(no changes)") {
-			t.Errorf("output should contain 'no changes': %s", output)
+		if !strings.Contains(output, "(unchanged)") {
+			t.Errorf("output should contain 'unchanged': %s", output)
 		}
 	})
 
@@ -430,7 +469,7 @@ func TestRunMultipleTargets(t *testing.T) {
 
 		var buf bytes.Buffer
 		cli := &CLI{Target: settingsJSON, checker: testutil.AllPathsExist{}, homeDir: dir, w: &buf}
-		if err := cli.Run(t.Context()); err != nil {
+		if err := cli.RunTidy(t.Context()); err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
 
@@ -465,7 +504,7 @@ func TestCheck(t *testing.T) {
 
 		var buf bytes.Buffer
 		cli := &CLI{Target: file, Check: true, checker: testutil.AllPathsExist{}, homeDir: dir, w: &buf}
-		if err := cli.Run(t.Context()); err != nil {
+		if err := cli.RunTidy(t.Context()); err != nil {
 			t.Fatalf("expected nil, got: %v", err)
 		}
 		if buf.Len() != 0 {
@@ -481,7 +520,7 @@ func TestCheck(t *testing.T) {
 
 		var buf bytes.Buffer
 		cli := &CLI{Target: file, Check: true, checker: testutil.AllPathsExist{}, homeDir: dir, w: &buf}
-		err := cli.Run(t.Context())
+		err := cli.RunTidy(t.Context())
 		if !errors.Is(err, errUnformatted) {
 			t.Fatalf("expected errUnformatted, got: %v", err)
 		}
@@ -496,7 +535,7 @@ func TestCheck(t *testing.T) {
 
 		var buf bytes.Buffer
 		cli := &CLI{Target: file, Check: true, checker: testutil.AllPathsExist{}, homeDir: dir, w: &buf}
-		cli.Run(t.Context())
+		cli.RunTidy(t.Context())
 
 		data, _ := os.ReadFile(file)
 		if string(data) != input {
@@ -508,7 +547,7 @@ func TestCheck(t *testing.T) {
 		t.Parallel()
 		var buf bytes.Buffer
 		cli := &CLI{Target: "/nonexistent/path/test.json", Check: true, checker: testutil.AllPathsExist{}, homeDir: "/tmp", w: &buf}
-		err := cli.Run(t.Context())
+		err := cli.RunTidy(t.Context())
 		if err == nil {
 			t.Fatal("expected error for missing file")
 		}
@@ -525,7 +564,7 @@ func TestCheck(t *testing.T) {
 
 		var buf bytes.Buffer
 		cli := &CLI{Target: file, Check: true, checker: testutil.AllPathsExist{}, homeDir: dir, w: &buf}
-		err := cli.Run(t.Context())
+		err := cli.RunTidy(t.Context())
 		if err == nil {
 			t.Fatal("expected error for invalid JSON")
 		}
@@ -635,7 +674,7 @@ func TestDefaultSilentOutput(t *testing.T) {
 
 	var buf bytes.Buffer
 	cli := &CLI{Target: file, checker: testutil.AllPathsExist{}, homeDir: dir, w: &buf}
-	if err := cli.Run(t.Context()); err != nil {
+	if err := cli.RunTidy(t.Context()); err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
@@ -650,7 +689,7 @@ func TestResolveTargets(t *testing.T) {
 	t.Run("with target flag returns single target", func(t *testing.T) {
 		t.Parallel()
 		cli := &CLI{Target: "/some/path.json", checker: testutil.AllPathsExist{}, homeDir: "/home/user"}
-		targets, err := cli.resolveTargets()
+		targets, err := cli.resolveTargets(t.Context())
 		if err != nil {
 			t.Fatalf("resolveTargets: %v", err)
 		}
@@ -665,7 +704,7 @@ func TestResolveTargets(t *testing.T) {
 	t.Run("claude.json target uses ClaudeJSONFormatter", func(t *testing.T) {
 		t.Parallel()
 		cli := &CLI{Target: "/home/user/.claude.json", homeDir: "/home/user", checker: testutil.AllPathsExist{}}
-		targets, err := cli.resolveTargets()
+		targets, err := cli.resolveTargets(t.Context())
 		if err != nil {
 			t.Fatalf("resolveTargets: %v", err)
 		}
@@ -677,7 +716,7 @@ func TestResolveTargets(t *testing.T) {
 	t.Run("settings.json target uses SettingsJSONFormatter", func(t *testing.T) {
 		t.Parallel()
 		cli := &CLI{Target: "/home/user/.claude/settings.json", homeDir: "/home/user", checker: testutil.AllPathsExist{}}
-		targets, err := cli.resolveTargets()
+		targets, err := cli.resolveTargets(t.Context())
 		if err != nil {
 			t.Fatalf("resolveTargets: %v", err)
 		}
@@ -689,7 +728,7 @@ func TestResolveTargets(t *testing.T) {
 	t.Run("without target returns default targets", func(t *testing.T) {
 		t.Parallel()
 		cli := &CLI{homeDir: "/home/user", checker: testutil.AllPathsExist{}}
-		targets, err := cli.resolveTargets()
+		targets, err := cli.resolveTargets(t.Context())
 		if err != nil {
 			t.Fatalf("resolveTargets: %v", err)
 		}
@@ -735,8 +774,8 @@ func TestIntegrationSweep(t *testing.T) {
 	os.WriteFile(file, []byte(input), 0o644)
 
 	var buf bytes.Buffer
-	cli := &CLI{Target: file, Verbose: true, homeDir: dir, checker: &osPathChecker{}, w: &buf}
-	if err := cli.Run(t.Context()); err != nil {
+	cli := &CLI{Target: file, Verbose: true, homeDir: dir, checker: cctidy.OSPathChecker{}, w: &buf}
+	if err := cli.RunTidy(t.Context()); err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
@@ -762,6 +801,157 @@ func TestIntegrationSweep(t *testing.T) {
 	}
 }
 
+func TestIntegrationUserLevelRelativeSweep(t *testing.T) {
+	t.Parallel()
+	home := t.TempDir()
+	claudeDir := filepath.Join(home, ".claude")
+	os.MkdirAll(filepath.Join(home, "notes"), 0o755)
+	os.MkdirAll(claudeDir, 0o755)
+	os.WriteFile(filepath.Join(home, "notes", "keep.md"), nil, 0o644)
+
+	input := `{
+  "permissions": {
+    "allow": [
+      "Read(/notes/keep.md)",
+      "Read(/notes/gone.md)",
+      "Edit(notes/gone-too.md)"
+    ]
+  }
+}`
+	file := filepath.Join(claudeDir, "settings.json")
+	os.WriteFile(file, []byte(input), 0o644)
+
+	var buf bytes.Buffer
+	cli := &CLI{Target: file, homeDir: home, checker: cctidy.OSPathChecker{}, w: &buf}
+	if err := cli.RunTidy(t.Context()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	data, _ := os.ReadFile(file)
+	got := string(data)
+	if !strings.Contains(got, "Read(/notes/keep.md)") {
+		t.Error("existing home-relative entry was removed")
+	}
+	if strings.Contains(got, "Read(/notes/gone.md)") {
+		t.Errorf("dead settings-relative entry was not removed:\n%s", got)
+	}
+	// Bare paths are relative to the working directory, so a
+	// user-level rule applies in every project and must be kept.
+	if !strings.Contains(got, "Edit(notes/gone-too.md)") {
+		t.Errorf("cwd-relative user-level entry was removed:\n%s", got)
+	}
+}
+
+func TestIntegrationPluginSettingsSweep(t *testing.T) {
+	t.Parallel()
+	home := t.TempDir()
+	pluginsDir := filepath.Join(home, ".claude", "plugins")
+	os.MkdirAll(filepath.Join(pluginsDir, "cache", "tools"), 0o755)
+	os.WriteFile(filepath.Join(pluginsDir, "installed_plugins.json"),
+		[]byte(`{"version":2,"plugins":{"tools@official":[{"installPath":"`+filepath.Join(pluginsDir, "cache", "tools")+`"}]}}`), 0o644)
+	os.WriteFile(filepath.Join(pluginsDir, "known_marketplaces.json"), []byte(`{"official":{}}`), 0o644)
+
+	input := `{
+  "enabledPlugins": {
+    "tools@official": true,
+    "removed@official": true,
+    "old@deleted-market": true
+  },
+  "permissions": {
+    "allow": [
+      "Task(tools:gone-agent)",
+      "Task(removed:agent)"
+    ]
+  }
+}`
+	file := filepath.Join(home, ".claude", "settings.json")
+	os.WriteFile(file, []byte(input), 0o644)
+
+	var buf bytes.Buffer
+	cli := &CLI{
+		Target:  file,
+		homeDir: home,
+		checker: cctidy.OSPathChecker{},
+		trash:   cctidy.NewTrashJournal(filepath.Join(home, "trash.jsonl")),
+		w:       &buf,
+	}
+	if err := cli.RunTidy(t.Context()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	data, _ := os.ReadFile(file)
+	want := `{
+  "enabledPlugins": {
+    "tools@official": true
+  },
+  "permissions": {
+    "allow": []
+  }
+}
+`
+	if string(data) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", data, want)
+	}
+
+	items, err := cli.trash.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var plugins []string
+	for _, it := range items {
+		if it.Category == "enabledPlugins" {
+			plugins = append(plugins, it.Entry+"="+string(it.Value)+" "+string(it.Reason))
+		}
+	}
+	wantItems := []string{"old@deleted-market=true marketplace-missing", "removed@official=true plugin-missing"}
+	if !slices.Equal(plugins, wantItems) {
+		t.Errorf("trash items = %v, want %v", plugins, wantItems)
+	}
+}
+
+func TestIntegrationProjectPluginSettings(t *testing.T) {
+	t.Parallel()
+	home := t.TempDir()
+	pluginsDir := filepath.Join(home, ".claude", "plugins")
+	os.MkdirAll(filepath.Join(pluginsDir, "cache", "tools"), 0o755)
+	os.WriteFile(filepath.Join(pluginsDir, "installed_plugins.json"),
+		[]byte(`{"version":2,"plugins":{"tools@official":[{"installPath":"`+filepath.Join(pluginsDir, "cache", "tools")+`"}]}}`), 0o644)
+	os.WriteFile(filepath.Join(pluginsDir, "known_marketplaces.json"), []byte(`{"official":{}}`), 0o644)
+
+	claudeDir := filepath.Join(home, "project", ".claude")
+	os.MkdirAll(claudeDir, 0o755)
+	input := `{
+  "enabledPlugins": {
+    "removed@official": true
+  }
+}
+`
+	tests := []struct {
+		file      string
+		wantSwept bool
+	}{
+		{file: "settings.json", wantSwept: false},
+		{file: "settings.local.json", wantSwept: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			t.Parallel()
+			file := filepath.Join(claudeDir, tt.file)
+			os.WriteFile(file, []byte(input), 0o644)
+
+			var buf bytes.Buffer
+			cli := &CLI{Target: file, homeDir: home, checker: cctidy.OSPathChecker{}, w: &buf}
+			if err := cli.RunTidy(t.Context()); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			data, _ := os.ReadFile(file)
+			if swept := !strings.Contains(string(data), "removed@official"); swept != tt.wantSwept {
+				t.Errorf("swept = %v, want %v:\n%s", swept, tt.wantSwept, data)
+			}
+		})
+	}
+}
+
 func TestIntegrationBashSweep(t *testing.T) {
 	t.Parallel()
 	dir := t.TempDir()
@@ -805,8 +995,8 @@ func TestIntegrationBashSweep(t *testing.T) {
 	os.WriteFile(file, []byte(input), 0o644)
 
 	var buf bytes.Buffer
-	cli := &CLI{Target: file, Unsafe: true, Verbose: true, homeDir: dir, checker: &osPathChecker{}, w: &buf}
-	if err := cli.Run(t.Context()); err != nil {
+	cli := &CLI{Target: file, Unsafe: true, Verbose: true, homeDir: dir, checker: cctidy.OSPathChecker{}, w: &buf}
+	if err := cli.RunTidy(t.Context()); err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
@@ -904,8 +1094,8 @@ func TestIntegrationTaskSweepProjectLevel(t *testing.T) {
 	os.WriteFile(file, []byte(input), 0o644)
 
 	var buf bytes.Buffer
-	cli := &CLI{Target: file, Verbose: true, homeDir: dir, checker: &osPathChecker{}, w: &buf}
-	if err := cli.Run(t.Context()); err != nil {
+	cli := &CLI{Target: file, Verbose: true, homeDir: dir, checker: cctidy.OSPathChecker{}, w: &buf}
+	if err := cli.RunTidy(t.Context()); err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
@@ -989,8 +1179,8 @@ func TestIntegrationTaskSweepUserLevel(t *testing.T) {
 	os.WriteFile(file, []byte(input), 0o644)
 
 	var buf bytes.Buffer
-	cli := &CLI{Target: file, Verbose: true, homeDir: dir, checker: &osPathChecker{}, w: &buf}
-	if err := cli.Run(t.Context()); err != nil {
+	cli := &CLI{Target: file, Verbose: true, homeDir: dir, checker: cctidy.OSPathChecker{}, w: &buf}
+	if err := cli.RunTidy(t.Context()); err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
@@ -1040,8 +1230,8 @@ func TestIntegrationBashSweepDisabledByDefault(t *testing.T) {
 
 	var buf bytes.Buffer
 	// Unsafe is NOT set
-	cli := &CLI{Target: file, Verbose: true, homeDir: dir, checker: &osPathChecker{}, w: &buf}
-	if err := cli.Run(t.Context()); err != nil {
+	cli := &CLI{Target: file, Verbose: true, homeDir: dir, checker: cctidy.OSPathChecker{}, w: &buf}
+	if err := cli.RunTidy(t.Context()); err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
@@ -1069,8 +1259,8 @@ func TestSweepCheck(t *testing.T) {
 	os.WriteFile(file, []byte(input), 0o644)
 
 	var buf bytes.Buffer
-	cli := &CLI{Target: file, Check: true, homeDir: dir, checker: &osPathChecker{}, w: &buf}
-	err := cli.Run(t.Context())
+	cli := &CLI{Target: file, Check: true, homeDir: dir, checker: cctidy.OSPathChecker{}, w: &buf}
+	err := cli.RunTidy(t.Context())
 	if !errors.Is(err, errUnformatted) {
 		t.Fatalf("expected errUnformatted, got: %v", err)
 	}
@@ -1115,11 +1305,11 @@ exclude_commands = ["mkdir", "touch"]
 			Unsafe:  true,
 			Verbose: true,
 			homeDir: dir,
-			checker: &osPathChecker{},
+			checker: cctidy.OSPathChecker{},
 			cfg:     cfg,
 			w:       &buf,
 		}
-		if err := cli.Run(t.Context()); err != nil {
+		if err := cli.RunTidy(t.Context()); err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
 
@@ -1172,11 +1362,11 @@ exclude_entries = ["install -m 755 `+deadPath+`/bin/app"]
 			Unsafe:  true,
 			Verbose: true,
 			homeDir: dir,
-			checker: &osPathChecker{},
+			checker: cctidy.OSPathChecker{},
 			cfg:     cfg,
 			w:       &buf,
 		}
-		if err := cli.Run(t.Context()); err != nil {
+		if err := cli.RunTidy(t.Context()); err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
 
@@ -1226,11 +1416,11 @@ exclude_paths = ["`+deadPath+`/opt/"]
 			Unsafe:  true,
 			Verbose: true,
 			homeDir: dir,
-			checker: &osPathChecker{},
+			checker: cctidy.OSPathChecker{},
 			cfg:     cfg,
 			w:       &buf,
 		}
-		if err := cli.Run(t.Context()); err != nil {
+		if err := cli.RunTidy(t.Context()); err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
 
@@ -1278,11 +1468,11 @@ enabled = true
 			Target:  file,
 			Verbose: true,
 			homeDir: dir,
-			checker: &osPathChecker{},
+			checker: cctidy.OSPathChecker{},
 			cfg:     cfg,
 			w:       &buf,
 		}
-		if err := cli.Run(t.Context()); err != nil {
+		if err := cli.RunTidy(t.Context()); err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
 
@@ -1327,11 +1517,11 @@ enabled = false
 			Target:  file,
 			Verbose: true,
 			homeDir: dir,
-			checker: &osPathChecker{},
+			checker: cctidy.OSPathChecker{},
 			cfg:     cfg,
 			w:       &buf,
 		}
-		if err := cli.Run(t.Context()); err != nil {
+		if err := cli.RunTidy(t.Context()); err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
 
@@ -1377,11 +1567,11 @@ enabled = false
 			Unsafe:  true,
 			Verbose: true,
 			homeDir: dir,
-			checker: &osPathChecker{},
+			checker: cctidy.OSPathChecker{},
 			cfg:     cfg,
 			w:       &buf,
 		}
-		if err := cli.Run(t.Context()); err != nil {
+		if err := cli.RunTidy(t.Context()); err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
 
@@ -1415,11 +1605,11 @@ enabled = false
 			Target:  file,
 			Verbose: true,
 			homeDir: dir,
-			checker: &osPathChecker{},
+			checker: cctidy.OSPathChecker{},
 			cfg:     cfg,
 			w:       &buf,
 		}
-		if err := cli.Run(t.Context()); err != nil {
+		if err := cli.RunTidy(t.Context()); err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
 
@@ -1448,8 +1638,8 @@ func TestSweepDryRun(t *testing.T) {
 	os.WriteFile(file, []byte(input), 0o644)
 
 	var buf bytes.Buffer
-	cli := &CLI{Target: file, DryRun: true, Verbose: true, homeDir: dir, checker: &osPathChecker{}, w: &buf}
-	if err := cli.Run(t.Context()); err != nil {
+	cli := &CLI{Target: file, DryRun: true, Verbose: true, homeDir: dir, checker: cctidy.OSPathChecker{}, w: &buf}
+	if err := cli.RunTidy(t.Context()); err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
@@ -1459,6 +1649,717 @@ func TestSweepDryRun(t *testing.T) {
 	}
 }
 
+func TestDiff(t *testing.T) {
+	t.Parallel()
+
+	setup := func(t *testing.T) (dir, file, deadPath string) {
+		t.Helper()
+		dir = t.TempDir()
+		deadPath = filepath.Join(dir, "gone-project")
+		input := "{\n  \"permissions\": {\n    \"allow\": [\n      \"Read(/" + deadPath + ")\",\n      \"Write\"\n    ]\n  }\n}\n"
+		file = filepath.Join(dir, "settings.json")
+		os.WriteFile(file, []byte(input), 0o644)
+		return dir, file, deadPath
+	}
+
+	t.Run("dry-run prints diff without writing", func(t *testing.T) {
+		t.Parallel()
+		dir, file, deadPath := setup(t)
+		before, _ := os.ReadFile(file)
+
+		var errBuf, outBuf bytes.Buffer
+		cli := &CLI{Target: file, DryRun: true, Diff: true, homeDir: dir, checker: cctidy.OSPathChecker{}, w: &errBuf, out: &outBuf}
+		if err := cli.RunTidy(t.Context()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		after, _ := os.ReadFile(file)
+		if !bytes.Equal(before, after) {
+			t.Error("file was modified in dry-run mode")
+		}
+		out := outBuf.String()
+		if !strings.Contains(out, "--- "+file+".orig\n+++ "+file+"\n") {
+			t.Errorf("missing diff header: %s", out)
+		}
+		if !strings.Contains(out, "-      \"Read(/"+deadPath+")\",") {
+			t.Errorf("missing removed entry line: %s", out)
+		}
+		if strings.Contains(out, "\x1b[") {
+			t.Errorf("diff should not be colored for non-terminal output: %q", out)
+		}
+	})
+
+	t.Run("check prints diff and reports unformatted", func(t *testing.T) {
+		t.Parallel()
+		dir, file, deadPath := setup(t)
+
+		var errBuf, outBuf bytes.Buffer
+		cli := &CLI{Target: file, Check: true, Diff: true, homeDir: dir, checker: cctidy.OSPathChecker{}, w: &errBuf, out: &outBuf}
+		err := cli.RunTidy(t.Context())
+		if !errors.Is(err, errUnformatted) {
+			t.Fatalf("expected errUnformatted, got: %v", err)
+		}
+		if !strings.Contains(outBuf.String(), "-      \"Read(/"+deadPath+")\",") {
+			t.Errorf("missing removed entry line: %s", outBuf.String())
+		}
+	})
+
+	t.Run("color always emits ANSI codes", func(t *testing.T) {
+		t.Parallel()
+		dir, file, _ := setup(t)
+
+		var errBuf, outBuf bytes.Buffer
+		cli := &CLI{Target: file, DryRun: true, Diff: true, Color: "always", homeDir: dir, checker: cctidy.OSPathChecker{}, w: &errBuf, out: &outBuf}
+		if err := cli.RunTidy(t.Context()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !strings.Contains(outBuf.String(), "\x1b[31m-") {
+			t.Errorf("expected red deletion line: %q", outBuf.String())
+		}
+	})
+
+	t.Run("unchanged file prints nothing", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		file := filepath.Join(dir, "settings.json")
+		os.WriteFile(file, []byte("{\n  \"a\": 1\n}\n"), 0o644)
+
+		var errBuf, outBuf bytes.Buffer
+		cli := &CLI{Target: file, Diff: true, homeDir: dir, checker: cctidy.OSPathChecker{}, w: &errBuf, out: &outBuf}
+		if err := cli.RunTidy(t.Context()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if outBuf.Len() != 0 {
+			t.Errorf("expected no diff output, got: %s", outBuf.String())
+		}
+	})
+}
+
+func TestJSONReport(t *testing.T) {
+	t.Parallel()
+
+	t.Run("reports changed target with swept entries and backup", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		deadPath := filepath.Join(dir, "gone-project")
+		input := `{"permissions": {"allow": ["Read(/` + deadPath + `)", "Write"]}}`
+		file := filepath.Join(dir, "settings.json")
+		os.WriteFile(file, []byte(input), 0o644)
+
+		var errBuf, outBuf bytes.Buffer
+		cli := &CLI{Target: file, Backup: true, Format: "json", Verbose: true, homeDir: dir, checker: cctidy.OSPathChecker{}, w: &errBuf, out: &outBuf,
+			backups: cctidy.NewBackupStore(filepath.Join(dir, "backups"), false, cctidy.RetentionPolicy{})}
+		if err := cli.RunTidy(t.Context()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if errBuf.Len() != 0 {
+			t.Errorf("text output should be suppressed in json mode: %s", errBuf.String())
+		}
+
+		var rep struct {
+			Mode    string `json:"mode"`
+			Targets []struct {
+				Path         string `json:"path"`
+				Status       string `json:"status"`
+				Changed      bool   `json:"changed"`
+				SizeBefore   int    `json:"sizeBefore"`
+				SizeAfter    int    `json:"sizeAfter"`
+				SHA256Before string `json:"sha256Before"`
+				SHA256After  string `json:"sha256After"`
+				Backup       string `json:"backup"`
+				Swept        []struct {
+					Category string `json:"category"`
+					Entry    string `json:"entry"`
+					Reason   string `json:"reason"`
+				} `json:"swept"`
+				Stats struct {
+					SweptAllow int `json:"sweptAllow"`
+				} `json:"stats"`
+			} `json:"targets"`
+		}
+		if err := json.Unmarshal(outBuf.Bytes(), &rep); err != nil {
+			t.Fatalf("invalid JSON output: %v\n%s", err, outBuf.String())
+		}
+		if rep.Mode != "write" {
+			t.Errorf("mode = %q, want write", rep.Mode)
+		}
+		if len(rep.Targets) != 1 {
+			t.Fatalf("targets len = %d, want 1", len(rep.Targets))
+		}
+		tr := rep.Targets[0]
+		if tr.Path != file || tr.Status != "changed" || !tr.Changed {
+			t.Errorf("unexpected target header: %+v", tr)
+		}
+		if tr.SizeBefore != len(input) {
+			t.Errorf("sizeBefore = %d, want %d", tr.SizeBefore, len(input))
+		}
+		written, _ := os.ReadFile(file)
+		if tr.SizeAfter != len(written) {
+			t.Errorf("sizeAfter = %d, want %d", tr.SizeAfter, len(written))
+		}
+		if tr.SHA256Before == "" || tr.SHA256Before == tr.SHA256After {
+			t.Errorf("unexpected hashes: before=%q after=%q", tr.SHA256Before, tr.SHA256After)
+		}
+		if tr.Backup == "" {
+			t.Error("backup path should be reported")
+		}
+		if len(tr.Swept) != 1 || tr.Swept[0].Entry != "Read(/"+deadPath+")" || tr.Swept[0].Reason != "path-missing" {
+			t.Errorf("unexpected swept entries: %+v", tr.Swept)
+		}
+		if tr.Stats.SweptAllow != 1 {
+			t.Errorf("stats.sweptAllow = %d, want 1", tr.Stats.SweptAllow)
+		}
+	})
+
+	t.Run("check mode reports skipped and unchanged targets", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		formatted := filepath.Join(dir, "settings.json")
+		os.WriteFile(formatted, []byte("{\n  \"a\": 1\n}\n"), 0o644)
+		missing := filepath.Join(dir, "missing.json")
+
+		var errBuf, outBuf bytes.Buffer
+		cli := &CLI{Check: true, Format: "json", homeDir: dir, checker: testutil.AllPathsExist{}, w: &errBuf, out: &outBuf}
+		targets := []targetFile{
+			{path: formatted, formatter: mustSettingsFormatter(t, testutil.AllPathsExist{}, "", nil)},
+			{path: missing, formatter: mustSettingsFormatter(t, testutil.AllPathsExist{}, "", nil)},
+		}
+		if err := cli.runTargets(t.Context(), targets); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		var rep struct {
+			Mode    string `json:"mode"`
+			Targets []struct {
+				Path   string `json:"path"`
+				Status string `json:"status"`
+			} `json:"targets"`
+		}
+		if err := json.Unmarshal(outBuf.Bytes(), &rep); err != nil {
+			t.Fatalf("invalid JSON output: %v\n%s", err, outBuf.String())
+		}
+		if rep.Mode != "check" {
+			t.Errorf("mode = %q, want check", rep.Mode)
+		}
+		if len(rep.Targets) != 2 {
+			t.Fatalf("targets len = %d, want 2", len(rep.Targets))
+		}
+		if rep.Targets[0].Status != "unchanged" {
+			t.Errorf("targets[0].status = %q, want unchanged", rep.Targets[0].Status)
+		}
+		if rep.Targets[1].Path != missing || rep.Targets[1].Status != "skipped" {
+			t.Errorf("targets[1] = %+v, want skipped %s", rep.Targets[1], missing)
+		}
+	})
+}
+
+func TestPatch(t *testing.T) {
+	t.Parallel()
+
+	setup := func(t *testing.T) (dir, file, deadPath string) {
+		t.Helper()
+		dir = t.TempDir()
+		deadPath = filepath.Join(dir, "gone-project")
+		input := "{\n  \"permissions\": {\n    \"allow\": [\n      \"Read(/" + deadPath + ")\",\n      \"Write\"\n    ]\n  }\n}\n"
+		file = filepath.Join(dir, "settings.json")
+		os.WriteFile(file, []byte(input), 0o644)
+		return dir, file, deadPath
+	}
+
+	t.Run("patch prints tested removals without writing", func(t *testing.T) {
+		t.Parallel()
+		dir, file, deadPath := setup(t)
+		before, _ := os.ReadFile(file)
+
+		var errBuf, outBuf bytes.Buffer
+		cli := &CLI{Target: file, Patch: true, homeDir: dir, checker: cctidy.OSPathChecker{}, w: &errBuf, out: &outBuf}
+		if err := cli.RunTidy(t.Context()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		after, _ := os.ReadFile(file)
+		if !bytes.Equal(before, after) {
+			t.Error("file was modified in patch mode")
+		}
+		var doc patchDocument
+		if err := json.Unmarshal(outBuf.Bytes(), &doc); err != nil {
+			t.Fatalf("invalid patch output: %v\n%s", err, outBuf.String())
+		}
+		if len(doc.Targets) != 1 || doc.Targets[0].Path != file {
+			t.Fatalf("targets = %+v, want one entry for %s", doc.Targets, file)
+		}
+		ops := doc.Targets[0].Patch
+		if len(ops) != 2 {
+			t.Fatalf("ops = %+v, want test and remove", ops)
+		}
+		if ops[0].Op != "test" || ops[0].Path != "/permissions/allow/0" || ops[0].Value != "Read(/"+deadPath+")" {
+			t.Errorf("ops[0] = %+v", ops[0])
+		}
+		if ops[1].Op != "remove" || ops[1].Path != "/permissions/allow/0" {
+			t.Errorf("ops[1] = %+v", ops[1])
+		}
+	})
+
+	t.Run("claude json projects use escaped pointers", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		alive := filepath.Join(dir, "alive")
+		os.Mkdir(alive, 0o755)
+		dead := filepath.Join(dir, "dead")
+		file := filepath.Join(dir, ".claude.json")
+		input := fmt.Sprintf("{\"projects\":{%q:{},%q:{}}}\n", alive, dead)
+		os.WriteFile(file, []byte(input), 0o644)
+
+		var errBuf, outBuf bytes.Buffer
+		cli := &CLI{Target: file, Patch: true, homeDir: dir, checker: cctidy.OSPathChecker{}, w: &errBuf, out: &outBuf}
+		if err := cli.RunTidy(t.Context()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		ptr := "/projects/" + strings.ReplaceAll(dead, "/", "~1")
+		if !strings.Contains(outBuf.String(), `"op": "remove",`+"\n"+`          "path": "`+ptr+`"`) {
+			t.Errorf("missing remove of %s:\n%s", ptr, outBuf.String())
+		}
+	})
+
+	t.Run("apply reproduces formatted output", func(t *testing.T) {
+		t.Parallel()
+		dir, file, _ := setup(t)
+
+		var errBuf, outBuf bytes.Buffer
+		cli := &CLI{Target: file, Patch: true, homeDir: dir, checker: cctidy.OSPathChecker{}, w: &errBuf, out: &outBuf}
+		if err := cli.RunTidy(t.Context()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		patchFile := filepath.Join(dir, "changes.json")
+		os.WriteFile(patchFile, outBuf.Bytes(), 0o644)
+
+		var wantBuf bytes.Buffer
+		want := &CLI{Target: file, DryRun: true, homeDir: dir, checker: cctidy.OSPathChecker{}, w: &wantBuf, out: &wantBuf}
+		targets, _ := want.resolveTargets(t.Context())
+		r, err := want.formatFile(t.Context(), targets[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		apply := &CLI{Apply: applyCmd{PatchFile: patchFile}, w: &errBuf, out: &errBuf}
+		if err := apply.RunApply(t.Context()); err != nil {
+			t.Fatalf("unexpected apply error: %v", err)
+		}
+		got, _ := os.ReadFile(file)
+		if !bytes.Equal(got, r.result.Data) {
+			t.Errorf("applied file mismatch:\ngot:\n%s\nwant:\n%s", got, r.result.Data)
+		}
+	})
+
+	t.Run("apply reads patch from stdin", func(t *testing.T) {
+		t.Parallel()
+		dir, file, _ := setup(t)
+
+		var errBuf, outBuf bytes.Buffer
+		cli := &CLI{Target: file, Patch: true, homeDir: dir, checker: cctidy.OSPathChecker{}, w: &errBuf, out: &outBuf}
+		if err := cli.RunTidy(t.Context()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		apply := &CLI{Apply: applyCmd{PatchFile: "-"}, in: &outBuf, w: &errBuf, out: &errBuf}
+		if err := apply.RunApply(t.Context()); err != nil {
+			t.Fatalf("unexpected apply error: %v", err)
+		}
+		got, _ := os.ReadFile(file)
+		if strings.Contains(string(got), "gone-project") {
+			t.Errorf("dead entry was not removed:\n%s", got)
+		}
+	})
+
+	t.Run("stale patch fails without writing", func(t *testing.T) {
+		t.Parallel()
+		dir, file, _ := setup(t)
+		other := filepath.Join(dir, "other.json")
+		os.WriteFile(other, []byte("{\"a\": 1}\n"), 0o644)
+
+		var errBuf, outBuf bytes.Buffer
+		cli := &CLI{Target: file, Patch: true, homeDir: dir, checker: cctidy.OSPathChecker{}, w: &errBuf, out: &outBuf}
+		if err := cli.RunTidy(t.Context()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		var doc patchDocument
+		json.Unmarshal(outBuf.Bytes(), &doc)
+		doc.Targets = append([]patchTarget{{Path: other, Patch: jsonpatch.Patch{
+			{Op: jsonpatch.OpRemove, Path: "/a"},
+		}}}, doc.Targets...)
+		data, _ := json.Marshal(doc)
+		patchFile := filepath.Join(dir, "changes.json")
+		os.WriteFile(patchFile, data, 0o644)
+
+		os.WriteFile(file, []byte("{\"permissions\": {\"allow\": [\"Write\"]}}\n"), 0o644)
+		edited, _ := os.ReadFile(file)
+		otherBefore, _ := os.ReadFile(other)
+
+		apply := &CLI{Apply: applyCmd{PatchFile: patchFile}, w: &errBuf, out: &errBuf}
+		err := apply.RunApply(t.Context())
+		if err == nil || !strings.Contains(err.Error(), "patch does not match "+file) {
+			t.Fatalf("expected mismatch error, got: %v", err)
+		}
+		if got, _ := os.ReadFile(file); !bytes.Equal(got, edited) {
+			t.Errorf("target was modified after failed apply:\n%s", got)
+		}
+		if got, _ := os.ReadFile(other); !bytes.Equal(got, otherBefore) {
+			t.Errorf("other target was written although a later patch failed:\n%s", got)
+		}
+	})
+}
+
+func TestSafetyLimits(t *testing.T) {
+	t.Parallel()
+
+	setup := func(t *testing.T) (dir, file string, cfg *cctidy.Config) {
+		t.Helper()
+		dir = t.TempDir()
+		alive := filepath.Join(dir, "alive")
+		os.Mkdir(alive, 0o755)
+		projects := map[string]any{alive: map[string]any{}}
+		for _, name := range []string{"gone-a", "gone-b", "gone-c", "gone-d", "gone-e", "gone-f"} {
+			projects[filepath.Join(dir, name)] = map[string]any{}
+		}
+		input, _ := json.Marshal(map[string]any{"projects": projects})
+		file = filepath.Join(dir, ".claude.json")
+		os.WriteFile(file, []byte(input), 0o644)
+		cfg = &cctidy.Config{}
+		cfg.Safety.Projects.MaxPercent = 50
+		return dir, file, cfg
+	}
+
+	t.Run("exceeded threshold blocks write", func(t *testing.T) {
+		t.Parallel()
+		dir, file, cfg := setup(t)
+		before, _ := os.ReadFile(file)
+
+		var errBuf, outBuf bytes.Buffer
+		cli := &CLI{Target: file, cfg: cfg, homeDir: dir, checker: cctidy.OSPathChecker{}, w: &errBuf, out: &outBuf}
+		err := cli.RunTidy(t.Context())
+		if !errors.Is(err, errLimitExceeded) {
+			t.Fatalf("expected errLimitExceeded, got: %v", err)
+		}
+		after, _ := os.ReadFile(file)
+		if !bytes.Equal(before, after) {
+			t.Error("file was written although threshold was exceeded")
+		}
+		if !strings.Contains(errBuf.String(), "projects: would remove 6 of 7 (limit max_percent 50%)") {
+			t.Errorf("missing threshold explanation: %s", errBuf.String())
+		}
+	})
+
+	t.Run("force overrides threshold", func(t *testing.T) {
+		t.Parallel()
+		dir, file, cfg := setup(t)
+
+		var errBuf, outBuf bytes.Buffer
+		cli := &CLI{Target: file, Force: true, cfg: cfg, homeDir: dir, checker: cctidy.OSPathChecker{}, w: &errBuf, out: &outBuf}
+		if err := cli.RunTidy(t.Context()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		after, _ := os.ReadFile(file)
+		if strings.Contains(string(after), "gone-a") {
+			t.Errorf("dead project not removed with --force:\n%s", after)
+		}
+	})
+
+	t.Run("json report marks target blocked", func(t *testing.T) {
+		t.Parallel()
+		dir, file, cfg := setup(t)
+
+		var errBuf, outBuf bytes.Buffer
+		cli := &CLI{Target: file, DryRun: true, Format: "json", cfg: cfg, homeDir: dir, checker: cctidy.OSPathChecker{}, w: &errBuf, out: &outBuf}
+		// --dry-run only reports the violation.
+		if err := cli.RunTidy(t.Context()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !strings.Contains(errBuf.String(), "safety threshold exceeded") {
+			t.Errorf("violation not reported:\n%s", errBuf.String())
+		}
+		var rep runReport
+		if err := json.Unmarshal(outBuf.Bytes(), &rep); err != nil {
+			t.Fatalf("invalid JSON: %v\n%s", err, outBuf.String())
+		}
+		if len(rep.Targets) != 1 || rep.Targets[0].Status != statusBlocked || len(rep.Targets[0].Violations) != 1 {
+			t.Errorf("unexpected report: %+v", rep.Targets)
+		}
+	})
+
+	t.Run("defaults allow removing a single stale entry", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		file := filepath.Join(dir, ".claude.json")
+		input := fmt.Sprintf("{\"githubRepoPaths\":{\"o/r\":[%q]}}\n", filepath.Join(dir, "gone"))
+		os.WriteFile(file, []byte(input), 0o644)
+		cfg := &cctidy.Config{Safety: cctidy.DefaultSafety}
+
+		var errBuf, outBuf bytes.Buffer
+		cli := &CLI{Target: file, cfg: cfg, homeDir: dir, checker: cctidy.OSPathChecker{}, w: &errBuf, out: &outBuf}
+		if err := cli.RunTidy(t.Context()); err != nil {
+			t.Fatalf("unexpected error: %v\n%s", err, errBuf.String())
+		}
+		after, _ := os.ReadFile(file)
+		if strings.Contains(string(after), "gone") {
+			t.Errorf("stale repo path not removed:\n%s", after)
+		}
+	})
+}
+
+func TestTrash(t *testing.T) {
+	t.Parallel()
+
+	setup := func(t *testing.T) (dir, file, deadPath string, cli *CLI) {
+		t.Helper()
+		dir = t.TempDir()
+		deadPath = filepath.Join(dir, "gone-project")
+		input := "{\n  \"permissions\": {\n    \"allow\": [\n      \"Read(/" + deadPath + ")\",\n      \"Write\"\n    ]\n  }\n}\n"
+		file = filepath.Join(dir, "settings.json")
+		os.WriteFile(file, []byte(input), 0o644)
+		cli = &CLI{
+			Target:  file,
+			homeDir: dir,
+			checker: cctidy.OSPathChecker{},
+			trash:   cctidy.NewTrashJournal(filepath.Join(dir, "state", "trash.jsonl")),
+			w:       &bytes.Buffer{},
+			out:     &bytes.Buffer{},
+		}
+		if err := cli.RunTidy(t.Context()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return dir, file, deadPath, cli
+	}
+
+	t.Run("sweep records removed entries", func(t *testing.T) {
+		t.Parallel()
+		_, file, deadPath, cli := setup(t)
+
+		items, err := cli.trash.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(items) != 1 {
+			t.Fatalf("items = %+v, want one", items)
+		}
+		it := items[0]
+		if it.File != file || it.Category != "allow" || it.Entry != "Read(/"+deadPath+")" || it.Reason != cctidy.ReasonPathMissing {
+			t.Errorf("unexpected item: %+v", it)
+		}
+	})
+
+	t.Run("dry run records nothing", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		file := filepath.Join(dir, "settings.json")
+		os.WriteFile(file, []byte(`{"permissions":{"allow":["Read(/`+filepath.Join(dir, "gone")+`)"]}}`), 0o644)
+		cli := &CLI{
+			Target: file, DryRun: true, homeDir: dir, checker: cctidy.OSPathChecker{},
+			trash: cctidy.NewTrashJournal(filepath.Join(dir, "trash.jsonl")),
+			w:     &bytes.Buffer{}, out: &bytes.Buffer{},
+		}
+		if err := cli.RunTidy(t.Context()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if items, _ := cli.trash.Load(); len(items) != 0 {
+			t.Errorf("items = %+v, want none", items)
+		}
+	})
+
+	t.Run("list shows items", func(t *testing.T) {
+		t.Parallel()
+		_, _, deadPath, cli := setup(t)
+
+		var outBuf bytes.Buffer
+		cli.out = &outBuf
+		if err := cli.RunTrashList(t.Context()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !strings.Contains(outBuf.String(), "Read(/"+deadPath+")") || !strings.Contains(outBuf.String(), "trashed") {
+			t.Errorf("unexpected list output:\n%s", outBuf.String())
+		}
+	})
+
+	t.Run("restore by id puts entry back", func(t *testing.T) {
+		t.Parallel()
+		_, file, deadPath, cli := setup(t)
+		items, _ := cli.trash.Load()
+
+		cli.Trash.Restore.IDs = []string{items[0].ID}
+		if err := cli.RunTrashRestore(t.Context()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		got, _ := os.ReadFile(file)
+		want := "{\n  \"permissions\": {\n    \"allow\": [\n      \"Read(/" + deadPath + ")\",\n      \"Write\"\n    ]\n  }\n}\n"
+		if string(got) != want {
+			t.Errorf("got:\n%s\nwant:\n%s", got, want)
+		}
+		items, _ = cli.trash.Load()
+		if items[0].Restored == nil {
+			t.Error("item not marked restored")
+		}
+	})
+
+	t.Run("restore since skips restored items", func(t *testing.T) {
+		t.Parallel()
+		_, _, _, cli := setup(t)
+		items, _ := cli.trash.Load()
+		cli.trash.MarkRestored([]string{items[0].ID}, time.Now())
+
+		var errBuf bytes.Buffer
+		cli.w = &errBuf
+		cli.Trash.Restore.Since = "1h"
+		if err := cli.RunTrashRestore(t.Context()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !strings.Contains(errBuf.String(), "no trash items to restore") {
+			t.Errorf("unexpected output: %s", errBuf.String())
+		}
+	})
+
+	t.Run("restore requires a selection", func(t *testing.T) {
+		t.Parallel()
+		_, _, _, cli := setup(t)
+		if err := cli.RunTrashRestore(t.Context()); err == nil {
+			t.Error("expected error")
+		}
+	})
+
+	t.Run("project restored with its settings", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		dead := filepath.Join(dir, "dead")
+		file := filepath.Join(dir, ".claude.json")
+		input := fmt.Sprintf("{\n  \"projects\": {\n    %q: {\n      \"history\": 3\n    }\n  }\n}\n", dead)
+		os.WriteFile(file, []byte(input), 0o644)
+		cli := &CLI{
+			Target: file, homeDir: dir, checker: cctidy.OSPathChecker{},
+			trash: cctidy.NewTrashJournal(filepath.Join(dir, "trash.jsonl")),
+			w:     &bytes.Buffer{}, out: &bytes.Buffer{},
+		}
+		if err := cli.RunTidy(t.Context()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		cli.Trash.Restore.Since = "1h"
+		if err := cli.RunTrashRestore(t.Context()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		got, _ := os.ReadFile(file)
+		if !strings.Contains(string(got), fmt.Sprintf("%q: {\n      \"history\": 3\n    }", dead)) {
+			t.Errorf("project not restored:\n%s", got)
+		}
+	})
+}
+
+func TestRestore(t *testing.T) {
+	t.Parallel()
+
+	setup := func(t *testing.T) (file, input string, cli *CLI) {
+		t.Helper()
+		dir := t.TempDir()
+		input = `{"permissions":{"allow":["Read(/` + filepath.Join(dir, "gone") + `)","Write"]}}`
+		file = filepath.Join(dir, "settings.json")
+		os.WriteFile(file, []byte(input), 0o644)
+		cfg := &cctidy.Config{}
+		cfg.Backup.Auto = true
+		cli = &CLI{
+			Target:  file,
+			cfg:     cfg,
+			homeDir: dir,
+			checker: cctidy.OSPathChecker{},
+			backups: cctidy.NewBackupStore(filepath.Join(dir, "backups"), true, cctidy.RetentionPolicy{}),
+			w:       &bytes.Buffer{},
+			out:     &bytes.Buffer{},
+		}
+		return file, input, cli
+	}
+
+	t.Run("destructive run backs up automatically", func(t *testing.T) {
+		t.Parallel()
+		file, input, cli := setup(t)
+		if err := cli.RunTidy(t.Context()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		backups, _ := cli.backups.List(file)
+		if len(backups) != 1 {
+			t.Fatalf("backups = %+v, want one", backups)
+		}
+		data, _ := cli.backups.Read(backups[0])
+		if string(data) != input {
+			t.Errorf("backup = %q, want %q", data, input)
+		}
+	})
+
+	t.Run("formatting-only run takes no backup", func(t *testing.T) {
+		t.Parallel()
+		file, _, cli := setup(t)
+		os.WriteFile(file, []byte(`{"permissions":{"allow":["Write","Edit"]}}`), 0o644)
+		if err := cli.RunTidy(t.Context()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if backups, _ := cli.backups.List(file); len(backups) != 0 {
+			t.Errorf("backups = %+v, want none", backups)
+		}
+	})
+
+	t.Run("restore puts latest backup back", func(t *testing.T) {
+		t.Parallel()
+		file, input, cli := setup(t)
+		if err := cli.RunTidy(t.Context()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		swept, _ := os.ReadFile(file)
+
+		cli.Restore.File = file
+		if err := cli.RunRestore(t.Context()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		got, _ := os.ReadFile(file)
+		if string(got) != input {
+			t.Errorf("restored = %q, want %q", got, input)
+		}
+		info, _ := os.Stat(file)
+		if info.Mode().Perm() != 0o644 {
+			t.Errorf("perm = %o, want 644", info.Mode().Perm())
+		}
+		backups, _ := cli.backups.List(file)
+		if len(backups) != 2 {
+			t.Fatalf("backups = %+v, want original and pre-restore", backups)
+		}
+		if data, _ := cli.backups.Read(backups[1]); !bytes.Equal(data, swept) {
+			t.Errorf("pre-restore backup = %q, want %q", data, swept)
+		}
+	})
+
+	t.Run("restore at time before any backup fails", func(t *testing.T) {
+		t.Parallel()
+		file, _, cli := setup(t)
+		if err := cli.RunTidy(t.Context()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		cli.Restore.File = file
+		cli.Restore.At = "2000-01-01T00:00:00Z"
+		if err := cli.RunRestore(t.Context()); err == nil || !strings.Contains(err.Error(), "no backup") {
+			t.Errorf("expected no backup error, got: %v", err)
+		}
+	})
+
+	t.Run("list shows backups", func(t *testing.T) {
+		t.Parallel()
+		file, _, cli := setup(t)
+		if err := cli.RunTidy(t.Context()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		var outBuf bytes.Buffer
+		cli.out = &outBuf
+		if err := cli.RunRestore(t.Context()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !strings.Contains(outBuf.String(), file) || !strings.Contains(outBuf.String(), ".json.gz") {
+			t.Errorf("unexpected list output:\n%s", outBuf.String())
+		}
+	})
+}
+
 func TestIntegrationProjectConfig(t *testing.T) {
 	t.Parallel()
 
@@ -1495,12 +2396,12 @@ func TestIntegrationProjectConfig(t *testing.T) {
 			Target:      file,
 			Verbose:     true,
 			homeDir:     dir,
-			checker:     &osPathChecker{},
+			checker:     cctidy.OSPathChecker{},
 			cfg:         merged,
 			projectRoot: projectDir,
 			w:           &buf,
 		}
-		if err := cli.Run(t.Context()); err != nil {
+		if err := cli.RunTidy(t.Context()); err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
 
@@ -1546,12 +2447,12 @@ func TestIntegrationProjectConfig(t *testing.T) {
 			Target:      file,
 			Verbose:     true,
 			homeDir:     dir,
-			checker:     &osPathChecker{},
+			checker:     cctidy.OSPathChecker{},
 			cfg:         merged,
 			projectRoot: projectDir,
 			w:           &buf,
 		}
-		if err := cli.Run(t.Context()); err != nil {
+		if err := cli.RunTidy(t.Context()); err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
 
@@ -1619,12 +2520,12 @@ exclude_commands = ["cp"]
 			Target:      file,
 			Verbose:     true,
 			homeDir:     dir,
-			checker:     &osPathChecker{},
+			checker:     cctidy.OSPathChecker{},
 			cfg:         merged,
 			projectRoot: filepath.Join(dir, "project"),
 			w:           &buf,
 		}
-		if err := cli.Run(t.Context()); err != nil {
+		if err := cli.RunTidy(t.Context()); err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
 
@@ -1692,12 +2593,12 @@ exclude_paths = ["vendor/"]
 			Target:      file,
 			Verbose:     true,
 			homeDir:     dir,
-			checker:     &osPathChecker{},
+			checker:     cctidy.OSPathChecker{},
 			cfg:         merged,
 			projectRoot: projectDir,
 			w:           &buf,
 		}
-		if err := cli.Run(t.Context()); err != nil {
+		if err := cli.RunTidy(t.Context()); err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
 
@@ -1741,12 +2642,12 @@ exclude_paths = ["vendor/"]
 			Target:      file,
 			Verbose:     true,
 			homeDir:     dir,
-			checker:     &osPathChecker{},
+			checker:     cctidy.OSPathChecker{},
 			cfg:         merged,
 			projectRoot: filepath.Join(dir, "project"),
 			w:           &buf,
 		}
-		if err := cli.Run(t.Context()); err != nil {
+		if err := cli.RunTidy(t.Context()); err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
 
@@ -1813,12 +2714,12 @@ remove_commands = ["npm", "pip"]
 			Target:      file,
 			Verbose:     true,
 			homeDir:     dir,
-			checker:     &osPathChecker{},
+			checker:     cctidy.OSPathChecker{},
 			cfg:         cfg,
 			projectRoot: projectDir,
 			w:           &buf,
 		}
-		if err := cli.Run(t.Context()); err != nil {
+		if err := cli.RunTidy(t.Context()); err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
 
@@ -1895,11 +2796,11 @@ remove_commands = ["npm"]
 			Target:  file,
 			Verbose: true,
 			homeDir: dir,
-			checker: &osPathChecker{},
+			checker: cctidy.OSPathChecker{},
 			cfg:     cfg,
 			w:       &buf,
 		}
-		if err := cli.Run(t.Context()); err != nil {
+		if err := cli.RunTidy(t.Context()); err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
 
@@ -1944,11 +2845,11 @@ remove_commands = ["npm"]
 			Target:  file,
 			Verbose: true,
 			homeDir: dir,
-			checker: &osPathChecker{},
+			checker: cctidy.OSPathChecker{},
 			cfg:     cfg,
 			w:       &buf,
 		}
-		if err := cli.Run(t.Context()); err != nil {
+		if err := cli.RunTidy(t.Context()); err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
 
@@ -1997,12 +2898,12 @@ func TestIntegrationBashSafeTierViaConfig(t *testing.T) {
 		Target:      file,
 		Verbose:     true,
 		homeDir:     dir,
-		checker:     &osPathChecker{},
+		checker:     cctidy.OSPathChecker{},
 		cfg:         merged,
 		projectRoot: projectDir,
 		w:           &buf,
 	}
-	if err := cli.Run(t.Context()); err != nil {
+	if err := cli.RunTidy(t.Context()); err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
@@ -2059,11 +2960,11 @@ func TestIntegrationMCPSweep(t *testing.T) {
 			Target:      file,
 			Verbose:     true,
 			homeDir:     dir,
-			checker:     &osPathChecker{},
+			checker:     cctidy.OSPathChecker{},
 			projectRoot: projectDir,
 			w:           &buf,
 		}
-		if err := cli.Run(t.Context()); err != nil {
+		if err := cli.RunTidy(t.Context()); err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
 
@@ -2134,11 +3035,11 @@ func TestIntegrationMCPSweep(t *testing.T) {
 			Target:      file,
 			Verbose:     true,
 			homeDir:     dir,
-			checker:     &osPathChecker{},
+			checker:     cctidy.OSPathChecker{},
 			projectRoot: projectDir,
 			w:           &buf,
 		}
-		if err := cli.Run(t.Context()); err != nil {
+		if err := cli.RunTidy(t.Context()); err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
 
@@ -2192,11 +3093,11 @@ func TestIntegrationMCPSweep(t *testing.T) {
 			Target:      file,
 			Verbose:     true,
 			homeDir:     dir,
-			checker:     &osPathChecker{},
+			checker:     cctidy.OSPathChecker{},
 			projectRoot: projectDir,
 			w:           &buf,
 		}
-		if err := cli.Run(t.Context()); err != nil {
+		if err := cli.RunTidy(t.Context()); err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
 
@@ -2252,11 +3153,11 @@ func TestIntegrationMCPSweep(t *testing.T) {
 			Target:      file,
 			Verbose:     true,
 			homeDir:     dir,
-			checker:     &osPathChecker{},
+			checker:     cctidy.OSPathChecker{},
 			projectRoot: projectDir,
 			w:           &buf,
 		}
-		if err := cli.Run(t.Context()); err != nil {
+		if err := cli.RunTidy(t.Context()); err != nil {
 			t.Fatalf("unexpected error: %v", err)
 		}
 
@@ -2337,8 +3238,8 @@ func TestIntegrationSkillSweepProjectLevel(t *testing.T) {
 	os.WriteFile(file, []byte(input), 0o644)
 
 	var buf bytes.Buffer
-	cli := &CLI{Target: file, Verbose: true, homeDir: dir, checker: &osPathChecker{}, w: &buf}
-	if err := cli.Run(t.Context()); err != nil {
+	cli := &CLI{Target: file, Verbose: true, homeDir: dir, checker: cctidy.OSPathChecker{}, w: &buf}
+	if err := cli.RunTidy(t.Context()); err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
@@ -2373,9 +3274,9 @@ func TestIntegrationSkillSweepProjectLevel(t *testing.T) {
 	if strings.Contains(got, `"Skill(file-name)"`) {
 		t.Error("Skill(file-name) should be swept when frontmatter name differs")
 	}
-	// home-only skill should be swept in project-level settings
-	if strings.Contains(got, `"Skill(home-skill)"`) {
-		t.Error("home-only Skill(home-skill) was not swept from project settings")
+	// user-scope skill should be kept in project-level settings
+	if !strings.Contains(got, `"Skill(home-skill)"`) {
+		t.Error("user-scope Skill(home-skill) was removed from project settings")
 	}
 	// plugin skill should be kept
 	if !strings.Contains(got, `"Skill(plugin:my-skill)"`) {
@@ -2439,8 +3340,8 @@ func TestIntegrationSkillSweepUserLevel(t *testing.T) {
 	os.WriteFile(file, []byte(input), 0o644)
 
 	var buf bytes.Buffer
-	cli := &CLI{Target: file, Verbose: true, homeDir: dir, checker: &osPathChecker{}, w: &buf}
-	if err := cli.Run(t.Context()); err != nil {
+	cli := &CLI{Target: file, Verbose: true, homeDir: dir, checker: cctidy.OSPathChecker{}, w: &buf}
+	if err := cli.RunTidy(t.Context()); err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
@@ -2472,3 +3373,274 @@ func TestIntegrationSkillSweepUserLevel(t *testing.T) {
 		t.Error("Skill(fm-dir) should be swept from user settings")
 	}
 }
+
+func TestAllProjects(t *testing.T) {
+	t.Parallel()
+
+	t.Run("sweeps settings of every existing project", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		deadPath := filepath.Join(dir, "dead-repo")
+		cwdProject := filepath.Join(dir, "cwd-project")
+		listed := filepath.Join(dir, "listed")
+		gone := filepath.Join(dir, "gone")
+		os.MkdirAll(filepath.Join(cwdProject, ".claude"), 0o755)
+
+		claudeDir := filepath.Join(listed, ".claude")
+		os.MkdirAll(claudeDir, 0o755)
+		os.WriteFile(filepath.Join(claudeDir, "cctidy.toml"),
+			[]byte("[permission.bash]\nenabled = true\n"), 0o644)
+		settings := filepath.Join(claudeDir, "settings.json")
+		os.WriteFile(settings, []byte(`{
+  "permissions": {
+    "allow": [
+      "Bash(git -C `+deadPath+` status)",
+      "Read(//`+deadPath+`/**)",
+      "mcp__local__tool"
+    ]
+  }
+}`), 0o644)
+		os.WriteFile(filepath.Join(listed, ".mcp.json"),
+			[]byte(`{"mcpServers": {"local": {}}}`), 0o644)
+		os.WriteFile(filepath.Join(dir, ".claude.json"), []byte(`{
+  "projects": {
+    "`+listed+`": {},
+    "`+gone+`": {}
+  }
+}`), 0o644)
+
+		var errBuf, outBuf bytes.Buffer
+		cli := &CLI{
+			AllProjects: true,
+			Format:      "json",
+			homeDir:     dir,
+			projectRoot: cwdProject,
+			checker:     cctidy.OSPathChecker{},
+			w:           &errBuf,
+			out:         &outBuf,
+		}
+		if err := cli.RunTidy(t.Context()); err != nil {
+			t.Fatalf("unexpected error: %v\n%s", err, errBuf.String())
+		}
+
+		data, _ := os.ReadFile(settings)
+		got := string(data)
+		if strings.Contains(got, "Bash(git -C") {
+			t.Error("bash entry should be swept by the listed project's config")
+		}
+		if !strings.Contains(got, "mcp__local__tool") {
+			t.Error("mcp entry should be kept by the listed project's .mcp.json")
+		}
+
+		var rep struct {
+			Targets []struct {
+				Path    string `json:"path"`
+				Project string `json:"project"`
+			} `json:"targets"`
+		}
+		if err := json.Unmarshal(outBuf.Bytes(), &rep); err != nil {
+			t.Fatalf("invalid JSON output: %v\n%s", err, outBuf.String())
+		}
+		projects := map[string]string{}
+		for _, tr := range rep.Targets {
+			projects[tr.Path] = tr.Project
+		}
+		if p := projects[settings]; p != listed {
+			t.Errorf("project of %s = %q, want %q", settings, p, listed)
+		}
+		if p := projects[filepath.Join(cwdProject, ".claude", "settings.json")]; p != cwdProject {
+			t.Errorf("current project should be included, got %q", p)
+		}
+		for path := range projects {
+			if strings.HasPrefix(path, gone) {
+				t.Errorf("missing project should not be targeted: %s", path)
+			}
+		}
+		if p := projects[filepath.Join(dir, ".claude.json")]; p != "" {
+			t.Errorf("user-level target should have no project, got %q", p)
+		}
+	})
+
+	t.Run("groups verbose output by project", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		a := filepath.Join(dir, "a")
+		b := filepath.Join(dir, "b")
+		for _, p := range []string{a, b} {
+			os.MkdirAll(filepath.Join(p, ".claude"), 0o755)
+			os.WriteFile(filepath.Join(p, ".claude", "settings.json"), []byte(`{"z": 1, "a": 2}`), 0o644)
+		}
+		os.WriteFile(filepath.Join(dir, ".claude.json"),
+			[]byte(`{"projects": {"`+b+`": {}, "`+a+`": {}}}`), 0o644)
+
+		var buf bytes.Buffer
+		cli := &CLI{AllProjects: true, Verbose: true, homeDir: dir, projectRoot: a, checker: cctidy.OSPathChecker{}, w: &buf}
+		if err := cli.RunTidy(t.Context()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		output := buf.String()
+		ia := strings.Index(output, "== Project: "+a+" ==")
+		ib := strings.Index(output, "== Project: "+b+" ==")
+		if ia < 0 || ib < 0 || ia > ib {
+			t.Errorf("projects should be printed as sorted groups:\n%s", output)
+		}
+		if strings.Count(output, "== Project: "+a+" ==") != 1 {
+			t.Errorf("project header should be printed once:\n%s", output)
+		}
+	})
+
+	t.Run("home directory is not a project", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		os.MkdirAll(filepath.Join(dir, ".claude"), 0o755)
+		os.WriteFile(filepath.Join(dir, ".claude", "settings.json"),
+			[]byte(`{"permissions": {"allow": ["Edit(package.json)"]}}`), 0o644)
+		os.WriteFile(filepath.Join(dir, ".claude.json"),
+			[]byte(`{"projects": {"`+dir+`": {}}}`), 0o644)
+
+		var errBuf, outBuf bytes.Buffer
+		cli := &CLI{AllProjects: true, Format: "json", homeDir: dir, projectRoot: dir, checker: cctidy.OSPathChecker{}, w: &errBuf, out: &outBuf}
+		if err := cli.RunTidy(t.Context()); err != nil {
+			t.Fatalf("unexpected error: %v\n%s", err, errBuf.String())
+		}
+		var rep struct {
+			Targets []struct {
+				Path string `json:"path"`
+			} `json:"targets"`
+		}
+		if err := json.Unmarshal(outBuf.Bytes(), &rep); err != nil {
+			t.Fatalf("invalid JSON output: %v\n%s", err, outBuf.String())
+		}
+		settings := filepath.Join(dir, ".claude", "settings.json")
+		n := 0
+		for _, tr := range rep.Targets {
+			if tr.Path == settings {
+				n++
+			}
+		}
+		if n != 1 {
+			t.Errorf("%s targeted %d times, want 1", settings, n)
+		}
+		data, _ := os.ReadFile(settings)
+		if !strings.Contains(string(data), "Edit(package.json)") {
+			t.Errorf("user-level entry was swept by a project-level sweeper:\n%s", data)
+		}
+	})
+
+	t.Run("aggregates unknown project warnings", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		a := filepath.Join(dir, "a")
+		b := filepath.Join(dir, "b")
+		os.WriteFile(filepath.Join(dir, ".claude.json"),
+			[]byte(`{"projects": {"`+a+`": {}, "`+b+`": {}}}`), 0o644)
+		checker := testutil.UnknownFor(errors.New("stale mount"), a, b)
+
+		var buf bytes.Buffer
+		cli := &CLI{AllProjects: true, homeDir: dir, projectRoot: dir, checker: checker, w: &buf, out: io.Discard}
+		if err := cli.RunTidy(t.Context()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		output := buf.String()
+		if strings.Count(output, "skipping") != 1 || !strings.Contains(output, "skipping 2 projects") {
+			t.Errorf("want one aggregated warning:\n%s", output)
+		}
+	})
+}
+
+func TestJobs(t *testing.T) {
+	t.Parallel()
+
+	setup := func(t *testing.T) (string, []targetFile) {
+		t.Helper()
+		dir := t.TempDir()
+		var targets []targetFile
+		for i := range 8 {
+			file := filepath.Join(dir, fmt.Sprintf("settings-%d.json", i))
+			if i%3 != 2 {
+				os.WriteFile(file, []byte(`{"permissions": {"allow": ["Read(//nonexistent-cctidy/gone)", "Write"]}}`), 0o644)
+			}
+			targets = append(targets, targetFile{
+				path:      file,
+				formatter: mustSettingsFormatter(t, cctidy.OSPathChecker{}, dir, nil, cctidy.WithJobs(4)),
+			})
+		}
+		return dir, targets
+	}
+
+	run := func(t *testing.T, jobs int, format string) string {
+		t.Helper()
+		dir, targets := setup(t)
+		var buf bytes.Buffer
+		cli := &CLI{DryRun: true, Verbose: true, Format: format, Jobs: jobs, homeDir: dir, checker: cctidy.OSPathChecker{}, w: &buf, out: &buf}
+		if err := cli.runTargets(t.Context(), targets); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return strings.ReplaceAll(buf.String(), dir, "<dir>")
+	}
+
+	for _, format := range []string{"text", "json"} {
+		t.Run("output matches sequential run in "+format, func(t *testing.T) {
+			t.Parallel()
+			want := run(t, 1, format)
+			got := run(t, 4, format)
+			if got != want {
+				t.Errorf("output mismatch:\n--- jobs=1\n%s\n--- jobs=4\n%s", want, got)
+			}
+		})
+	}
+
+	t.Run("fatal error still reports finished targets", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		ok := filepath.Join(dir, "ok.json")
+		broken := filepath.Join(dir, "broken.json")
+		os.WriteFile(ok, []byte(`{"permissions": {"allow": ["Read(//nonexistent-cctidy/gone)", "Write"]}}`), 0o644)
+		os.WriteFile(broken, []byte(`{"permissions": `), 0o644)
+		var targets []targetFile
+		for _, file := range []string{ok, broken} {
+			targets = append(targets, targetFile{
+				path:      file,
+				formatter: mustSettingsFormatter(t, cctidy.OSPathChecker{}, dir, nil),
+			})
+		}
+		var buf bytes.Buffer
+		cli := &CLI{Format: "json", Jobs: 2, homeDir: dir, checker: cctidy.OSPathChecker{}, w: io.Discard, out: &buf}
+		err := cli.runTargets(t.Context(), targets)
+		if err == nil || !strings.Contains(err.Error(), broken) {
+			t.Fatalf("err = %v, want error for %s", err, broken)
+		}
+		var rep struct {
+			Targets []struct {
+				Path   string `json:"path"`
+				Status string `json:"status"`
+			} `json:"targets"`
+		}
+		if err := json.Unmarshal(buf.Bytes(), &rep); err != nil {
+			t.Fatalf("invalid JSON output: %v\n%s", err, buf.String())
+		}
+		if len(rep.Targets) != 1 || rep.Targets[0].Path != ok || rep.Targets[0].Status != statusChanged {
+			t.Errorf("targets = %+v, want only %s as changed", rep.Targets, ok)
+		}
+		data, _ := os.ReadFile(ok)
+		if strings.Contains(string(data), "gone") {
+			t.Error("finished target should have been written")
+		}
+	})
+
+	t.Run("canceled context stops the run", func(t *testing.T) {
+		t.Parallel()
+		dir, targets := setup(t)
+		ctx, cancel := context.WithCancel(t.Context())
+		cancel()
+		var buf bytes.Buffer
+		cli := &CLI{Jobs: 4, homeDir: dir, checker: cctidy.OSPathChecker{}, w: &buf}
+		if err := cli.runTargets(ctx, targets); !errors.Is(err, context.Canceled) {
+			t.Fatalf("err = %v, want context.Canceled", err)
+		}
+		data, _ := os.ReadFile(targets[0].path)
+		if !strings.Contains(string(data), "gone") {
+			t.Error("file should not be written after cancellation")
+		}
+	})
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// errFileChanged is returned when a file was modified by another
+// writer between reading and replacing it.
+var errFileChanged = errors.New("file changed while it was being processed")
+
+// maxUpdateAttempts bounds how often updateFile re-reads a file that
+// keeps changing underneath it.
+const maxUpdateAttempts = 3
+
+// lockTimeout is how long updateFile waits for another cctidy
+// process to release a file.
+const lockTimeout = 10 * time.Second
+
+// lockPath returns the lock file for path in dir, named after the
+// file and a hash of its absolute path so that lock files are not
+// left next to the targets. Symlinks are resolved so that every
+// alias of a file shares one lock.
+func lockPath(dir, path string) string {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	sum := sha256.Sum256([]byte(path))
+	return filepath.Join(dir, fmt.Sprintf("%s.%x.lock", filepath.Base(path), sum[:8]))
+}
+
+// lock takes the advisory lock for path in c.lockDir, shared with
+// other cctidy processes, and returns the function releasing it.
+// An empty lockDir disables locking.
+func (c *CLI) lock(ctx context.Context, path string) (func(), error) {
+	if c.lockDir == "" {
+		return func() {}, nil
+	}
+	if err := os.MkdirAll(c.lockDir, 0o700); err != nil {
+		return nil, fmt.Errorf("locking %s: %w", path, err)
+	}
+	unlock, err := lockFile(ctx, lockPath(c.lockDir, path), lockTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("locking %s: %w", path, err)
+	}
+	return unlock, nil
+}
+
+// fileSnapshot identifies the content of a file at the time it was
+// read.
+type fileSnapshot struct {
+	size    int64
+	modTime time.Time
+	sum     [sha256.Size]byte
+}
+
+func newSnapshot(info os.FileInfo, data []byte) fileSnapshot {
+	return fileSnapshot{size: info.Size(), modTime: info.ModTime(), sum: sha256.Sum256(data)}
+}
+
+// unchanged reports whether path still matches the snapshot.
+// Size and mtime are compared first; the content hash catches
+// rewrites within the filesystem's timestamp granularity.
+func (s fileSnapshot) unchanged(path string) (bool, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false, err
+	}
+	if info.Size() != s.size || !info.ModTime().Equal(s.modTime) {
+		return false, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	return sha256.Sum256(data) == s.sum, nil
+}
+
+// updateFile replaces the content of path with the output of fn.
+// It holds an advisory lock in c.lockDir, shared with other cctidy
+// processes, for the whole update; an empty lockDir disables it. If
+// another writer (e.g. Claude Code) changes the file between the
+// read and the rename, fn is run again on the new content, up to
+// maxUpdateAttempts times. fn may return nil to leave the file
+// untouched. beforeWrite, if not nil, is called once with the
+// content being replaced, after the last change check and right
+// before the rename, so that e.g. a backup is taken only for the
+// content actually overwritten.
+func (c *CLI) updateFile(ctx context.Context, path string, fn func(data []byte) ([]byte, error), beforeWrite func(data []byte) error) error {
+	// Missing files are reported as is, without creating a lock file.
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+	unlock, err := c.lock(ctx, path)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	for attempt := 1; ; attempt++ {
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", path, err)
+		}
+		out, err := fn(data)
+		if err != nil || out == nil {
+			return err
+		}
+		snap := newSnapshot(info, data)
+		var beforeRename func() error
+		if beforeWrite != nil {
+			beforeRename = func() error { return beforeWrite(data) }
+		}
+		err = writeFileIf(path, out, info.Mode().Perm(), &snap, beforeRename)
+		if !errors.Is(err, errFileChanged) {
+			if err != nil {
+				return fmt.Errorf("writing %s: %w", path, err)
+			}
+			return nil
+		}
+		if attempt == maxUpdateAttempts {
+			return fmt.Errorf("writing %s: %w (gave up after %d attempts)", path, err, attempt)
+		}
+		if c.Verbose {
+			fmt.Fprintf(c.w, "%s: changed by another process, retrying\n", path)
+		}
+	}
+}
@@ -0,0 +1,14 @@
+//go:build !unix
+
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// lockFile is a no-op on platforms without flock. The snapshot
+// comparison in writeFileIf still detects concurrent writers.
+func lockFile(context.Context, string, time.Duration) (func(), error) {
+	return func() {}, nil
+}
@@ -2,38 +2,85 @@ package main
 
 import (
 	"bytes"
+	"cmp"
 	"context"
 	"errors"
 	"fmt"
 	"io"
+	"maps"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"slices"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/708u/cctidy"
+	"github.com/708u/cctidy/internal/diff"
+	"github.com/708u/cctidy/internal/pool"
 	"github.com/708u/cctidy/internal/set"
 	"github.com/alecthomas/kong"
 )
 
-var errUnformatted = errors.New("unformatted files detected")
+var (
+	errUnformatted   = errors.New("unformatted files detected")
+	errLimitExceeded = errors.New("safety threshold exceeded; rerun with --force to write anyway")
+)
+
+// limitError reports that formatting a file would exceed one or
+// more safety thresholds. The file is left untouched.
+type limitError struct {
+	path       string
+	violations []cctidy.LimitViolation
+}
+
+func (e *limitError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: refusing to write, safety threshold exceeded:", e.path)
+	for _, v := range e.violations {
+		fmt.Fprintf(&b, "\n  %s", v)
+	}
+	return b.String()
+}
 
 type CLI struct {
-	Target  string           `help:"Path to a specific file to format." short:"t" name:"target"`
-	Backup  bool             `help:"Create backup before writing."`
-	DryRun  bool             `help:"Show changes without writing." name:"dry-run"`
-	Check   bool             `help:"Exit with 1 if any file needs formatting."`
-	Unsafe  bool             `help:"Enable unsafe sweepers (e.g. Bash)." name:"unsafe"`
-	Config  string           `help:"Path to config file." name:"config"`
-	Verbose bool             `help:"Show formatting details." short:"v"`
-	Version kong.VersionFlag `help:"Print version."`
+	Target      string           `help:"Path to a specific file to format." short:"t" name:"target"`
+	Backup      bool             `help:"Create backup before writing."`
+	DryRun      bool             `help:"Show changes without writing." name:"dry-run"`
+	Check       bool             `help:"Exit with 1 if any file needs formatting."`
+	Diff        bool             `help:"Print a unified diff of changes."`
+	Color       string           `help:"Colorize diff output (auto, always, never)." enum:"auto,always,never" default:"auto"`
+	Format      string           `help:"Output format for results (text, json)." enum:"text,json" default:"text"`
+	Patch       bool             `help:"Print an RFC 6902 JSON Patch of changes without writing."`
+	Force       bool             `help:"Write even when a safety threshold is exceeded."`
+	AllProjects bool             `help:"Also tidy the settings of every project listed in ~/.claude.json." name:"all-projects"`
+	Jobs        int              `help:"Number of files and path checks processed in parallel." short:"j" default:"1"`
+	Unsafe      bool             `help:"Enable unsafe sweepers (e.g. Bash)." name:"unsafe"`
+	Config      string           `help:"Path to config file." name:"config"`
+	Verbose     bool             `help:"Show formatting details." short:"v"`
+	Version     kong.VersionFlag `help:"Print version."`
+
+	Tidy    tidyCmd    `cmd:"" default:"1" hidden:"" help:"Format and sweep target files."`
+	Apply   applyCmd   `cmd:"" help:"Apply a JSON Patch saved from --patch."`
+	Trash   trashCmd   `cmd:"" help:"List or restore removed entries."`
+	Restore restoreCmd `cmd:"" help:"List backups or restore a file from one."`
 
 	checker     cctidy.PathChecker
+	mounts      *cctidy.MountAwareChecker
 	cfg         *cctidy.Config
+	globalCfg   *cctidy.Config
 	homeDir     string
 	projectRoot string
+	graceState  *cctidy.GraceState
+	plugins     *[]cctidy.InstalledPlugin
+	pluginCfg   *cctidy.PluginSettingsSweeper
+	trash       *cctidy.TrashJournal
+	backups     *cctidy.BackupStore
+	lockDir     string
 	w           io.Writer
+	out         io.Writer
+	in          io.Reader
 }
 
 type Formatter interface {
@@ -47,6 +94,9 @@ type Sweeper interface {
 type targetFile struct {
 	path      string
 	formatter Formatter
+	// project is the project root the file belongs to in
+	// --all-projects mode, used to group results.
+	project string
 }
 
 type fileResult struct {
@@ -73,11 +123,15 @@ func run() int {
 	}
 
 	cli := CLI{
-		checker: &osPathChecker{},
+		checker: cctidy.OSPathChecker{},
 		homeDir: home,
+		trash:   cctidy.NewTrashJournal(cctidy.DefaultTrashPath(home)),
+		lockDir: filepath.Join(cctidy.StateDir(home), "locks"),
 		w:       os.Stderr,
+		out:     os.Stdout,
+		in:      os.Stdin,
 	}
-	kong.Parse(&cli,
+	kctx := kong.Parse(&cli,
 		kong.Vars{"version": versionString()},
 	)
 
@@ -95,68 +149,182 @@ func run() int {
 		fmt.Fprintf(os.Stderr, "cctidy: %v\n", err)
 		return 1
 	}
+	cli.globalCfg = cfg
 	cli.cfg = cctidy.MergeConfig(cfg, projectCfg, cli.projectRoot)
+	cli.backups = newBackupStore(cli.cfg.Backup, home)
+	// The timeout wraps the mount checker so that its mount point
+	// probe is bounded along with the existence check.
+	cli.mounts = cctidy.NewMountAwareChecker(cli.checker, cli.cfg.Paths.VolatileRoots, cctidy.LoadMountTable())
+	cli.checker = cctidy.NewTimeoutChecker(cli.mounts, cli.cfg.Paths.CheckTimeout)
+	now := time.Now()
+	if policy := cli.cfg.Paths.GracePolicy(); policy.Enabled() {
+		state, err := cctidy.LoadGraceState(cctidy.DefaultGraceStatePath(home))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "cctidy: warning: %v; starting with empty grace state\n", err)
+		}
+		cli.checker = cctidy.NewGraceChecker(cli.checker, state, policy, now)
+		cli.graceState = state
+	}
+	cli.checker = cctidy.NewCachingChecker(cli.checker)
 
 	if cli.Check && (cli.Backup || cli.DryRun) {
 		fmt.Fprintf(os.Stderr, "cctidy: --check cannot be combined with --backup or --dry-run\n")
 		return 2
 	}
+	if cli.Diff && cli.jsonOutput() {
+		fmt.Fprintf(os.Stderr, "cctidy: --diff cannot be combined with --format json\n")
+		return 2
+	}
+	if cli.Patch && (cli.Check || cli.Diff || cli.jsonOutput()) {
+		fmt.Fprintf(os.Stderr, "cctidy: --patch cannot be combined with --check, --diff or --format json\n")
+		return 2
+	}
+	if cli.Jobs < 1 {
+		fmt.Fprintf(os.Stderr, "cctidy: --jobs must be at least 1\n")
+		return 2
+	}
+	if cli.AllProjects && cli.Target != "" {
+		fmt.Fprintf(os.Stderr, "cctidy: --all-projects cannot be combined with --target\n")
+		return 2
+	}
 
-	if err := cli.Run(ctx); err != nil {
+	kctx.BindTo(ctx, (*context.Context)(nil))
+	if err := kctx.Run(); err != nil {
 		if errors.Is(err, errUnformatted) {
 			return 1
 		}
 		fmt.Fprintf(os.Stderr, "cctidy: %v\n", err)
+		if errors.Is(err, errLimitExceeded) {
+			return 3
+		}
 		return 2
 	}
+	cli.saveGraceState(ctx, now)
 	return 0
 }
 
-func (c *CLI) Run(ctx context.Context) error {
-	targets, err := c.resolveTargets()
+type tidyCmd struct{}
+
+func (tidyCmd) Run(ctx context.Context, c *CLI) error {
+	return c.RunTidy(ctx)
+}
+
+// RunTidy formats and sweeps the target files.
+func (c *CLI) RunTidy(ctx context.Context) error {
+	targets, err := c.resolveTargets(ctx)
 	if err != nil {
 		return err
 	}
 	return c.runTargets(ctx, targets)
 }
 
-func (c *CLI) checkFile(ctx context.Context, tf targetFile) (bool, error) {
+func (c *CLI) checkFile(ctx context.Context, tf targetFile) (*fileResult, error) {
 	data, err := os.ReadFile(tf.path)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 
 	result, err := tf.formatter.Format(ctx, data)
 	if err != nil {
-		return false, fmt.Errorf("formatting %s: %w", tf.path, err)
+		return nil, fmt.Errorf("formatting %s: %w", tf.path, err)
 	}
 
-	return bytes.Equal(data, result.Data), nil
+	return &fileResult{path: tf.path, original: data, result: result}, nil
+}
+
+// targetOutcome is the result of processing one target file.
+// output holds what processing wrote to the CLI writer.
+type targetOutcome struct {
+	r      *fileResult
+	err    error
+	output bytes.Buffer
+	done   bool
+}
+
+// processTargets runs fn on up to c.Jobs targets at a time and
+// returns the outcomes in target order. Each target writes to its
+// own buffer so that output does not depend on scheduling. Errors
+// that would end a sequential run stop further targets from
+// starting; runErr reports why targets were left undone.
+func (c *CLI) processTargets(ctx context.Context, targets []targetFile, fn func(*CLI, context.Context, targetFile) (*fileResult, error)) (outcomes []targetOutcome, runErr error) {
+	single := len(targets) == 1
+	outcomes = make([]targetOutcome, len(targets))
+	runErr = pool.Run(ctx, c.Jobs, len(targets), func(ctx context.Context, i int) error {
+		o := &outcomes[i]
+		tc := *c
+		tc.w = &o.output
+		o.r, o.err = fn(&tc, ctx, targets[i])
+		o.done = true
+		if fatalTargetError(o.err, single) {
+			return o.err
+		}
+		return nil
+	})
+	return outcomes, runErr
+}
+
+// fatalTargetError reports whether err ends the run. Missing files
+// in multi-target mode and exceeded safety thresholds do not.
+func fatalTargetError(err error, single bool) bool {
+	var le *limitError
+	switch {
+	case err == nil, errors.As(err, &le):
+		return false
+	case os.IsNotExist(err):
+		return single
+	default:
+		return true
+	}
+}
+
+// flush copies the buffered output of o to the CLI writer.
+func (c *CLI) flush(o *targetOutcome) {
+	_, _ = o.output.WriteTo(c.w)
 }
 
 func (c *CLI) checkTargets(ctx context.Context, targets []targetFile) error {
 	single := len(targets) == 1
 	hasUnformatted := false
-
-	for _, tf := range targets {
-		if err := ctx.Err(); err != nil {
-			return err
+	var reports []targetReport
+
+	var fatal error
+	outcomes, runErr := c.processTargets(ctx, targets, (*CLI).checkFile)
+	for i, tf := range targets {
+		o := &outcomes[i]
+		if !o.done {
+			fatal = cmp.Or(fatal, runErr)
+			continue
 		}
-		formatted, err := c.checkFile(ctx, tf)
+		c.flush(o)
+		r, err := o.r, o.err
 		if err != nil {
 			if single || !os.IsNotExist(err) {
-				return err
+				fatal = cmp.Or(fatal, err)
+				continue
 			}
+			reports = append(reports, skippedReport(tf.path).inProject(tf.project))
 			continue
 		}
-		if !formatted {
+		reports = append(reports, newTargetReport(r).inProject(tf.project))
+		if c.Diff {
+			c.printDiff(r)
+		}
+		if r.result.Changed {
 			hasUnformatted = true
-			if c.Verbose {
+			if c.Verbose && !c.jsonOutput() {
 				fmt.Fprintf(c.w, "%s: needs formatting\n", tf.path)
 			}
 		}
 	}
 
+	if c.jsonOutput() {
+		if err := writeReport(c.out, runReport{Mode: c.mode(), Targets: reports}); err != nil {
+			return err
+		}
+	}
+	if fatal != nil {
+		return fatal
+	}
 	if hasUnformatted {
 		return errUnformatted
 	}
@@ -168,54 +336,176 @@ func (c *CLI) runTargets(ctx context.Context, targets []targetFile) error {
 		return c.checkTargets(ctx, targets)
 	}
 	single := len(targets) == 1
-
-	for _, tf := range targets {
-		if err := ctx.Err(); err != nil {
-			return err
+	var reports []targetReport
+	var patches []patchTarget
+	blocked := false
+
+	// A fatal error does not stop reporting the targets that
+	// finished, since with --jobs > 1 they may already have been
+	// written.
+	var fatal error
+	outcomes, runErr := c.processTargets(ctx, targets, (*CLI).formatFile)
+	project := ""
+	for i, tf := range targets {
+		o := &outcomes[i]
+		if !o.done {
+			fatal = cmp.Or(fatal, runErr)
+			continue
+		}
+		if tf.project != project {
+			project = tf.project
+			if c.Verbose && !c.jsonOutput() {
+				fmt.Fprintf(c.w, "== Project: %s ==\n\n", project)
+			}
+		}
+		c.flush(o)
+		r, err := o.r, o.err
+		var le *limitError
+		if errors.As(err, &le) {
+			blocked = true
+			fmt.Fprintf(c.w, "cctidy: %v\n", le)
+			reports = append(reports, blockedReport(tf.path, le.violations).inProject(tf.project))
+			continue
 		}
-		r, err := c.formatFile(ctx, tf)
 		if err != nil {
 			if single || !os.IsNotExist(err) {
-				return err
+				fatal = cmp.Or(fatal, err)
+				continue
 			}
-			if c.Verbose {
+			reports = append(reports, skippedReport(tf.path).inProject(tf.project))
+			if c.Verbose && !c.jsonOutput() {
 				fmt.Fprintf(c.w, "%s: skipped (not found)\n\n", tf.path)
 			}
 			continue
 		}
-		if c.Verbose {
+		reports = append(reports, newTargetReport(r).inProject(tf.project))
+		if c.Patch {
+			pt, err := newPatchTarget(r)
+			if err != nil {
+				return err
+			}
+			if pt != nil {
+				patches = append(patches, *pt)
+			}
+		}
+		if c.Diff {
+			c.printDiff(r)
+		}
+		if c.Verbose && !c.jsonOutput() {
 			printResult(c.w, r, single)
 		}
 	}
+
+	// --patch writes nothing, so a partial patch is not emitted.
+	if c.Patch && fatal == nil {
+		if err := writePatch(c.out, patchDocument{Targets: patches}); err != nil {
+			return err
+		}
+	} else if !c.Patch && c.jsonOutput() {
+		if err := writeReport(c.out, runReport{Mode: c.mode(), Targets: reports}); err != nil {
+			return err
+		}
+	}
+	if fatal != nil {
+		return fatal
+	}
+	// --dry-run writes nothing, so a tripped threshold is only
+	// reported.
+	if blocked && !c.DryRun {
+		return errLimitExceeded
+	}
 	return nil
 }
 
-func (c *CLI) resolveTargets() ([]targetFile, error) {
+// limitViolations returns the safety thresholds exceeded by a
+// formatting result. --force disables the check.
+func (c *CLI) limitViolations(stats cctidy.Summarizer) []cctidy.LimitViolation {
+	if c.Force || c.cfg == nil {
+		return nil
+	}
+	r, ok := stats.(interface{ Removals() []cctidy.Removal })
+	if !ok {
+		return nil
+	}
+	return c.cfg.Safety.Check(r.Removals())
+}
+
+// saveGraceState persists paths observed missing during a run
+// that wrote files. Read-only runs (--dry-run, --check, --patch)
+// do not advance the grace period. The state file is re-read and
+// merged under the state-dir lock, so concurrent runs do not drop
+// each other's observations.
+func (c *CLI) saveGraceState(ctx context.Context, now time.Time) {
+	if c.graceState == nil || c.DryRun || c.Check || c.Patch {
+		return
+	}
+	unlock, err := c.lock(ctx, c.graceState.Path())
+	if err == nil {
+		defer unlock()
+		err = c.graceState.Save(now)
+	}
+	if err != nil {
+		fmt.Fprintf(c.w, "cctidy: warning: saving grace state: %v\n", err)
+	}
+}
+
+// jsonOutput reports whether results are printed as a JSON document.
+func (c *CLI) jsonOutput() bool {
+	return c.Format == "json"
+}
+
+func (c *CLI) resolveTargets(ctx context.Context) ([]targetFile, error) {
 	if c.Target == "" {
-		return c.defaultTargets()
+		return c.defaultTargets(ctx)
 	}
 	if filepath.Base(c.Target) == ".claude.json" {
-		f := cctidy.NewClaudeJSONFormatter(c.checker)
-		return []targetFile{{path: c.Target, formatter: f}}, nil
+		return []targetFile{{path: c.Target, formatter: c.claudeJSONFormatter()}}, nil
 	}
 	var opts []cctidy.SweepOption
 	if filepath.Dir(c.Target) != filepath.Join(c.homeDir, ".claude") {
 		projectDir := filepath.Dir(filepath.Dir(c.Target))
 		opts = append(opts, cctidy.WithProjectLevel(projectDir))
 	}
-	if c.cfg != nil {
-		opts = append(opts, cctidy.WithBashConfig(&c.cfg.Permission.Bash))
-	}
-	if c.Unsafe {
-		opts = append(opts, cctidy.WithUnsafe())
+	if baseDir, err := filepath.Abs(cctidy.SettingsBaseDir(c.Target)); err == nil {
+		opts = append(opts, cctidy.WithBaseDir(baseDir))
 	}
-	serverSets := c.loadMCPServers()
+	opts = c.sweepOptions(c.cfg, opts...)
+	serverSets := c.loadMCPServers(c.projectRoot)
 	mcpServers := c.mcpServersForTarget(serverSets, c.Target)
 	sweeper, err := cctidy.NewPermissionSweeper(c.checker, c.homeDir, mcpServers, opts...)
 	if err != nil {
 		return nil, err
 	}
-	return []targetFile{{path: c.Target, formatter: cctidy.NewSettingsJSONFormatter(sweeper)}}, nil
+	return []targetFile{{path: c.Target, formatter: c.settingsFormatter(sweeper, c.Target)}}, nil
+}
+
+// sweepOptions returns the permission sweep options for cfg and the
+// command-line flags, appended to opts.
+func (c *CLI) sweepOptions(cfg *cctidy.Config, opts ...cctidy.SweepOption) []cctidy.SweepOption {
+	opts = append(opts, cctidy.WithJobs(c.Jobs), cctidy.WithPlugins(c.installedPlugins()))
+	if c.mounts != nil {
+		opts = append(opts, cctidy.WithMountChecker(c.mounts))
+	}
+	if cfg != nil {
+		opts = append(opts,
+			cctidy.WithBashConfig(&cfg.Permission.Bash),
+			cctidy.WithGlobConfig(&cfg.Permission.Glob),
+			cctidy.WithCheckTimeout(cfg.Paths.CheckTimeout),
+			cctidy.WithExpandVars(cfg.Paths.ExpandVars, os.LookupEnv),
+			cctidy.WithSearchPath(filepath.SplitList(os.Getenv("PATH"))),
+		)
+	}
+	if c.Unsafe {
+		opts = append(opts, cctidy.WithUnsafe())
+	}
+	return opts
+}
+
+// claudeJSONFormatter returns the formatter for ~/.claude.json.
+func (c *CLI) claudeJSONFormatter() *cctidy.ClaudeJSONFormatter {
+	f := cctidy.NewClaudeJSONFormatter(c.checker)
+	f.Jobs = c.Jobs
+	return f
 }
 
 func findProjectRoot(dir string) string {
@@ -233,11 +523,11 @@ func findProjectRoot(dir string) string {
 	}
 }
 
-// loadMCPServers loads known MCP server names from .mcp.json and
-// ~/.claude.json. Errors are printed as warnings.
-func (c *CLI) loadMCPServers() *cctidy.MCPServerSets {
+// loadMCPServers loads known MCP server names from the .mcp.json
+// of projectRoot and ~/.claude.json. Errors are printed as warnings.
+func (c *CLI) loadMCPServers(projectRoot string) *cctidy.MCPServerSets {
 	servers, err := cctidy.LoadMCPServers(
-		filepath.Join(c.projectRoot, ".mcp.json"),
+		filepath.Join(projectRoot, ".mcp.json"),
 		filepath.Join(c.homeDir, ".claude.json"),
 	)
 	if err != nil {
@@ -247,6 +537,47 @@ func (c *CLI) loadMCPServers() *cctidy.MCPServerSets {
 	return servers
 }
 
+// installedPlugins loads the plugins installed under
+// ~/.claude/plugins once per run. Errors are printed as warnings.
+func (c *CLI) installedPlugins() []cctidy.InstalledPlugin {
+	if c.plugins == nil {
+		plugins, err := cctidy.LoadInstalledPlugins(filepath.Join(c.homeDir, ".claude", "plugins"))
+		if err != nil {
+			fmt.Fprintf(c.w, "cctidy: warning: loading installed plugins: %v\n", err)
+		}
+		c.plugins = &plugins
+	}
+	return *c.plugins
+}
+
+// settingsFormatter returns a formatter for the settings file at
+// path that sweeps permissions with sweeper. Plugin references are
+// swept against the local plugin registry only in user-level and
+// *.local.json files: enabledPlugins in a checked-in project
+// settings file is a team declaration that prompts teammates to
+// install the plugin, not a record of this machine.
+func (c *CLI) settingsFormatter(sweeper *cctidy.PermissionSweeper, path string) *cctidy.SettingsJSONFormatter {
+	f := cctidy.NewSettingsJSONFormatter(sweeper)
+	if filepath.Dir(path) == filepath.Join(c.homeDir, ".claude") || strings.HasSuffix(path, ".local.json") {
+		f.Plugins = c.pluginSettingsSweeper()
+	}
+	return f
+}
+
+// pluginSettingsSweeper builds the enabledPlugins sweeper once per
+// run from the installed plugins and known marketplaces. Errors
+// are printed as warnings.
+func (c *CLI) pluginSettingsSweeper() *cctidy.PluginSettingsSweeper {
+	if c.pluginCfg == nil {
+		marketplaces, err := cctidy.LoadKnownMarketplaces(filepath.Join(c.homeDir, ".claude", "plugins"))
+		if err != nil {
+			fmt.Fprintf(c.w, "cctidy: warning: loading known marketplaces: %v\n", err)
+		}
+		c.pluginCfg = cctidy.NewPluginSettingsSweeper(c.installedPlugins(), marketplaces)
+	}
+	return c.pluginCfg
+}
+
 // mcpServersForTarget returns the appropriate server set for the
 // given target path. User-scope paths (~/.claude/) get User set;
 // everything else gets Project set.
@@ -259,88 +590,186 @@ func (c *CLI) mcpServersForTarget(servers *cctidy.MCPServerSets, target string)
 	return servers.ForProjectScope()
 }
 
-func (c *CLI) defaultTargets() ([]targetFile, error) {
-	projectRoot := c.projectRoot
-	claude := cctidy.NewClaudeJSONFormatter(c.checker)
-	var globalOpts []cctidy.SweepOption
-	projectOpts := []cctidy.SweepOption{cctidy.WithProjectLevel(projectRoot)}
-	if c.cfg != nil {
-		bashOpt := cctidy.WithBashConfig(&c.cfg.Permission.Bash)
-		globalOpts = append(globalOpts, bashOpt)
-		projectOpts = append(projectOpts, bashOpt)
+func (c *CLI) defaultTargets(ctx context.Context) ([]targetFile, error) {
+	claude := c.claudeJSONFormatter()
+	serverSets := c.loadMCPServers(c.projectRoot)
+	// ~/.claude/settings.json resolves relative rules against ~.
+	globalOpts := c.sweepOptions(c.cfg, cctidy.WithBaseDir(c.homeDir))
+	globalSweeper, err := cctidy.NewPermissionSweeper(c.checker, c.homeDir, serverSets.ForUserScope(), globalOpts...)
+	if err != nil {
+		return nil, err
 	}
-	if c.Unsafe {
-		unsafeOpt := cctidy.WithUnsafe()
-		globalOpts = append(globalOpts, unsafeOpt)
-		projectOpts = append(projectOpts, unsafeOpt)
+	globalPath := filepath.Join(c.homeDir, ".claude", "settings.json")
+	globalSettings := c.settingsFormatter(globalSweeper, globalPath)
+	targets := []targetFile{
+		{path: filepath.Join(c.homeDir, ".claude.json"), formatter: claude},
+		{path: globalPath, formatter: globalSettings},
+		{path: filepath.Join(c.homeDir, ".claude", "settings.local.json"), formatter: globalSettings},
+	}
+
+	var projectTargets []targetFile
+	if c.AllProjects {
+		projectTargets, err = c.allProjectTargets(ctx)
+	} else {
+		projectTargets, err = c.projectTargets(c.projectRoot, c.cfg, serverSets)
 	}
-	serverSets := c.loadMCPServers()
-	globalSweeper, err := cctidy.NewPermissionSweeper(c.checker, c.homeDir, serverSets.ForUserScope(), globalOpts...)
 	if err != nil {
 		return nil, err
 	}
-	projectSweeper, err := cctidy.NewPermissionSweeper(c.checker, c.homeDir, serverSets.ForProjectScope(), projectOpts...)
+	// A project rooted at the home directory shares its settings
+	// files with the user level; those keep their user-level rules.
+	seen := set.New[string]()
+	for _, tf := range targets {
+		seen.Add(tf.path)
+	}
+	for _, tf := range projectTargets {
+		if !seen.Has(tf.path) {
+			seen.Add(tf.path)
+			targets = append(targets, tf)
+		}
+	}
+	return targets, nil
+}
+
+// allProjectTargets returns the settings files of the current
+// project and of every project in ~/.claude.json that still
+// exists. Each project is swept with its own project config and
+// .mcp.json. The home directory is not a project: its settings are
+// the user-level ones. Projects whose config fails to load are
+// skipped with a warning; projects whose status cannot be
+// determined are skipped with a single warning, listed one by one
+// in verbose mode.
+func (c *CLI) allProjectTargets(ctx context.Context) ([]targetFile, error) {
+	paths, err := cctidy.LoadProjectPaths(filepath.Join(c.homeDir, ".claude.json"))
+	if err != nil {
+		return nil, err
+	}
+	exists := make([]bool, len(paths))
+	errs := make([]error, len(paths))
+	err = pool.Run(ctx, c.Jobs, len(paths), func(ctx context.Context, i int) error {
+		exists[i], errs[i] = c.checker.Exists(ctx, paths[i])
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	roots := set.New(c.projectRoot)
+	unknown := 0
+	for i, p := range paths {
+		if errs[i] != nil {
+			unknown++
+			if c.Verbose {
+				fmt.Fprintf(c.w, "cctidy: warning: skipping project %s: %v\n", p, errs[i])
+			}
+			continue
+		}
+		if exists[i] {
+			roots.Add(p)
+		}
+	}
+	if unknown > 0 && !c.Verbose {
+		fmt.Fprintf(c.w, "cctidy: warning: skipping %d projects whose status cannot be determined (use -v for details)\n", unknown)
+	}
+	roots.Delete(c.homeDir)
+
+	var targets []targetFile
+	for _, root := range slices.Sorted(maps.Keys(roots)) {
+		cfg := c.cfg
+		if root != c.projectRoot {
+			projectCfg, err := cctidy.LoadProjectConfig(root)
+			if err != nil {
+				fmt.Fprintf(c.w, "cctidy: warning: skipping project %s: %v\n", root, err)
+				continue
+			}
+			cfg = cctidy.MergeConfig(c.globalCfg, projectCfg, root)
+		}
+		projectTargets, err := c.projectTargets(root, cfg, c.loadMCPServers(root))
+		if err != nil {
+			return nil, err
+		}
+		for i := range projectTargets {
+			projectTargets[i].project = root
+		}
+		targets = append(targets, projectTargets...)
+	}
+	return targets, nil
+}
+
+// projectTargets returns the settings files of the project at root,
+// swept with the given config and MCP servers.
+func (c *CLI) projectTargets(root string, cfg *cctidy.Config, serverSets *cctidy.MCPServerSets) ([]targetFile, error) {
+	opts := c.sweepOptions(cfg, cctidy.WithProjectLevel(root))
+	sweeper, err := cctidy.NewPermissionSweeper(c.checker, c.homeDir, serverSets.ForProjectScope(), opts...)
 	if err != nil {
 		return nil, err
 	}
-	globalSettings := cctidy.NewSettingsJSONFormatter(globalSweeper)
-	projectSettings := cctidy.NewSettingsJSONFormatter(projectSweeper)
+	shared := filepath.Join(root, ".claude", "settings.json")
+	local := filepath.Join(root, ".claude", "settings.local.json")
 	return []targetFile{
-		{path: filepath.Join(c.homeDir, ".claude.json"), formatter: claude},
-		{path: filepath.Join(c.homeDir, ".claude", "settings.json"), formatter: globalSettings},
-		{path: filepath.Join(c.homeDir, ".claude", "settings.local.json"), formatter: globalSettings},
-		{path: filepath.Join(projectRoot, ".claude", "settings.json"), formatter: projectSettings},
-		{path: filepath.Join(projectRoot, ".claude", "settings.local.json"), formatter: projectSettings},
+		{path: shared, formatter: c.settingsFormatter(sweeper, shared)},
+		{path: local, formatter: c.settingsFormatter(sweeper, local)},
 	}, nil
 }
 
 func (c *CLI) formatFile(ctx context.Context, tf targetFile) (*fileResult, error) {
-	info, err := os.Stat(tf.path)
-	if err != nil {
-		return nil, err
+	if c.DryRun || c.Patch {
+		data, err := os.ReadFile(tf.path)
+		if err != nil {
+			return nil, err
+		}
+		return c.formatData(ctx, tf, data)
 	}
-	perm := info.Mode().Perm()
 
-	data, err := os.ReadFile(tf.path)
+	var r *fileResult
+	err := c.updateFile(ctx, tf.path, func(data []byte) ([]byte, error) {
+		var err error
+		if r, err = c.formatData(ctx, tf, data); err != nil {
+			return nil, err
+		}
+		if !r.result.Changed {
+			return nil, nil
+		}
+		return r.result.Data, nil
+	}, func(data []byte) error {
+		var err error
+		if r.backupPath, err = c.backup(tf.path, data, hasSwept(r.result.Stats)); err != nil {
+			return fmt.Errorf("creating backup: %w", err)
+		}
+		return nil
+	})
 	if err != nil {
-		return nil, fmt.Errorf("reading %s: %w", tf.path, err)
+		return nil, err
 	}
+	if err := c.recordTrash(tf.path, r.original, r.result); err != nil {
+		fmt.Fprintf(c.w, "cctidy: warning: recording trash for %s: %v\n", tf.path, err)
+	}
+	return r, nil
+}
 
+// formatData formats data read from tf and applies the safety
+// thresholds.
+func (c *CLI) formatData(ctx context.Context, tf targetFile, data []byte) (*fileResult, error) {
 	result, err := tf.formatter.Format(ctx, data)
 	if err != nil {
 		return nil, fmt.Errorf("formatting %s: %w", tf.path, err)
 	}
-
-	var backupPath string
-	if !c.DryRun {
-		if c.Backup {
-			backupPath = fmt.Sprintf("%s.backup.%s",
-				tf.path, time.Now().Format("20060102150405"))
-			if err := os.WriteFile(backupPath, data, perm); err != nil {
-				return nil, fmt.Errorf("creating backup: %w", err)
-			}
-		}
-		if err := writeFile(tf.path, result.Data, perm); err != nil {
-			return nil, fmt.Errorf("writing %s: %w", tf.path, err)
-		}
+	if v := c.limitViolations(result.Stats); len(v) > 0 {
+		return nil, &limitError{path: tf.path, violations: v}
 	}
-
-	return &fileResult{
-		path:       tf.path,
-		original:   data,
-		result:     result,
-		backupPath: backupPath,
-	}, nil
+	return &fileResult{path: tf.path, original: data, result: result}, nil
 }
 
 func printResult(w io.Writer, r *fileResult, single bool) {
 	if single {
 		fmt.Fprint(w, r.result.Stats.Summary())
+		if !r.result.Changed {
+			fmt.Fprintln(w, "Unchanged: not written")
+		}
 		printBackup(w, r.backupPath, "")
 		return
 	}
-	if bytes.Equal(r.original, r.result.Data) {
-		fmt.Fprintf(w, "%s:\n  (no changes)\n\n", r.path)
+	if !r.result.Changed {
+		fmt.Fprintf(w, "%s:\n  (unchanged)\n\n", r.path)
 		return
 	}
 	fmt.Fprintf(w, "%s:\n", r.path)
@@ -351,6 +780,40 @@ func printResult(w io.Writer, r *fileResult, single bool) {
 	fmt.Fprintln(w)
 }
 
+// printDiff writes a unified diff between the original and
+// formatted contents of r to the standard output writer.
+func (c *CLI) printDiff(r *fileResult) {
+	d := diff.Unified(r.path+".orig", r.path, r.original, r.result.Data)
+	if d == "" {
+		return
+	}
+	if c.useColor() {
+		d = diff.Colorize(d)
+	}
+	fmt.Fprint(c.out, d)
+}
+
+// useColor reports whether diff output should be colorized.
+// In auto mode, color is used only when writing to a terminal
+// and NO_COLOR is not set.
+func (c *CLI) useColor() bool {
+	switch c.Color {
+	case "always":
+		return true
+	case "never":
+		return false
+	}
+	if _, ok := os.LookupEnv("NO_COLOR"); ok {
+		return false
+	}
+	f, ok := c.out.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
 func printBackup(w io.Writer, backupPath, indent string) {
 	if backupPath != "" {
 		fmt.Fprintf(w, "%sBackup: %s\n", indent, backupPath)
@@ -379,6 +842,14 @@ func splitLines(s string) []string {
 }
 
 func writeFile(path string, data []byte, perm os.FileMode) error {
+	return writeFileIf(path, data, perm, nil, nil)
+}
+
+// writeFileIf atomically replaces path with data. When snap is not
+// nil, the rename is skipped and errFileChanged returned if path no
+// longer matches snap. beforeRename, if not nil, runs once the
+// snapshot check has passed; an error from it aborts the write.
+func writeFileIf(path string, data []byte, perm os.FileMode, snap *fileSnapshot, beforeRename func() error) error {
 	resolved, err := filepath.EvalSymlinks(path)
 	if err != nil && !os.IsNotExist(err) {
 		return fmt.Errorf("resolving path: %w", err)
@@ -393,10 +864,12 @@ func writeFile(path string, data []byte, perm os.FileMode) error {
 		return fmt.Errorf("creating temp file: %w", err)
 	}
 
-	closed := false
+	closed, renamed := false, false
 	defer func() {
 		if !closed {
 			_ = tmp.Close()
+		}
+		if !renamed {
 			_ = os.Remove(tmp.Name())
 		}
 	}()
@@ -418,16 +891,25 @@ func writeFile(path string, data []byte, perm os.FileMode) error {
 	}
 	closed = true
 
+	if snap != nil {
+		ok, err := snap.unchanged(path)
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("checking for concurrent changes: %w", err)
+		}
+		if !ok {
+			return errFileChanged
+		}
+	}
+	if beforeRename != nil {
+		if err := beforeRename(); err != nil {
+			return err
+		}
+	}
+
 	if err := os.Rename(tmp.Name(), path); err != nil {
 		return fmt.Errorf("renaming temp file: %w", err)
 	}
+	renamed = true
 
 	return nil
 }
-
-type osPathChecker struct{}
-
-func (o *osPathChecker) Exists(_ context.Context, path string) bool {
-	_, err := os.Stat(path)
-	return err == nil
-}
@@ -2,10 +2,15 @@ package main
 
 import (
 	"bytes"
+	"errors"
 	"os"
 	"path/filepath"
 	"runtime"
 	"testing"
+	"time"
+
+	"github.com/708u/cctidy"
+	"github.com/708u/cctidy/internal/testutil"
 )
 
 func TestFindProjectRoot(t *testing.T) {
@@ -246,3 +251,254 @@ func TestWriteFile(t *testing.T) {
 		}
 	})
 }
+
+func TestWriteFileIf(t *testing.T) {
+	t.Parallel()
+
+	setup := func(t *testing.T) (string, *fileSnapshot) {
+		t.Helper()
+		path := filepath.Join(t.TempDir(), "out.json")
+		os.WriteFile(path, []byte("original"), 0o644)
+		info, _ := os.Stat(path)
+		snap := newSnapshot(info, []byte("original"))
+		return path, &snap
+	}
+
+	t.Run("unchanged file is replaced", func(t *testing.T) {
+		t.Parallel()
+		path, snap := setup(t)
+		if err := writeFileIf(path, []byte("updated"), 0o644, snap, nil); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got, _ := os.ReadFile(path); string(got) != "updated" {
+			t.Errorf("content = %q, want updated", got)
+		}
+	})
+
+	t.Run("changed file is left alone", func(t *testing.T) {
+		t.Parallel()
+		path, snap := setup(t)
+		os.WriteFile(path, []byte("concurrent write"), 0o644)
+
+		err := writeFileIf(path, []byte("updated"), 0o644, snap, nil)
+		if !errors.Is(err, errFileChanged) {
+			t.Fatalf("expected errFileChanged, got: %v", err)
+		}
+		if got, _ := os.ReadFile(path); string(got) != "concurrent write" {
+			t.Errorf("content = %q, concurrent write was clobbered", got)
+		}
+		matches, _ := filepath.Glob(filepath.Join(filepath.Dir(path), "*.tmp.*"))
+		if len(matches) != 0 {
+			t.Errorf("temp files remain: %v", matches)
+		}
+	})
+
+	t.Run("same size and mtime with different content", func(t *testing.T) {
+		t.Parallel()
+		path, snap := setup(t)
+		info, _ := os.Stat(path)
+		os.WriteFile(path, []byte("ORIGINAL"), 0o644)
+		os.Chtimes(path, info.ModTime(), info.ModTime())
+
+		if err := writeFileIf(path, []byte("updated"), 0o644, snap, nil); !errors.Is(err, errFileChanged) {
+			t.Errorf("expected errFileChanged, got: %v", err)
+		}
+	})
+
+	t.Run("deleted file counts as changed", func(t *testing.T) {
+		t.Parallel()
+		path, snap := setup(t)
+		os.Remove(path)
+
+		if err := writeFileIf(path, []byte("updated"), 0o644, snap, nil); !errors.Is(err, errFileChanged) {
+			t.Errorf("expected errFileChanged, got: %v", err)
+		}
+	})
+}
+
+func TestUpdateFile(t *testing.T) {
+	t.Parallel()
+
+	t.Run("retries on concurrent change", func(t *testing.T) {
+		t.Parallel()
+		path := filepath.Join(t.TempDir(), "out.json")
+		os.WriteFile(path, []byte("a"), 0o644)
+
+		var seen, backedUp []string
+		cli := &CLI{w: &bytes.Buffer{}, lockDir: t.TempDir()}
+		err := cli.updateFile(t.Context(), path, func(data []byte) ([]byte, error) {
+			seen = append(seen, string(data))
+			if len(seen) == 1 {
+				// Simulate another writer between read and rename.
+				os.WriteFile(path, []byte("bb"), 0o644)
+			}
+			return append(data, '!'), nil
+		}, func(data []byte) error {
+			backedUp = append(backedUp, string(data))
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(seen) != 2 || seen[1] != "bb" {
+			t.Errorf("fn saw %q, want [a bb]", seen)
+		}
+		if len(backedUp) != 1 || backedUp[0] != "bb" {
+			t.Errorf("beforeWrite saw %q, want [bb]", backedUp)
+		}
+		if got, _ := os.ReadFile(path); string(got) != "bb!" {
+			t.Errorf("content = %q, want bb!", got)
+		}
+	})
+
+	t.Run("gives up when file keeps changing", func(t *testing.T) {
+		t.Parallel()
+		path := filepath.Join(t.TempDir(), "out.json")
+		os.WriteFile(path, []byte("a"), 0o644)
+
+		calls, backups := 0, 0
+		cli := &CLI{w: &bytes.Buffer{}}
+		err := cli.updateFile(t.Context(), path, func(data []byte) ([]byte, error) {
+			calls++
+			os.WriteFile(path, append(data, 'x'), 0o644)
+			return []byte("mine"), nil
+		}, func([]byte) error {
+			backups++
+			return nil
+		})
+		if !errors.Is(err, errFileChanged) {
+			t.Fatalf("expected errFileChanged, got: %v", err)
+		}
+		if calls != maxUpdateAttempts {
+			t.Errorf("calls = %d, want %d", calls, maxUpdateAttempts)
+		}
+		if got, _ := os.ReadFile(path); string(got) == "mine" {
+			t.Error("concurrent write was clobbered")
+		}
+		if backups != 0 {
+			t.Errorf("beforeWrite called %d times for aborted writes", backups)
+		}
+	})
+
+	t.Run("nil output leaves file untouched", func(t *testing.T) {
+		t.Parallel()
+		path := filepath.Join(t.TempDir(), "out.json")
+		os.WriteFile(path, []byte("a"), 0o600)
+		before, _ := os.Stat(path)
+
+		cli := &CLI{w: &bytes.Buffer{}}
+		if err := cli.updateFile(t.Context(), path, func([]byte) ([]byte, error) { return nil, nil }, nil); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		after, _ := os.Stat(path)
+		if !os.SameFile(before, after) {
+			t.Error("file was replaced")
+		}
+	})
+
+	t.Run("missing file creates no lock", func(t *testing.T) {
+		t.Parallel()
+		path := filepath.Join(t.TempDir(), "missing.json")
+		lockDir := filepath.Join(t.TempDir(), "locks")
+		cli := &CLI{w: &bytes.Buffer{}, lockDir: lockDir}
+		err := cli.updateFile(t.Context(), path, func(data []byte) ([]byte, error) { return data, nil }, nil)
+		if !os.IsNotExist(err) {
+			t.Errorf("expected not-exist error, got: %v", err)
+		}
+		if _, err := os.Stat(lockDir); !os.IsNotExist(err) {
+			t.Error("lock created for missing target")
+		}
+	})
+
+	t.Run("lock is kept out of the target directory", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		path := filepath.Join(dir, "out.json")
+		os.WriteFile(path, []byte("a"), 0o644)
+		lockDir := t.TempDir()
+		cli := &CLI{w: &bytes.Buffer{}, lockDir: lockDir}
+		err := cli.updateFile(t.Context(), path, func(data []byte) ([]byte, error) { return append(data, '!'), nil }, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+			t.Errorf("target directory has extra files: %v", entries)
+		}
+		if _, err := os.Stat(lockPath(lockDir, path)); err != nil {
+			t.Errorf("lock file not in lock dir: %v", err)
+		}
+	})
+}
+
+func TestLockFile(t *testing.T) {
+	t.Parallel()
+	if runtime.GOOS == "windows" {
+		t.Skip("skip on windows")
+	}
+
+	path := filepath.Join(t.TempDir(), "out.json.lock")
+	unlock, err := lockFile(t.Context(), path, time.Second)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := lockFile(t.Context(), path, 100*time.Millisecond); err == nil {
+		t.Fatal("expected timeout while lock is held")
+	}
+
+	unlock()
+	unlock2, err := lockFile(t.Context(), path, time.Second)
+	if err != nil {
+		t.Fatalf("lock not released: %v", err)
+	}
+	unlock2()
+}
+
+func TestSaveGraceState(t *testing.T) {
+	t.Parallel()
+	if runtime.GOOS == "windows" {
+		t.Skip("skip on windows")
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "grace.json")
+	lockDir := filepath.Join(dir, "locks")
+	now := time.Now()
+	policy := cctidy.GracePolicy{Runs: 2}
+
+	// Both runs load the state before either saves.
+	newCLI := func(missing string) *CLI {
+		state, err := cctidy.LoadGraceState(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cctidy.NewGraceChecker(testutil.NoPathsExist{}, state, policy, now).Exists(t.Context(), missing)
+		return &CLI{w: &bytes.Buffer{}, lockDir: lockDir, graceState: state}
+	}
+	a, b := newCLI("/a"), newCLI("/b")
+
+	os.MkdirAll(lockDir, 0o700)
+	unlock, err := lockFile(t.Context(), lockPath(lockDir, path), time.Second)
+	if err != nil {
+		t.Fatalf("lockFile: %v", err)
+	}
+	done := make(chan struct{})
+	go func() {
+		a.saveGraceState(t.Context(), now)
+		close(done)
+	}()
+	time.Sleep(100 * time.Millisecond)
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("state saved while the lock was held")
+	}
+	unlock()
+	<-done
+	b.saveGraceState(t.Context(), now.Add(time.Minute))
+
+	data, _ := os.ReadFile(path)
+	for _, p := range []string{"/a", "/b"} {
+		if !bytes.Contains(data, []byte(`"`+p+`"`)) {
+			t.Errorf("state lost %s:\n%s", p, data)
+		}
+	}
+}
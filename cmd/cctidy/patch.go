@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/708u/cctidy/internal/jsonpatch"
+)
+
+// patchDocument is the JSON document printed by --patch and
+// consumed by the apply command.
+type patchDocument struct {
+	Targets []patchTarget `json:"targets"`
+}
+
+// patchTarget holds the RFC 6902 patch for a single target file.
+type patchTarget struct {
+	Path  string          `json:"path"`
+	Patch jsonpatch.Patch `json:"patch"`
+}
+
+type applyCmd struct {
+	PatchFile string `arg:"" help:"Patch file produced by --patch, or - for stdin." name:"patch-file"`
+}
+
+func (applyCmd) Run(ctx context.Context, c *CLI) error {
+	return c.RunApply(ctx)
+}
+
+// newPatchTarget computes the patch between the decoded original
+// and formatted contents of r. Returns nil when nothing changed.
+func newPatchTarget(r *fileResult) (*patchTarget, error) {
+	if !r.result.Changed {
+		return nil, nil
+	}
+	before, err := jsonpatch.Decode(r.original)
+	if err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", r.path, err)
+	}
+	after, err := jsonpatch.Decode(r.result.Data)
+	if err != nil {
+		return nil, fmt.Errorf("decoding formatted %s: %w", r.path, err)
+	}
+	p := jsonpatch.Diff(before, after)
+	if len(p) == 0 {
+		p = jsonpatch.Patch{}
+	}
+	return &patchTarget{Path: r.path, Patch: p}, nil
+}
+
+func writePatch(w io.Writer, doc patchDocument) error {
+	if doc.Targets == nil {
+		doc.Targets = []patchTarget{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
+
+func (c *CLI) readPatch() (*patchDocument, error) {
+	var data []byte
+	var err error
+	if c.Apply.PatchFile == "-" {
+		data, err = io.ReadAll(c.in)
+	} else {
+		data, err = os.ReadFile(c.Apply.PatchFile)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading patch: %w", err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var doc patchDocument
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid patch: %w", err)
+	}
+	return &doc, nil
+}
+
+type appliedPatch struct {
+	target  patchTarget
+	removes int
+}
+
+// RunApply applies a patch document produced by --patch. Every
+// target is patched in memory first; files are only written when
+// all patches apply cleanly, so a stale patch leaves every file
+// untouched.
+func (c *CLI) RunApply(ctx context.Context) error {
+	doc, err := c.readPatch()
+	if err != nil {
+		return err
+	}
+
+	applied := make([]appliedPatch, 0, len(doc.Targets))
+	for _, t := range doc.Targets {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		a, err := applyTarget(t)
+		if err != nil {
+			return err
+		}
+		applied = append(applied, *a)
+	}
+
+	for _, a := range applied {
+		if !c.DryRun {
+			// The file may have changed since it was checked above;
+			// updateFile re-applies the patch, whose test operations
+			// fail if the change conflicts with it.
+			var backupPath string
+			err := c.updateFile(ctx, a.target.Path, func(data []byte) ([]byte, error) {
+				return patchData(a.target, data)
+			}, func(data []byte) error {
+				var err error
+				if backupPath, err = c.backup(a.target.Path, data, a.removes > 0); err != nil {
+					return fmt.Errorf("creating backup: %w", err)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			if c.Verbose {
+				printBackup(c.w, backupPath, "")
+			}
+		}
+		if c.Verbose {
+			fmt.Fprintf(c.w, "%s: applied %d operations\n", a.target.Path, len(a.target.Patch))
+		}
+	}
+	return nil
+}
+
+func applyTarget(t patchTarget) (*appliedPatch, error) {
+	data, err := os.ReadFile(t.Path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := patchData(t, data); err != nil {
+		return nil, err
+	}
+	removes := 0
+	for _, op := range t.Patch {
+		if op.Op == jsonpatch.OpRemove {
+			removes++
+		}
+	}
+	return &appliedPatch{target: t, removes: removes}, nil
+}
+
+// patchData applies the patch of t to data, the current content
+// of t.Path.
+func patchData(t patchTarget, data []byte) ([]byte, error) {
+	doc, err := jsonpatch.Decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", t.Path, err)
+	}
+	doc, err = jsonpatch.Apply(doc, t.Patch)
+	if err != nil {
+		return nil, fmt.Errorf("patch does not match %s: %w", t.Path, err)
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return nil, fmt.Errorf("encoding %s: %w", t.Path, err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+
+	"github.com/708u/cctidy"
+)
+
+// Target statuses reported in JSON output.
+const (
+	statusChanged   = "changed"
+	statusUnchanged = "unchanged"
+	statusSkipped   = "skipped"
+	statusBlocked   = "blocked"
+)
+
+// runReport is the JSON document printed by --format json.
+type runReport struct {
+	Mode    string         `json:"mode"`
+	Targets []targetReport `json:"targets"`
+}
+
+// targetReport describes the outcome for a single target file.
+// Swept and Warnings are extracted from the formatter records
+// for convenience; Stats holds the full formatter statistics.
+type targetReport struct {
+	Path         string               `json:"path"`
+	Project      string               `json:"project,omitempty"`
+	Status       string               `json:"status"`
+	Changed      bool                 `json:"changed"`
+	SizeBefore   int                  `json:"sizeBefore"`
+	SizeAfter    int                  `json:"sizeAfter"`
+	SHA256Before string               `json:"sha256Before,omitempty"`
+	SHA256After  string               `json:"sha256After,omitempty"`
+	Backup       string               `json:"backup,omitempty"`
+	Violations   []string             `json:"violations,omitempty"`
+	Swept        []cctidy.SweepRecord `json:"swept"`
+	Warnings     []cctidy.SweepRecord `json:"warnings"`
+	Stats        cctidy.Summarizer    `json:"stats,omitempty"`
+}
+
+func newTargetReport(r *fileResult) targetReport {
+	changed := r.result.Changed
+	status := statusUnchanged
+	if changed {
+		status = statusChanged
+	}
+	tr := targetReport{
+		Path:         r.path,
+		Status:       status,
+		Changed:      changed,
+		SizeBefore:   len(r.original),
+		SizeAfter:    len(r.result.Data),
+		SHA256Before: sha256Hex(r.original),
+		SHA256After:  sha256Hex(r.result.Data),
+		Backup:       r.backupPath,
+		Swept:        []cctidy.SweepRecord{},
+		Warnings:     []cctidy.SweepRecord{},
+		Stats:        r.result.Stats,
+	}
+	for _, rec := range statsRecords(r.result.Stats) {
+		switch rec.Decision {
+		case cctidy.DecisionSwept:
+			tr.Swept = append(tr.Swept, rec)
+		case cctidy.DecisionWarned:
+			tr.Warnings = append(tr.Warnings, rec)
+		}
+	}
+	return tr
+}
+
+// inProject returns tr tagged with the project it belongs to in
+// --all-projects mode.
+func (tr targetReport) inProject(project string) targetReport {
+	tr.Project = project
+	return tr
+}
+
+// skippedReport describes a target that was not found in
+// multi-target mode.
+func skippedReport(path string) targetReport {
+	return targetReport{
+		Path:     path,
+		Status:   statusSkipped,
+		Swept:    []cctidy.SweepRecord{},
+		Warnings: []cctidy.SweepRecord{},
+	}
+}
+
+// blockedReport describes a target that was not written because
+// a safety threshold was exceeded.
+func blockedReport(path string, violations []cctidy.LimitViolation) targetReport {
+	tr := skippedReport(path)
+	tr.Status = statusBlocked
+	for _, v := range violations {
+		tr.Violations = append(tr.Violations, v.String())
+	}
+	return tr
+}
+
+// statsRecords returns the sweep records carried by known stats types.
+func statsRecords(s cctidy.Summarizer) []cctidy.SweepRecord {
+	switch st := s.(type) {
+	case *cctidy.ClaudeJSONFormatterStats:
+		return st.Records
+	case *cctidy.SettingsJSONFormatterStats:
+		return st.Records
+	}
+	return nil
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// mode returns the run mode name used in JSON output.
+func (c *CLI) mode() string {
+	switch {
+	case c.Check:
+		return "check"
+	case c.DryRun:
+		return "dry-run"
+	default:
+		return "write"
+	}
+}
+
+func writeReport(w io.Writer, rep runReport) error {
+	if rep.Targets == nil {
+		rep.Targets = []targetReport{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return enc.Encode(rep)
+}
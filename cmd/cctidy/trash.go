@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"text/tabwriter"
+	"time"
+
+	"github.com/708u/cctidy"
+	"github.com/708u/cctidy/internal/jsonpatch"
+)
+
+type trashCmd struct {
+	List    trashListCmd    `cmd:"" help:"List entries recorded in the trash journal."`
+	Restore trashRestoreCmd `cmd:"" help:"Restore entries from the trash journal."`
+}
+
+type trashListCmd struct{}
+
+func (trashListCmd) Run(ctx context.Context, c *CLI) error {
+	return c.RunTrashList(ctx)
+}
+
+type trashRestoreCmd struct {
+	IDs   []string `arg:"" optional:"" name:"id" help:"IDs of trash items to restore."`
+	Since string   `help:"Restore items removed since a time (RFC 3339) or a duration ago (e.g. 2h)."`
+}
+
+func (trashRestoreCmd) Run(ctx context.Context, c *CLI) error {
+	return c.RunTrashRestore(ctx)
+}
+
+// recordTrash appends every entry swept from path to the trash
+// journal. Values of object-keyed categories (projects, enabled
+// plugins, marketplace declarations) are looked up in the original
+// data so that they can be restored in full.
+func (c *CLI) recordTrash(path string, original []byte, result *cctidy.FormatResult) error {
+	if c.trash == nil {
+		return nil
+	}
+	var swept []cctidy.SweepRecord
+	for _, rec := range statsRecords(result.Stats) {
+		if rec.Decision == cctidy.DecisionSwept {
+			swept = append(swept, rec)
+		}
+	}
+	if len(swept) == 0 {
+		return nil
+	}
+
+	var doc map[string]any
+	if v, err := jsonpatch.Decode(original); err == nil {
+		doc, _ = v.(map[string]any)
+	}
+
+	now := time.Now()
+	items := make([]cctidy.TrashItem, 0, len(swept))
+	for _, rec := range swept {
+		it := cctidy.TrashItem{
+			ID:       cctidy.NewTrashID(),
+			Time:     now,
+			File:     path,
+			Category: rec.Category,
+			Key:      rec.Key,
+			Entry:    rec.Entry,
+			Reason:   rec.Reason,
+		}
+		if cctidy.IsObjectCategory(rec.Category) {
+			parent, _ := doc[rec.Category].(map[string]any)
+			if v, ok := parent[rec.Entry]; ok {
+				data, err := json.Marshal(v)
+				if err != nil {
+					return fmt.Errorf("encoding %s %s: %w", rec.Category, rec.Entry, err)
+				}
+				it.Value = data
+			}
+		}
+		items = append(items, it)
+	}
+	return c.trash.Append(items)
+}
+
+// RunTrashList prints the trash journal.
+func (c *CLI) RunTrashList(_ context.Context) error {
+	items, err := c.trash.Load()
+	if err != nil {
+		return err
+	}
+	if c.jsonOutput() {
+		if items == nil {
+			items = []cctidy.TrashItem{}
+		}
+		enc := json.NewEncoder(c.out)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		return enc.Encode(items)
+	}
+	if len(items) == 0 {
+		fmt.Fprintln(c.out, "trash is empty")
+		return nil
+	}
+	tw := tabwriter.NewWriter(c.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tREMOVED\tFILE\tCATEGORY\tENTRY\tREASON\tSTATUS")
+	for _, it := range items {
+		entry := it.Entry
+		if it.Key != "" {
+			entry = it.Key + ": " + it.Entry
+		}
+		status := "trashed"
+		if it.Restored != nil {
+			status = "restored"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			it.ID, it.Time.Local().Format(time.DateTime), it.File, it.Category, entry, it.Reason, status)
+	}
+	return tw.Flush()
+}
+
+// RunTrashRestore puts the selected trash items back into their
+// files. All files are checked first and only written when every
+// one of them can be restored.
+func (c *CLI) RunTrashRestore(ctx context.Context) error {
+	items, err := c.trash.Load()
+	if err != nil {
+		return err
+	}
+	selected, err := c.Trash.Restore.selectItems(items, time.Now())
+	if err != nil {
+		return err
+	}
+	if len(selected) == 0 {
+		fmt.Fprintln(c.w, "cctidy: no trash items to restore")
+		return nil
+	}
+
+	byFile := map[string][]cctidy.TrashItem{}
+	for _, it := range selected {
+		byFile[it.File] = append(byFile[it.File], it)
+	}
+	paths := slices.Sorted(maps.Keys(byFile))
+	for _, path := range paths {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if _, err := restoreData(ctx, path, data, byFile[path]); err != nil {
+			return err
+		}
+	}
+
+	var ids []string
+	for _, path := range paths {
+		if !c.DryRun {
+			err := c.updateFile(ctx, path, func(data []byte) ([]byte, error) {
+				return restoreData(ctx, path, data, byFile[path])
+			}, nil)
+			if err != nil {
+				// Keep the journal in step with files already written.
+				return errors.Join(err, c.trash.MarkRestored(ids, time.Now()))
+			}
+			for _, it := range byFile[path] {
+				ids = append(ids, it.ID)
+			}
+		}
+		if c.Verbose {
+			fmt.Fprintf(c.w, "%s: restored %d entries\n", path, len(byFile[path]))
+		}
+	}
+	return c.trash.MarkRestored(ids, time.Now())
+}
+
+// restoreData inserts items into data, the content of path, and
+// formats the result as a run without sweeping would, so arrays
+// in settings files are sorted again.
+func restoreData(ctx context.Context, path string, data []byte, items []cctidy.TrashItem) ([]byte, error) {
+	out, err := cctidy.RestoreTrash(data, items)
+	if err != nil {
+		return nil, fmt.Errorf("restoring into %s: %w", path, err)
+	}
+	result, err := restoreFormatter(path).Format(ctx, out)
+	if err != nil {
+		return nil, fmt.Errorf("formatting %s: %w", path, err)
+	}
+	return result.Data, nil
+}
+
+// restoreFormatter returns the formatter for path with sweeping
+// disabled, so restored entries are not removed again.
+func restoreFormatter(path string) Formatter {
+	if filepath.Base(path) == ".claude.json" {
+		return cctidy.NewClaudeJSONFormatter(nil)
+	}
+	return cctidy.NewSettingsJSONFormatter(nil)
+}
+
+// selectItems returns the items named by IDs, or those removed
+// since --since that have not been restored yet.
+func (r *trashRestoreCmd) selectItems(items []cctidy.TrashItem, now time.Time) ([]cctidy.TrashItem, error) {
+	switch {
+	case len(r.IDs) > 0 && r.Since != "":
+		return nil, errors.New("specify either trash IDs or --since, not both")
+	case len(r.IDs) > 0:
+		var selected []cctidy.TrashItem
+		for _, id := range r.IDs {
+			i := slices.IndexFunc(items, func(it cctidy.TrashItem) bool { return it.ID == id })
+			if i < 0 {
+				return nil, fmt.Errorf("unknown trash ID %q", id)
+			}
+			selected = append(selected, items[i])
+		}
+		return selected, nil
+	case r.Since != "":
+		since, err := parseTime(r.Since, now)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --since: %w", err)
+		}
+		var selected []cctidy.TrashItem
+		for _, it := range items {
+			if it.Restored == nil && !it.Time.Before(since) {
+				selected = append(selected, it)
+			}
+		}
+		return selected, nil
+	default:
+		return nil, errors.New("specify trash IDs or --since")
+	}
+}
+
+// parseTime accepts an RFC 3339 timestamp or a duration that is
+// subtracted from now.
+func parseTime(s string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return time.Time{}, fmt.Errorf("want RFC 3339 time or duration, got %q", s)
+	}
+	return now.Add(-d), nil
+}
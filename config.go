@@ -2,9 +2,13 @@ package cctidy
 
 import (
 	"fmt"
+	"maps"
 	"os"
 	"path/filepath"
 	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	toml "github.com/pelletier/go-toml/v2"
 )
@@ -12,6 +16,92 @@ import (
 // Config holds the cctidy configuration loaded from TOML.
 type Config struct {
 	Permission PermissionConfig `toml:"permission"`
+	Paths      PathsConfig      `toml:"paths"`
+	Safety     SafetyConfig     `toml:"safety"`
+	Backup     BackupConfig     `toml:"backup"`
+}
+
+// BackupConfig controls where backups are stored and how long they
+// are kept. It is read from the user config only.
+type BackupConfig struct {
+	// Dir is the backup directory. Empty means DefaultBackupDir.
+	// A leading ~/ is expanded to the home directory.
+	Dir string `toml:"dir"`
+	// Compress stores backups gzip-compressed.
+	Compress bool `toml:"compress"`
+	// Keep is the number of backups kept per file. Zero keeps all.
+	Keep int `toml:"keep"`
+	// MaxAge removes backups older than this. Zero keeps all.
+	MaxAge time.Duration `toml:"-"`
+	// Auto backs up a file whenever a run removes entries from it,
+	// even without --backup.
+	Auto bool `toml:"auto"`
+}
+
+// Retention returns the retention policy described by the config.
+func (c BackupConfig) Retention() RetentionPolicy {
+	return RetentionPolicy{Keep: c.Keep, MaxAge: c.MaxAge}
+}
+
+// SafetyConfig holds per-file thresholds that abort a run which
+// would remove too many entries at once.
+type SafetyConfig struct {
+	// Projects limits removals from ~/.claude.json projects.
+	Projects SafetyLimit `toml:"projects"`
+	// RepoPaths limits removals from ~/.claude.json githubRepoPaths.
+	RepoPaths SafetyLimit `toml:"repo_paths"`
+	// Permissions limits removals from settings allow and ask lists.
+	Permissions SafetyLimit `toml:"permissions"`
+	// Plugins limits removals from settings enabledPlugins.
+	Plugins SafetyLimit `toml:"plugins"`
+	// Marketplaces limits removals from settings
+	// extraKnownMarketplaces.
+	Marketplaces SafetyLimit `toml:"marketplaces"`
+}
+
+// SafetyLimit bounds the number of entries removed from one
+// category of a single file. Zero fields are unlimited. Categories
+// not set in the config use DefaultSafety.
+type SafetyLimit struct {
+	// MaxCount is the maximum number of removed entries.
+	MaxCount int `toml:"max_count"`
+	// MaxPercent is the maximum share of entries removed (1-100).
+	// It applies only when more than SafetyPercentFloor entries
+	// are removed.
+	MaxPercent int `toml:"max_percent"`
+}
+
+// PathsConfig controls how path existence is determined.
+type PathsConfig struct {
+	// VolatileRoots lists directories whose contents are never swept,
+	// such as mount points of removable or encrypted volumes.
+	// Entries under these roots are kept and reported as skipped.
+	VolatileRoots []string `toml:"volatile_roots"`
+
+	// GraceRuns is the number of consecutive runs a path must be
+	// missing before entries referencing it are swept.
+	// Zero or one sweeps on the first run.
+	GraceRuns int `toml:"grace_runs"`
+
+	// GracePeriod is the minimum time a path must have been missing
+	// before entries referencing it are swept.
+	GracePeriod time.Duration `toml:"-"`
+
+	// CheckTimeout bounds each path existence check. Paths whose
+	// check does not finish in time are kept as unknown.
+	// Zero disables the deadline. It is read from the user config
+	// only.
+	CheckTimeout time.Duration `toml:"-"`
+
+	// ExpandVars lists environment variables expanded in Read, Edit
+	// and Bash path specifiers. Defaults to DefaultExpandVars when
+	// unset. Entries referencing other variables are kept.
+	ExpandVars []string `toml:"expand_vars"`
+}
+
+// GracePolicy returns the grace policy described by the config.
+func (c PathsConfig) GracePolicy() GracePolicy {
+	return GracePolicy{Runs: c.GraceRuns, Duration: c.GracePeriod}
 }
 
 // PermissionConfig groups per-tool permission sweep settings.
@@ -19,6 +109,26 @@ type PermissionConfig struct {
 	// Bash configures sweeping for Bash permission entries.
 	// "bash" corresponds to the Bash tool name in Claude Code permissions.
 	Bash BashPermissionConfig `toml:"bash"`
+
+	// Glob configures sweeping of Read/Edit entries whose specifier
+	// contains glob characters.
+	Glob GlobPermissionConfig `toml:"glob"`
+}
+
+// GlobPermissionConfig controls sweeping of Read/Edit glob entries.
+type GlobPermissionConfig struct {
+	// Enabled sweeps glob entries whose base directory (the part
+	// before the first glob character) no longer exists.
+	Enabled bool `toml:"enabled"`
+
+	// SweepUnmatched also sweeps glob entries that match no file.
+	// Requires Enabled.
+	SweepUnmatched bool `toml:"sweep_unmatched"`
+
+	// WalkBudget bounds the number of directory entries visited
+	// per pattern. Entries whose walk exceeds it are kept.
+	// Zero uses DefaultGlobWalkBudget.
+	WalkBudget int `toml:"walk_budget"`
 }
 
 // BashAllowConfig holds settings scoped to the allow permission category.
@@ -46,6 +156,18 @@ type BashPermissionConfig struct {
 	// ExcludePaths lists path prefixes to exclude.
 	// Trailing / is recommended to ensure directory boundary matching.
 	ExcludePaths []string `toml:"exclude_paths"`
+
+	// CheckExecutables sweeps entries whose program (the first
+	// token of a command) cannot be found.
+	CheckExecutables bool `toml:"check_executables"`
+
+	// SearchPath lists directories searched for bare command names.
+	// When empty, the PATH environment variable is used.
+	SearchPath []string `toml:"search_path"`
+
+	// KeepExecutables lists programs that are never reported
+	// missing, matched against the first token as written.
+	KeepExecutables []string `toml:"keep_executables"`
 }
 
 type rawBashAllowConfig struct {
@@ -54,19 +176,74 @@ type rawBashAllowConfig struct {
 
 // rawBashPermissionConfig uses *bool to distinguish "unset" from "false".
 type rawBashPermissionConfig struct {
-	Enabled         *bool              `toml:"enabled"`
-	Allow           rawBashAllowConfig `toml:"allow"`
-	ExcludeEntries  []string           `toml:"exclude_entries"`
-	ExcludeCommands []string           `toml:"exclude_commands"`
-	ExcludePaths    []string           `toml:"exclude_paths"`
+	Enabled          *bool              `toml:"enabled"`
+	Allow            rawBashAllowConfig `toml:"allow"`
+	ExcludeEntries   []string           `toml:"exclude_entries"`
+	ExcludeCommands  []string           `toml:"exclude_commands"`
+	ExcludePaths     []string           `toml:"exclude_paths"`
+	CheckExecutables *bool              `toml:"check_executables"`
+	SearchPath       []string           `toml:"search_path"`
+	KeepExecutables  []string           `toml:"keep_executables"`
+}
+
+type rawGlobPermissionConfig struct {
+	Enabled        *bool `toml:"enabled"`
+	SweepUnmatched *bool `toml:"sweep_unmatched"`
+	WalkBudget     *int  `toml:"walk_budget"`
 }
 
 type rawPermissionConfig struct {
 	Bash rawBashPermissionConfig `toml:"bash"`
+	Glob rawGlobPermissionConfig `toml:"glob"`
+}
+
+// rawPathsConfig keeps grace_period as a string so that it can be
+// validated with a helpful error; see parseDuration.
+type rawPathsConfig struct {
+	VolatileRoots []string `toml:"volatile_roots"`
+	GraceRuns     *int     `toml:"grace_runs"`
+	GracePeriod   *string  `toml:"grace_period"`
+	CheckTimeout  *string  `toml:"check_timeout"`
+	ExpandVars    []string `toml:"expand_vars"`
+}
+
+type rawSafetyLimit struct {
+	MaxCount   *int `toml:"max_count"`
+	MaxPercent *int `toml:"max_percent"`
+}
+
+type rawSafetyConfig struct {
+	Projects     rawSafetyLimit `toml:"projects"`
+	RepoPaths    rawSafetyLimit `toml:"repo_paths"`
+	Permissions  rawSafetyLimit `toml:"permissions"`
+	Plugins      rawSafetyLimit `toml:"plugins"`
+	Marketplaces rawSafetyLimit `toml:"marketplaces"`
+}
+
+func (r rawSafetyConfig) limits() map[string]rawSafetyLimit {
+	return map[string]rawSafetyLimit{
+		"projects":     r.Projects,
+		"repo_paths":   r.RepoPaths,
+		"permissions":  r.Permissions,
+		"plugins":      r.Plugins,
+		"marketplaces": r.Marketplaces,
+	}
+}
+
+// rawBackupConfig keeps max_age as a string; see parseDuration.
+type rawBackupConfig struct {
+	Dir      *string `toml:"dir"`
+	Compress *bool   `toml:"compress"`
+	Keep     *int    `toml:"keep"`
+	MaxAge   *string `toml:"max_age"`
+	Auto     *bool   `toml:"auto"`
 }
 
 type rawConfig struct {
 	Permission rawPermissionConfig `toml:"permission"`
+	Paths      rawPathsConfig      `toml:"paths"`
+	Safety     rawSafetyConfig     `toml:"safety"`
+	Backup     rawBackupConfig     `toml:"backup"`
 }
 
 // defaultConfigPath returns ~/.config/cctidy/config.toml.
@@ -93,6 +270,40 @@ func loadRawConfig(path string) (rawConfig, error) {
 	if err := toml.Unmarshal(data, &raw); err != nil {
 		return rawConfig{}, fmt.Errorf("parsing config %s: %w", path, err)
 	}
+	if raw.Paths.GracePeriod != nil {
+		if _, err := parseDuration(*raw.Paths.GracePeriod); err != nil {
+			return rawConfig{}, fmt.Errorf("parsing config %s: grace_period: %w", path, err)
+		}
+	}
+	if raw.Paths.CheckTimeout != nil {
+		if _, err := parseDuration(*raw.Paths.CheckTimeout); err != nil {
+			return rawConfig{}, fmt.Errorf("parsing config %s: check_timeout: %w", path, err)
+		}
+	}
+	for _, name := range raw.Paths.ExpandVars {
+		if name == "" || varNameLen(name) != len(name) {
+			return rawConfig{}, fmt.Errorf("parsing config %s: expand_vars: invalid variable name %q", path, name)
+		}
+	}
+	if raw.Permission.Glob.WalkBudget != nil && *raw.Permission.Glob.WalkBudget < 0 {
+		return rawConfig{}, fmt.Errorf("parsing config %s: permission.glob.walk_budget must not be negative", path)
+	}
+	if raw.Paths.GraceRuns != nil && *raw.Paths.GraceRuns < 0 {
+		return rawConfig{}, fmt.Errorf("parsing config %s: grace_runs must not be negative", path)
+	}
+	if err := validateBackup(raw.Backup); err != nil {
+		return rawConfig{}, fmt.Errorf("parsing config %s: %w", path, err)
+	}
+	limits := raw.Safety.limits()
+	for _, name := range slices.Sorted(maps.Keys(limits)) {
+		l := limits[name]
+		if l.MaxCount != nil && *l.MaxCount < 0 {
+			return rawConfig{}, fmt.Errorf("parsing config %s: safety.%s.max_count must not be negative", path, name)
+		}
+		if l.MaxPercent != nil && (*l.MaxPercent < 0 || *l.MaxPercent > 100) {
+			return rawConfig{}, fmt.Errorf("parsing config %s: safety.%s.max_percent must be between 0 and 100", path, name)
+		}
+	}
 	return raw, nil
 }
 
@@ -106,9 +317,142 @@ func rawToConfig(raw rawConfig) *Config {
 	cfg.Permission.Bash.ExcludeEntries = raw.Permission.Bash.ExcludeEntries
 	cfg.Permission.Bash.ExcludeCommands = raw.Permission.Bash.ExcludeCommands
 	cfg.Permission.Bash.ExcludePaths = raw.Permission.Bash.ExcludePaths
+	if raw.Permission.Bash.CheckExecutables != nil {
+		cfg.Permission.Bash.CheckExecutables = *raw.Permission.Bash.CheckExecutables
+	}
+	cfg.Permission.Bash.SearchPath = raw.Permission.Bash.SearchPath
+	cfg.Permission.Bash.KeepExecutables = raw.Permission.Bash.KeepExecutables
+	applyGlob(&cfg.Permission.Glob, raw.Permission.Glob)
+	cfg.Paths.VolatileRoots = raw.Paths.VolatileRoots
+	applyGrace(&cfg.Paths, raw.Paths)
+	cfg.Paths.CheckTimeout = DefaultCheckTimeout
+	if raw.Paths.CheckTimeout != nil {
+		cfg.Paths.CheckTimeout, _ = parseDuration(*raw.Paths.CheckTimeout)
+	}
+	cfg.Paths.ExpandVars = raw.Paths.ExpandVars
+	if cfg.Paths.ExpandVars == nil {
+		cfg.Paths.ExpandVars = slices.Clone(DefaultExpandVars)
+	}
+	cfg.Safety = DefaultSafety
+	applySafety(&cfg.Safety, raw.Safety)
+	applyBackup(&cfg.Backup, raw.Backup)
 	return cfg
 }
 
+// validateBackup checks the backup section of a raw config.
+func validateBackup(raw rawBackupConfig) error {
+	if raw.Dir != nil && *raw.Dir != "" && !filepath.IsAbs(*raw.Dir) && !strings.HasPrefix(*raw.Dir, "~/") {
+		return fmt.Errorf("backup.dir must be absolute or start with ~/")
+	}
+	if raw.Keep != nil && *raw.Keep < 0 {
+		return fmt.Errorf("backup.keep must not be negative")
+	}
+	if raw.MaxAge != nil {
+		if _, err := parseDuration(*raw.MaxAge); err != nil {
+			return fmt.Errorf("backup.max_age: %w", err)
+		}
+	}
+	return nil
+}
+
+// applyBackup fills cfg from raw, using defaults for unset fields.
+// raw must have been validated by loadRawConfig.
+func applyBackup(cfg *BackupConfig, raw rawBackupConfig) {
+	cfg.Keep = DefaultBackupKeep
+	cfg.Auto = true
+	if raw.Dir != nil {
+		cfg.Dir = *raw.Dir
+	}
+	if raw.Compress != nil {
+		cfg.Compress = *raw.Compress
+	}
+	if raw.Keep != nil {
+		cfg.Keep = *raw.Keep
+	}
+	if raw.MaxAge != nil {
+		cfg.MaxAge, _ = parseDuration(*raw.MaxAge)
+	}
+	if raw.Auto != nil {
+		cfg.Auto = *raw.Auto
+	}
+}
+
+// applySafety copies safety limits that are set in raw onto cfg.
+func applySafety(cfg *SafetyConfig, raw rawSafetyConfig) {
+	apply := func(l *SafetyLimit, r rawSafetyLimit) {
+		if r.MaxCount != nil {
+			l.MaxCount = *r.MaxCount
+		}
+		if r.MaxPercent != nil {
+			l.MaxPercent = *r.MaxPercent
+		}
+	}
+	apply(&cfg.Projects, raw.Projects)
+	apply(&cfg.RepoPaths, raw.RepoPaths)
+	apply(&cfg.Permissions, raw.Permissions)
+	apply(&cfg.Plugins, raw.Plugins)
+	apply(&cfg.Marketplaces, raw.Marketplaces)
+}
+
+// mergeRawGlob returns overlay fields where set, base otherwise.
+func mergeRawGlob(base, overlay rawGlobPermissionConfig) rawGlobPermissionConfig {
+	merged := base
+	if overlay.Enabled != nil {
+		merged.Enabled = overlay.Enabled
+	}
+	if overlay.SweepUnmatched != nil {
+		merged.SweepUnmatched = overlay.SweepUnmatched
+	}
+	if overlay.WalkBudget != nil {
+		merged.WalkBudget = overlay.WalkBudget
+	}
+	return merged
+}
+
+// applyGlob copies glob settings that are set in raw onto cfg.
+func applyGlob(cfg *GlobPermissionConfig, raw rawGlobPermissionConfig) {
+	if raw.Enabled != nil {
+		cfg.Enabled = *raw.Enabled
+	}
+	if raw.SweepUnmatched != nil {
+		cfg.SweepUnmatched = *raw.SweepUnmatched
+	}
+	if raw.WalkBudget != nil {
+		cfg.WalkBudget = *raw.WalkBudget
+	}
+}
+
+// applyGrace copies grace settings that are set in raw onto cfg.
+// raw must have been validated by loadRawConfig.
+func applyGrace(cfg *PathsConfig, raw rawPathsConfig) {
+	if raw.GraceRuns != nil {
+		cfg.GraceRuns = *raw.GraceRuns
+	}
+	if raw.GracePeriod != nil {
+		cfg.GracePeriod, _ = parseDuration(*raw.GracePeriod)
+	}
+}
+
+// parseDuration parses a Go duration string, additionally
+// accepting a whole number of days such as "7d".
+func parseDuration(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	return d, nil
+}
+
 // unionStrings returns the union of two string slices with duplicates removed.
 // Order is preserved: elements from a come first, then new elements from b.
 func unionStrings(a, b []string) []string {
@@ -144,6 +488,27 @@ func mergeRawConfigs(base, overlay rawConfig) rawConfig {
 		base.Permission.Bash.ExcludeCommands, overlay.Permission.Bash.ExcludeCommands)
 	merged.Permission.Bash.ExcludePaths = unionStrings(
 		base.Permission.Bash.ExcludePaths, overlay.Permission.Bash.ExcludePaths)
+	merged.Permission.Bash.CheckExecutables = overlay.Permission.Bash.CheckExecutables
+	if merged.Permission.Bash.CheckExecutables == nil {
+		merged.Permission.Bash.CheckExecutables = base.Permission.Bash.CheckExecutables
+	}
+	merged.Permission.Bash.SearchPath = unionStrings(
+		base.Permission.Bash.SearchPath, overlay.Permission.Bash.SearchPath)
+	merged.Permission.Bash.KeepExecutables = unionStrings(
+		base.Permission.Bash.KeepExecutables, overlay.Permission.Bash.KeepExecutables)
+	merged.Permission.Glob = mergeRawGlob(base.Permission.Glob, overlay.Permission.Glob)
+	merged.Paths.VolatileRoots = unionStrings(
+		base.Paths.VolatileRoots, overlay.Paths.VolatileRoots)
+	merged.Paths.ExpandVars = unionStrings(
+		base.Paths.ExpandVars, overlay.Paths.ExpandVars)
+	merged.Paths.GraceRuns = overlay.Paths.GraceRuns
+	if merged.Paths.GraceRuns == nil {
+		merged.Paths.GraceRuns = base.Paths.GraceRuns
+	}
+	merged.Paths.GracePeriod = overlay.Paths.GracePeriod
+	if merged.Paths.GracePeriod == nil {
+		merged.Paths.GracePeriod = base.Paths.GracePeriod
+	}
 
 	return merged
 }
@@ -156,7 +521,7 @@ func LoadConfig(path string) (*Config, error) {
 		var err error
 		path, err = defaultConfigPath()
 		if err != nil {
-			return &Config{}, nil
+			return rawToConfig(rawConfig{}), nil
 		}
 	}
 
@@ -185,11 +550,13 @@ func LoadProjectConfig(projectRoot string) (rawConfig, error) {
 }
 
 // MergeConfig merges a project rawConfig on top of a global Config.
-// Relative paths in the project config's ExcludePaths are resolved
-// against projectRoot before merging.
+// Relative paths in the project config's ExcludePaths, SearchPath
+// and VolatileRoots are resolved against projectRoot before merging.
+// A nil base is treated as an empty global config, so the same
+// defaults apply as when no config file exists.
 func MergeConfig(base *Config, project rawConfig, projectRoot string) *Config {
 	if base == nil {
-		base = &Config{}
+		base = rawToConfig(rawConfig{})
 	}
 
 	merged := &Config{}
@@ -208,16 +575,53 @@ func MergeConfig(base *Config, project rawConfig, projectRoot string) *Config {
 	merged.Permission.Bash.ExcludeCommands = unionStrings(
 		base.Permission.Bash.ExcludeCommands, project.Permission.Bash.ExcludeCommands)
 
-	// Resolve relative paths in project config against projectRoot
-	resolvedPaths := make([]string, 0, len(project.Permission.Bash.ExcludePaths))
-	for _, p := range project.Permission.Bash.ExcludePaths {
+	merged.Permission.Bash.ExcludePaths = unionStrings(
+		base.Permission.Bash.ExcludePaths, resolvePaths(project.Permission.Bash.ExcludePaths, projectRoot))
+
+	// Executable check: project wins if explicitly set
+	merged.Permission.Bash.CheckExecutables = base.Permission.Bash.CheckExecutables
+	if project.Permission.Bash.CheckExecutables != nil {
+		merged.Permission.Bash.CheckExecutables = *project.Permission.Bash.CheckExecutables
+	}
+	merged.Permission.Bash.SearchPath = unionStrings(
+		base.Permission.Bash.SearchPath, resolvePaths(project.Permission.Bash.SearchPath, projectRoot))
+	merged.Permission.Bash.KeepExecutables = unionStrings(
+		base.Permission.Bash.KeepExecutables, project.Permission.Bash.KeepExecutables)
+
+	// Glob settings: project wins per field if explicitly set
+	merged.Permission.Glob = base.Permission.Glob
+	applyGlob(&merged.Permission.Glob, project.Permission.Glob)
+	merged.Paths.VolatileRoots = unionStrings(
+		base.Paths.VolatileRoots, resolvePaths(project.Paths.VolatileRoots, projectRoot))
+	merged.Paths.ExpandVars = unionStrings(
+		base.Paths.ExpandVars, project.Paths.ExpandVars)
+
+	// Grace settings: project wins if explicitly set
+	merged.Paths.GraceRuns = base.Paths.GraceRuns
+	merged.Paths.GracePeriod = base.Paths.GracePeriod
+	applyGrace(&merged.Paths, project.Paths)
+
+	// Safety limits are user-level only, so a checked-in project
+	// config cannot loosen them
+	merged.Safety = base.Safety
+
+	// Check timeout is user-level only
+	merged.Paths.CheckTimeout = base.Paths.CheckTimeout
+
+	// Backup settings are user-level only
+	merged.Backup = base.Backup
+
+	return merged
+}
+
+// resolvePaths joins relative paths in a project config with projectRoot.
+func resolvePaths(paths []string, projectRoot string) []string {
+	resolved := make([]string, 0, len(paths))
+	for _, p := range paths {
 		if !filepath.IsAbs(p) {
 			p = filepath.Join(projectRoot, p)
 		}
-		resolvedPaths = append(resolvedPaths, p)
+		resolved = append(resolved, p)
 	}
-	merged.Permission.Bash.ExcludePaths = unionStrings(
-		base.Permission.Bash.ExcludePaths, resolvedPaths)
-
-	return merged
+	return resolved
 }
@@ -5,6 +5,7 @@ import (
 	"path/filepath"
 	"slices"
 	"testing"
+	"time"
 )
 
 func TestLoadConfig(t *testing.T) {
@@ -81,6 +82,226 @@ remove_commands = ["npm", "pip"]
 		}
 	})
 
+	t.Run("paths volatile_roots", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		path := filepath.Join(dir, "config.toml")
+		os.WriteFile(path, []byte("[paths]\nvolatile_roots = [\"/media/usb\", \"/Volumes/Work\"]\n"), 0o644)
+
+		cfg, err := LoadConfig(path)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := []string{"/media/usb", "/Volumes/Work"}
+		if !slices.Equal(cfg.Paths.VolatileRoots, want) {
+			t.Errorf("VolatileRoots = %v, want %v", cfg.Paths.VolatileRoots, want)
+		}
+	})
+
+	t.Run("paths grace settings", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		path := filepath.Join(dir, "config.toml")
+		os.WriteFile(path, []byte("[paths]\ngrace_runs = 3\ngrace_period = \"7d\"\n"), 0o644)
+
+		cfg, err := LoadConfig(path)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := GracePolicy{Runs: 3, Duration: 7 * 24 * time.Hour}
+		if got := cfg.Paths.GracePolicy(); got != want {
+			t.Errorf("GracePolicy() = %+v, want %+v", got, want)
+		}
+	})
+
+	t.Run("invalid grace_period returns error", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		path := filepath.Join(dir, "config.toml")
+		os.WriteFile(path, []byte("[paths]\ngrace_period = \"soon\"\n"), 0o644)
+
+		if _, err := LoadConfig(path); err == nil {
+			t.Fatal("expected error for invalid grace_period")
+		}
+	})
+
+	t.Run("check_timeout defaults and overrides", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		cfg, err := LoadConfig(filepath.Join(dir, "missing.toml"))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if cfg.Paths.CheckTimeout != DefaultCheckTimeout {
+			t.Errorf("default CheckTimeout = %s, want %s", cfg.Paths.CheckTimeout, DefaultCheckTimeout)
+		}
+
+		path := filepath.Join(dir, "config.toml")
+		os.WriteFile(path, []byte("[paths]\ncheck_timeout = \"500ms\"\n"), 0o644)
+		cfg, err = LoadConfig(path)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if cfg.Paths.CheckTimeout != 500*time.Millisecond {
+			t.Errorf("CheckTimeout = %s, want 500ms", cfg.Paths.CheckTimeout)
+		}
+	})
+
+	t.Run("invalid check_timeout returns error", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		path := filepath.Join(dir, "config.toml")
+		os.WriteFile(path, []byte("[paths]\ncheck_timeout = \"never\"\n"), 0o644)
+
+		if _, err := LoadConfig(path); err == nil {
+			t.Fatal("expected error for invalid check_timeout")
+		}
+	})
+
+	t.Run("expand_vars defaults and overrides", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		cfg, err := LoadConfig(filepath.Join(dir, "missing.toml"))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !slices.Equal(cfg.Paths.ExpandVars, DefaultExpandVars) {
+			t.Errorf("default ExpandVars = %v, want %v", cfg.Paths.ExpandVars, DefaultExpandVars)
+		}
+
+		path := filepath.Join(dir, "config.toml")
+		os.WriteFile(path, []byte("[paths]\nexpand_vars = [\"GOPATH\"]\n"), 0o644)
+		cfg, err = LoadConfig(path)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if want := []string{"GOPATH"}; !slices.Equal(cfg.Paths.ExpandVars, want) {
+			t.Errorf("ExpandVars = %v, want %v", cfg.Paths.ExpandVars, want)
+		}
+	})
+
+	t.Run("invalid expand_vars name returns error", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		path := filepath.Join(dir, "config.toml")
+		os.WriteFile(path, []byte("[paths]\nexpand_vars = [\"$HOME\"]\n"), 0o644)
+
+		if _, err := LoadConfig(path); err == nil {
+			t.Fatal("expected error for invalid expand_vars name")
+		}
+	})
+
+	t.Run("glob settings", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		path := filepath.Join(dir, "config.toml")
+		os.WriteFile(path, []byte("[permission.glob]\nenabled = true\nwalk_budget = 500\n"), 0o644)
+		cfg, err := LoadConfig(path)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := GlobPermissionConfig{Enabled: true, WalkBudget: 500}
+		if cfg.Permission.Glob != want {
+			t.Errorf("Glob = %+v, want %+v", cfg.Permission.Glob, want)
+		}
+	})
+
+	t.Run("negative walk_budget returns error", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		path := filepath.Join(dir, "config.toml")
+		os.WriteFile(path, []byte("[permission.glob]\nwalk_budget = -1\n"), 0o644)
+
+		if _, err := LoadConfig(path); err == nil {
+			t.Fatal("expected error for negative walk_budget")
+		}
+	})
+
+	t.Run("safety limits", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		path := filepath.Join(dir, "config.toml")
+		os.WriteFile(path, []byte("[safety.projects]\nmax_percent = 30\n\n[safety.repo_paths]\nmax_percent = 0\n\n[safety.permissions]\nmax_count = 10\n\n[safety.plugins]\nmax_count = 2\n"), 0o644)
+
+		cfg, err := LoadConfig(path)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := DefaultSafety
+		want.Projects = SafetyLimit{MaxPercent: 30}
+		want.RepoPaths = SafetyLimit{}
+		want.Permissions = SafetyLimit{MaxCount: 10}
+		want.Plugins = SafetyLimit{MaxCount: 2}
+		if cfg.Safety != want {
+			t.Errorf("Safety = %+v, want %+v", cfg.Safety, want)
+		}
+	})
+
+	t.Run("safety limits default when unset", func(t *testing.T) {
+		t.Parallel()
+		cfg, err := LoadConfig(filepath.Join(t.TempDir(), "missing.toml"))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if cfg.Safety != DefaultSafety {
+			t.Errorf("Safety = %+v, want %+v", cfg.Safety, DefaultSafety)
+		}
+	})
+
+	t.Run("invalid safety percent returns error", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		path := filepath.Join(dir, "config.toml")
+		os.WriteFile(path, []byte("[safety.repo_paths]\nmax_percent = 150\n"), 0o644)
+
+		if _, err := LoadConfig(path); err == nil {
+			t.Fatal("expected error for max_percent > 100")
+		}
+	})
+
+	t.Run("backup defaults", func(t *testing.T) {
+		t.Parallel()
+		cfg, err := LoadConfig(filepath.Join(t.TempDir(), "missing.toml"))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := BackupConfig{Keep: DefaultBackupKeep, Auto: true}
+		if cfg.Backup != want {
+			t.Errorf("Backup = %+v, want %+v", cfg.Backup, want)
+		}
+	})
+
+	t.Run("backup settings", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		path := filepath.Join(dir, "config.toml")
+		os.WriteFile(path, []byte("[backup]\ndir = \"~/backups\"\ncompress = true\nkeep = 3\nmax_age = \"30d\"\nauto = false\n"), 0o644)
+
+		cfg, err := LoadConfig(path)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := BackupConfig{Dir: "~/backups", Compress: true, Keep: 3, MaxAge: 30 * 24 * time.Hour}
+		if cfg.Backup != want {
+			t.Errorf("Backup = %+v, want %+v", cfg.Backup, want)
+		}
+	})
+
+	t.Run("invalid backup settings return error", func(t *testing.T) {
+		t.Parallel()
+		for _, body := range []string{
+			"[backup]\ndir = \"relative\"\n",
+			"[backup]\nkeep = -1\n",
+			"[backup]\nmax_age = \"soon\"\n",
+		} {
+			path := filepath.Join(t.TempDir(), "config.toml")
+			os.WriteFile(path, []byte(body), 0o644)
+			if _, err := LoadConfig(path); err == nil {
+				t.Errorf("expected error for %q", body)
+			}
+		}
+	})
+
 	t.Run("enabled false", func(t *testing.T) {
 		t.Parallel()
 		dir := t.TempDir()
@@ -282,6 +503,24 @@ func TestMergeConfig(t *testing.T) {
 		}
 	})
 
+	t.Run("nil base uses defaults", func(t *testing.T) {
+		t.Parallel()
+		got := MergeConfig(nil, rawConfig{}, "/project")
+		want := rawToConfig(rawConfig{})
+		if got.Backup != want.Backup {
+			t.Errorf("Backup = %+v, want %+v", got.Backup, want.Backup)
+		}
+		if got.Safety != DefaultSafety {
+			t.Errorf("Safety = %+v, want %+v", got.Safety, DefaultSafety)
+		}
+		if got.Paths.CheckTimeout != DefaultCheckTimeout {
+			t.Errorf("CheckTimeout = %v, want %v", got.Paths.CheckTimeout, DefaultCheckTimeout)
+		}
+		if !slices.Equal(got.Paths.ExpandVars, DefaultExpandVars) {
+			t.Errorf("ExpandVars = %v, want %v", got.Paths.ExpandVars, DefaultExpandVars)
+		}
+	})
+
 	t.Run("project Enabled overrides base", func(t *testing.T) {
 		t.Parallel()
 		base := &Config{}
@@ -349,6 +588,123 @@ func TestMergeConfig(t *testing.T) {
 			t.Errorf("paths: got %v, want %v", got.Permission.Bash.ExcludePaths, want)
 		}
 	})
+
+	t.Run("project grace settings override base", func(t *testing.T) {
+		t.Parallel()
+		base := &Config{}
+		base.Paths.GraceRuns = 2
+		base.Paths.GracePeriod = time.Hour
+		project := rawConfig{}
+		runs := 5
+		project.Paths.GraceRuns = &runs
+		got := MergeConfig(base, project, "/project")
+		want := GracePolicy{Runs: 5, Duration: time.Hour}
+		if got.Paths.GracePolicy() != want {
+			t.Errorf("GracePolicy() = %+v, want %+v", got.Paths.GracePolicy(), want)
+		}
+	})
+
+	t.Run("project cannot change safety limits", func(t *testing.T) {
+		t.Parallel()
+		base := &Config{}
+		base.Safety.Projects = SafetyLimit{MaxCount: 5, MaxPercent: 50}
+		base.Safety.Permissions = SafetyLimit{MaxCount: 20}
+		project := rawConfig{}
+		pct, zero := 100, 0
+		project.Safety.Projects.MaxPercent = &pct
+		project.Safety.Permissions.MaxCount = &zero
+		got := MergeConfig(base, project, "/project")
+		if got.Safety != base.Safety {
+			t.Errorf("Safety = %+v, want %+v", got.Safety, base.Safety)
+		}
+	})
+
+	t.Run("project cannot change check timeout", func(t *testing.T) {
+		t.Parallel()
+		base := &Config{}
+		base.Paths.CheckTimeout = time.Second
+		project := rawConfig{}
+		d := "1m"
+		project.Paths.CheckTimeout = &d
+		got := MergeConfig(base, project, "/project")
+		if got.Paths.CheckTimeout != time.Second {
+			t.Errorf("CheckTimeout = %s, want 1s", got.Paths.CheckTimeout)
+		}
+	})
+
+	t.Run("ExpandVars union", func(t *testing.T) {
+		t.Parallel()
+		base := &Config{}
+		base.Paths.ExpandVars = []string{"XDG_CONFIG_HOME"}
+		project := rawConfig{}
+		project.Paths.ExpandVars = []string{"GOPATH", "XDG_CONFIG_HOME"}
+		got := MergeConfig(base, project, "/project")
+		want := []string{"XDG_CONFIG_HOME", "GOPATH"}
+		if !slices.Equal(got.Paths.ExpandVars, want) {
+			t.Errorf("ExpandVars: got %v, want %v", got.Paths.ExpandVars, want)
+		}
+	})
+
+	t.Run("project cannot change backup settings", func(t *testing.T) {
+		t.Parallel()
+		base := &Config{Backup: BackupConfig{Keep: 5, Auto: true}}
+		project := rawConfig{}
+		dir := "/tmp/elsewhere"
+		project.Backup.Dir = &dir
+		got := MergeConfig(base, project, "/project")
+		if got.Backup != base.Backup {
+			t.Errorf("Backup = %+v, want %+v", got.Backup, base.Backup)
+		}
+	})
+
+	t.Run("glob settings override per field", func(t *testing.T) {
+		t.Parallel()
+		base := &Config{}
+		base.Permission.Glob = GlobPermissionConfig{Enabled: true, WalkBudget: 100}
+		project := rawConfig{}
+		on := true
+		project.Permission.Glob.SweepUnmatched = &on
+		got := MergeConfig(base, project, "/project")
+		want := GlobPermissionConfig{Enabled: true, SweepUnmatched: true, WalkBudget: 100}
+		if got.Permission.Glob != want {
+			t.Errorf("Glob = %+v, want %+v", got.Permission.Glob, want)
+		}
+	})
+
+	t.Run("executable check settings", func(t *testing.T) {
+		t.Parallel()
+		base := &Config{}
+		base.Permission.Bash.CheckExecutables = true
+		base.Permission.Bash.KeepExecutables = []string{"internal-cli"}
+		project := rawConfig{}
+		off := false
+		project.Permission.Bash.CheckExecutables = &off
+		project.Permission.Bash.SearchPath = []string{"bin", "/usr/bin"}
+		project.Permission.Bash.KeepExecutables = []string{"devtool"}
+		got := MergeConfig(base, project, "/myproject")
+		if got.Permission.Bash.CheckExecutables {
+			t.Error("CheckExecutables: project false should override base true")
+		}
+		if want := []string{"/myproject/bin", "/usr/bin"}; !slices.Equal(got.Permission.Bash.SearchPath, want) {
+			t.Errorf("SearchPath: got %v, want %v", got.Permission.Bash.SearchPath, want)
+		}
+		if want := []string{"internal-cli", "devtool"}; !slices.Equal(got.Permission.Bash.KeepExecutables, want) {
+			t.Errorf("KeepExecutables: got %v, want %v", got.Permission.Bash.KeepExecutables, want)
+		}
+	})
+
+	t.Run("VolatileRoots union with relative paths resolved", func(t *testing.T) {
+		t.Parallel()
+		base := &Config{}
+		base.Paths.VolatileRoots = []string{"/media/usb"}
+		project := rawConfig{}
+		project.Paths.VolatileRoots = []string{"mnt/cache", "/media/usb"}
+		got := MergeConfig(base, project, "/myproject")
+		want := []string{"/media/usb", "/myproject/mnt/cache"}
+		if !slices.Equal(got.Paths.VolatileRoots, want) {
+			t.Errorf("VolatileRoots: got %v, want %v", got.Paths.VolatileRoots, want)
+		}
+	})
 }
 
 func TestLoadProjectConfig(t *testing.T) {
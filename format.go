@@ -4,15 +4,22 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"maps"
 	"slices"
 	"strings"
+
+	"github.com/708u/cctidy/internal/pool"
 )
 
 // FormatResult holds the formatted output and statistics.
 type FormatResult struct {
 	Data  []byte
 	Stats Summarizer
+	// Changed reports whether Data differs from the input.
+	// Callers can skip writing the file when it is false.
+	Changed bool
 }
 
 // Summarizer produces a human-readable summary of formatting results.
@@ -21,14 +28,21 @@ type Summarizer interface {
 }
 
 // ClaudeJSONFormatterStats holds statistics for ~/.claude.json formatting.
+// It marshals to JSON with camelCase field names.
+// Records holds one swept record per removed project and repo path
+// and one warned record per path whose existence could not be
+// determined, sorted by project path and repository name.
+// Warns lists the warned paths.
 type ClaudeJSONFormatterStats struct {
-	ProjectsBefore int
-	ProjectsAfter  int
-	RepoBefore     int
-	RepoAfter      int
-	RemovedRepos   int
-	SizeBefore     int
-	SizeAfter      int
+	ProjectsBefore int           `json:"projectsBefore"`
+	ProjectsAfter  int           `json:"projectsAfter"`
+	RepoBefore     int           `json:"repoBefore"`
+	RepoAfter      int           `json:"repoAfter"`
+	RemovedRepos   int           `json:"removedRepos"`
+	SizeBefore     int           `json:"sizeBefore"`
+	SizeAfter      int           `json:"sizeAfter"`
+	Warns          []string      `json:"warns,omitempty"`
+	Records        []SweepRecord `json:"records,omitempty"`
 }
 
 func (s *ClaudeJSONFormatterStats) Summary() string {
@@ -41,18 +55,26 @@ func (s *ClaudeJSONFormatterStats) Summary() string {
 		fmt.Fprintf(&b, "GitHub repo paths: %d -> %d (removed %d paths, %d empty repos)\n",
 			s.RepoBefore, s.RepoAfter, removed, s.RemovedRepos)
 	}
+	for _, w := range s.Warns {
+		fmt.Fprintf(&b, "Skipped: %s\n", w)
+	}
 	fmt.Fprintf(&b, "Size: %s -> %s bytes\n",
 		formatComma(int64(s.SizeBefore)), formatComma(int64(s.SizeAfter)))
 	return b.String()
 }
 
 // SettingsJSONFormatterStats holds statistics for settings.json formatting.
+// It marshals to JSON with camelCase field names.
 type SettingsJSONFormatterStats struct {
-	SizeBefore int
-	SizeAfter  int
-	SweptAllow int
-	SweptAsk   int
-	Warns      []string
+	SizeBefore        int           `json:"sizeBefore"`
+	SizeAfter         int           `json:"sizeAfter"`
+	SweptAllow        int           `json:"sweptAllow"`
+	SweptAsk          int           `json:"sweptAsk"`
+	SweptDirectories  int           `json:"sweptDirectories,omitempty"`
+	SweptPlugins      int           `json:"sweptPlugins,omitempty"`
+	SweptMarketplaces int           `json:"sweptMarketplaces,omitempty"`
+	Warns             []string      `json:"warns,omitempty"`
+	Records           []SweepRecord `json:"records,omitempty"`
 }
 
 func (s *SettingsJSONFormatterStats) Summary() string {
@@ -62,6 +84,13 @@ func (s *SettingsJSONFormatterStats) Summary() string {
 		fmt.Fprintf(&b, "Swept: %d allow, %d ask entries\n",
 			s.SweptAllow, s.SweptAsk)
 	}
+	if s.SweptDirectories > 0 {
+		fmt.Fprintf(&b, "Swept: %d additional directories\n", s.SweptDirectories)
+	}
+	if s.SweptPlugins+s.SweptMarketplaces > 0 {
+		fmt.Fprintf(&b, "Swept: %d enabled plugins, %d marketplaces\n",
+			s.SweptPlugins, s.SweptMarketplaces)
+	}
 	for _, w := range s.Warns {
 		fmt.Fprintf(&b, "Skipped: %s\n", w)
 	}
@@ -72,9 +101,13 @@ func (s *SettingsJSONFormatterStats) Summary() string {
 
 // ClaudeJSONFormatter formats ~/.claude.json with path cleaning
 // (removing non-existent projects and GitHub repo paths)
-// and pretty-printing with 2-space indent.
+// and pretty-printing with 2-space indent. A nil PathChecker
+// skips path cleaning.
 type ClaudeJSONFormatter struct {
 	PathChecker PathChecker
+	// Jobs bounds the number of concurrent PathChecker calls.
+	// Values below 1 check paths one at a time.
+	Jobs int
 }
 
 func NewClaudeJSONFormatter(checker PathChecker) *ClaudeJSONFormatter {
@@ -82,8 +115,29 @@ func NewClaudeJSONFormatter(checker PathChecker) *ClaudeJSONFormatter {
 }
 
 // PathChecker checks whether a filesystem path exists.
+// The result is tri-state: (true, nil) means the path exists,
+// (false, nil) means it is known to be absent (ENOENT, ENOTDIR),
+// and a non-nil error means existence could not be determined.
+// Callers keep entries whose status is unknown.
 type PathChecker interface {
-	Exists(ctx context.Context, path string) bool
+	Exists(ctx context.Context, path string) (bool, error)
+}
+
+// unknownReason maps a PathChecker error to the reason code
+// recorded for the kept entry.
+func unknownReason(err error) SweepReason {
+	switch {
+	case errors.Is(err, ErrVolatileRoot):
+		return ReasonVolatileRoot
+	case errors.Is(err, ErrMountUnavailable):
+		return ReasonMountUnavailable
+	case errors.Is(err, ErrGracePending):
+		return ReasonGracePending
+	case errors.Is(err, ErrCheckTimeout):
+		return ReasonCheckTimeout
+	default:
+		return ReasonPathUnknown
+	}
 }
 
 func (f *ClaudeJSONFormatter) Format(ctx context.Context, data []byte) (*FormatResult, error) {
@@ -93,12 +147,14 @@ func (f *ClaudeJSONFormatter) Format(ctx context.Context, data []byte) (*FormatR
 	}
 
 	stats := &ClaudeJSONFormatterStats{SizeBefore: len(data)}
-	cj := &claudeJSONData{data: obj, checker: f.PathChecker}
-	if err := cj.cleanProjects(ctx, stats); err != nil {
-		return nil, err
-	}
-	if err := cj.cleanGitHubRepoPaths(ctx, stats); err != nil {
-		return nil, err
+	cj := &claudeJSONData{data: obj, checker: f.PathChecker, jobs: f.Jobs}
+	if f.PathChecker != nil {
+		if err := cj.cleanProjects(ctx, stats); err != nil {
+			return nil, err
+		}
+		if err := cj.cleanGitHubRepoPaths(ctx, stats); err != nil {
+			return nil, err
+		}
 	}
 
 	out, err := encodeJSON(cj.data)
@@ -107,12 +163,34 @@ func (f *ClaudeJSONFormatter) Format(ctx context.Context, data []byte) (*FormatR
 	}
 	stats.SizeAfter = len(out)
 
-	return &FormatResult{Data: out, Stats: stats}, nil
+	return &FormatResult{Data: out, Stats: stats, Changed: !bytes.Equal(data, out)}, nil
 }
 
 type claudeJSONData struct {
 	data    map[string]any
 	checker PathChecker
+	jobs    int
+}
+
+// pathStatus is the outcome of a single PathChecker call.
+type pathStatus struct {
+	exists bool
+	err    error
+}
+
+// checkPaths calls Exists for each path with at most jobs calls in
+// flight and returns the outcomes in input order.
+func checkPaths(ctx context.Context, checker PathChecker, paths []string, jobs int) ([]pathStatus, error) {
+	statuses := make([]pathStatus, len(paths))
+	err := pool.Run(ctx, jobs, len(paths), func(ctx context.Context, i int) error {
+		exists, err := checker.Exists(ctx, paths[i])
+		statuses[i] = pathStatus{exists: exists, err: err}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return statuses, nil
 }
 
 func (c *claudeJSONData) cleanProjects(ctx context.Context, stats *ClaudeJSONFormatterStats) error {
@@ -127,12 +205,31 @@ func (c *claudeJSONData) cleanProjects(ctx context.Context, stats *ClaudeJSONFor
 	}
 
 	stats.ProjectsBefore = len(projects)
-	for p := range projects {
-		if err := ctx.Err(); err != nil {
-			return err
-		}
-		if !c.checker.Exists(ctx, p) {
+	paths := slices.Sorted(maps.Keys(projects))
+	statuses, err := checkPaths(ctx, c.checker, paths, c.jobs)
+	if err != nil {
+		return err
+	}
+	for i, p := range paths {
+		exists, err := statuses[i].exists, statuses[i].err
+		switch {
+		case err != nil:
+			stats.Warns = append(stats.Warns, p)
+			stats.Records = append(stats.Records, SweepRecord{
+				Category: "projects",
+				Entry:    p,
+				Decision: DecisionWarned,
+				Reason:   unknownReason(err),
+				Detail:   err.Error(),
+			})
+		case !exists:
 			delete(projects, p)
+			stats.Records = append(stats.Records, SweepRecord{
+				Category: "projects",
+				Entry:    p,
+				Decision: DecisionSwept,
+				Reason:   ReasonPathMissing,
+			})
 		}
 	}
 	stats.ProjectsAfter = len(projects)
@@ -161,11 +258,24 @@ func (c *claudeJSONData) cleanGitHubRepoPaths(ctx context.Context, stats *Claude
 	stats.RepoBefore = totalBefore
 
 	reposBefore := len(repos)
-	for repo, v := range repos {
-		if err := ctx.Err(); err != nil {
-			return err
+	names := slices.Sorted(maps.Keys(repos))
+	var all []string
+	for _, repo := range names {
+		paths, _ := repos[repo].([]any)
+		for _, p := range paths {
+			if s, ok := p.(string); ok {
+				all = append(all, s)
+			}
 		}
-		paths, ok := v.([]any)
+	}
+	statuses, err := checkPaths(ctx, c.checker, all, c.jobs)
+	if err != nil {
+		return err
+	}
+
+	next := 0
+	for _, repo := range names {
+		paths, ok := repos[repo].([]any)
 		if !ok {
 			continue
 		}
@@ -175,9 +285,32 @@ func (c *claudeJSONData) cleanGitHubRepoPaths(ctx context.Context, stats *Claude
 			if !ok {
 				continue
 			}
-			if c.checker.Exists(ctx, s) {
+			exists, err := statuses[next].exists, statuses[next].err
+			next++
+			if err != nil {
 				existing = append(existing, s)
+				stats.Warns = append(stats.Warns, s)
+				stats.Records = append(stats.Records, SweepRecord{
+					Category: "githubRepoPaths",
+					Key:      repo,
+					Entry:    s,
+					Decision: DecisionWarned,
+					Reason:   unknownReason(err),
+					Detail:   err.Error(),
+				})
+				continue
 			}
+			if exists {
+				existing = append(existing, s)
+				continue
+			}
+			stats.Records = append(stats.Records, SweepRecord{
+				Category: "githubRepoPaths",
+				Key:      repo,
+				Entry:    s,
+				Decision: DecisionSwept,
+				Reason:   ReasonPathMissing,
+			})
 		}
 		if len(existing) == 0 {
 			delete(repos, repo)
@@ -202,8 +335,11 @@ func (c *claudeJSONData) cleanGitHubRepoPaths(ctx context.Context, stats *Claude
 // SettingsJSONFormatter formats settings.json / settings.local.json
 // by sorting keys recursively and sorting homogeneous arrays.
 // When Sweeper is provided, dead permission paths are swept.
+// When Plugins is provided, stale enabledPlugins and
+// extraKnownMarketplaces entries are swept.
 type SettingsJSONFormatter struct {
 	Sweeper *PermissionSweeper
+	Plugins *PluginSettingsSweeper
 }
 
 func NewSettingsJSONFormatter(sweeper *PermissionSweeper) *SettingsJSONFormatter {
@@ -221,7 +357,14 @@ func (s *SettingsJSONFormatter) Format(ctx context.Context, data []byte) (*Forma
 	sr := s.Sweeper.Sweep(ctx, obj)
 	stats.SweptAllow = sr.SweptAllow
 	stats.SweptAsk = sr.SweptAsk
+	stats.SweptDirectories = sr.SweptDirectories
 	stats.Warns = sr.Warns
+	stats.Records = sr.Records
+
+	pr := s.Plugins.Sweep(obj)
+	stats.SweptPlugins = pr.SweptPlugins
+	stats.SweptMarketplaces = pr.SweptMarketplaces
+	stats.Records = append(stats.Records, pr.Records...)
 
 	sortArraysRecursive(obj)
 
@@ -231,7 +374,7 @@ func (s *SettingsJSONFormatter) Format(ctx context.Context, data []byte) (*Forma
 	}
 
 	stats.SizeAfter = len(out)
-	return &FormatResult{Data: out, Stats: stats}, nil
+	return &FormatResult{Data: out, Stats: stats, Changed: !bytes.Equal(data, out)}, nil
 }
 
 func decodeJSON(data []byte) (map[string]any, error) {
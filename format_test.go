@@ -5,6 +5,8 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"slices"
+	"strings"
 	"testing"
 
 	"github.com/708u/cctidy/internal/testutil"
@@ -275,6 +277,72 @@ func TestSettingsJSONFormatterDoesNotAddProjects(t *testing.T) {
 	assertNoKey(t, got, "githubRepoPaths")
 }
 
+func TestFormatResultChanged(t *testing.T) {
+	t.Parallel()
+	sweeper, err := NewPermissionSweeper(testutil.AllPathsExist{}, "", nil)
+	if err != nil {
+		t.Fatalf("NewPermissionSweeper: %v", err)
+	}
+	claude := NewClaudeJSONFormatter(testutil.AllPathsExist{})
+	settings := NewSettingsJSONFormatter(sweeper)
+
+	tests := []struct {
+		name      string
+		formatter interface {
+			Format(context.Context, []byte) (*FormatResult, error)
+		}
+		input string
+		want  bool
+	}{
+		{name: "claude json formatted", formatter: claude, input: "{\n  \"githubRepoPaths\": {},\n  \"projects\": {}\n}\n", want: false},
+		{name: "claude json unsorted", formatter: claude, input: `{"projects": {}, "githubRepoPaths": {}}`, want: true},
+		{name: "settings formatted", formatter: settings, input: "{\n  \"a\": [\n    \"x\",\n    \"y\"\n  ]\n}\n", want: false},
+		{name: "settings unsorted array", formatter: settings, input: "{\n  \"a\": [\n    \"y\",\n    \"x\"\n  ]\n}\n", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			result, err := tt.formatter.Format(t.Context(), []byte(tt.input))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := result.Changed; got != tt.want {
+				t.Errorf("Changed = %v, want %v\noutput:\n%s", got, tt.want, result.Data)
+			}
+		})
+	}
+}
+
+func TestFormattersWithoutSweeping(t *testing.T) {
+	t.Parallel()
+
+	t.Run("claude json keeps every path", func(t *testing.T) {
+		t.Parallel()
+		input := `{"projects":{"/gone":{}},"githubRepoPaths":{"o/r":["/gone"]}}`
+		result, err := NewClaudeJSONFormatter(nil).Format(t.Context(), []byte(input))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := "{\n  \"githubRepoPaths\": {\n    \"o/r\": [\n      \"/gone\"\n    ]\n  },\n  \"projects\": {\n    \"/gone\": {}\n  }\n}\n"
+		if string(result.Data) != want {
+			t.Errorf("got:\n%s\nwant:\n%s", result.Data, want)
+		}
+	})
+
+	t.Run("settings sorts without sweeping", func(t *testing.T) {
+		t.Parallel()
+		input := `{"permissions":{"allow":["Write","Read(/gone)"]}}`
+		result, err := NewSettingsJSONFormatter(nil).Format(t.Context(), []byte(input))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := "{\n  \"permissions\": {\n    \"allow\": [\n      \"Read(/gone)\",\n      \"Write\"\n    ]\n  }\n}\n"
+		if string(result.Data) != want {
+			t.Errorf("got:\n%s\nwant:\n%s", result.Data, want)
+		}
+	})
+}
+
 func assertNoKey(t *testing.T, jsonStr, key string) {
 	t.Helper()
 	if len(jsonStr) > 0 && json.Valid([]byte(jsonStr)) &&
@@ -300,6 +368,134 @@ func TestFormatStats(t *testing.T) {
 	}
 }
 
+func TestFormatRecords(t *testing.T) {
+	t.Parallel()
+	input := `{"projects": {"/gone-b": {}, "/exists": {}, "/gone-a": {}}, "githubRepoPaths": {"org/r": ["/exists", "/gone-c"]}}`
+	f := NewClaudeJSONFormatter(testutil.CheckerFor("/exists"))
+	result, err := f.Format(t.Context(), []byte(input))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	s := result.Stats.(*ClaudeJSONFormatterStats)
+	want := []SweepRecord{
+		{Category: "projects", Entry: "/gone-a", Decision: DecisionSwept, Reason: ReasonPathMissing},
+		{Category: "projects", Entry: "/gone-b", Decision: DecisionSwept, Reason: ReasonPathMissing},
+		{Category: "githubRepoPaths", Key: "org/r", Entry: "/gone-c", Decision: DecisionSwept, Reason: ReasonPathMissing},
+	}
+	if !slices.Equal(s.Records, want) {
+		t.Errorf("Records mismatch:\ngot:  %+v\nwant: %+v", s.Records, want)
+	}
+}
+
+func TestFormatConcurrentMatchesSequential(t *testing.T) {
+	t.Parallel()
+	input := `{"projects": {"/p3": {}, "/exists": {}, "/p1": {}, "/p2": {}}, "githubRepoPaths": {"org/b": ["/gone-b", "/exists"], "org/a": ["/gone-a"]}}`
+	checker := testutil.CheckerFor("/exists", "/p2")
+
+	seq, err := NewClaudeJSONFormatter(checker).Format(t.Context(), []byte(input))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	f := &ClaudeJSONFormatter{PathChecker: checker, Jobs: 4}
+	par, err := f.Format(t.Context(), []byte(input))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(seq.Data, par.Data) {
+		t.Errorf("output mismatch:\nsequential: %s\nconcurrent: %s", seq.Data, par.Data)
+	}
+	seqStats := seq.Stats.(*ClaudeJSONFormatterStats)
+	parStats := par.Stats.(*ClaudeJSONFormatterStats)
+	if !slices.Equal(seqStats.Records, parStats.Records) {
+		t.Errorf("Records mismatch:\nsequential: %+v\nconcurrent: %+v", seqStats.Records, parStats.Records)
+	}
+}
+
+func TestFormatUnknownPaths(t *testing.T) {
+	t.Parallel()
+	input := `{"projects": {"/denied": {}, "/gone": {}}, "githubRepoPaths": {"org/r": ["/denied", "/gone"]}}`
+	errDenied := errors.New("permission denied")
+	f := NewClaudeJSONFormatter(testutil.UnknownFor(errDenied, "/denied"))
+	result, err := f.Format(t.Context(), []byte(input))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "{\n  \"githubRepoPaths\": {\n    \"org/r\": [\n      \"/denied\"\n    ]\n  },\n  \"projects\": {\n    \"/denied\": {}\n  }\n}\n"
+	if got := string(result.Data); got != want {
+		t.Errorf("mismatch:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+	s := result.Stats.(*ClaudeJSONFormatterStats)
+	wantRecords := []SweepRecord{
+		{Category: "projects", Entry: "/denied", Decision: DecisionWarned, Reason: ReasonPathUnknown, Detail: "permission denied"},
+		{Category: "projects", Entry: "/gone", Decision: DecisionSwept, Reason: ReasonPathMissing},
+		{Category: "githubRepoPaths", Key: "org/r", Entry: "/denied", Decision: DecisionWarned, Reason: ReasonPathUnknown, Detail: "permission denied"},
+		{Category: "githubRepoPaths", Key: "org/r", Entry: "/gone", Decision: DecisionSwept, Reason: ReasonPathMissing},
+	}
+	if !slices.Equal(s.Records, wantRecords) {
+		t.Errorf("Records mismatch:\ngot:  %+v\nwant: %+v", s.Records, wantRecords)
+	}
+	if !slices.Equal(s.Warns, []string{"/denied", "/denied"}) {
+		t.Errorf("Warns = %v", s.Warns)
+	}
+	if !strings.Contains(s.Summary(), "Skipped: /denied\n") {
+		t.Errorf("summary missing warning:\n%s", s.Summary())
+	}
+}
+
+func TestSettingsJSONFormatterPlugins(t *testing.T) {
+	t.Parallel()
+	f := NewSettingsJSONFormatter(mustNewPermissionSweeper(t, testutil.AllPathsExist{}, "", nil))
+	f.Plugins = NewPluginSettingsSweeper([]InstalledPlugin{{Name: "tools", Marketplace: "official"}}, nil)
+	input := `{"enabledPlugins":{"tools@official":true,"gone@official":true},"permissions":{"allow":["Read"]}}`
+
+	result, err := f.Format(t.Context(), []byte(input))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "{\n  \"enabledPlugins\": {\n    \"tools@official\": true\n  },\n  \"permissions\": {\n    \"allow\": [\n      \"Read\"\n    ]\n  }\n}\n"
+	if string(result.Data) != want {
+		t.Errorf("got:\n%s\nwant:\n%s", result.Data, want)
+	}
+	stats := result.Stats.(*SettingsJSONFormatterStats)
+	if stats.SweptPlugins != 1 {
+		t.Errorf("SweptPlugins = %d, want 1", stats.SweptPlugins)
+	}
+	if !strings.Contains(stats.Summary(), "Swept: 1 enabled plugins, 0 marketplaces") {
+		t.Errorf("summary missing plugin line:\n%s", stats.Summary())
+	}
+	var swept []string
+	for _, rec := range stats.Records {
+		if rec.Decision == DecisionSwept {
+			swept = append(swept, rec.Category+" "+rec.Entry)
+		}
+	}
+	if !slices.Equal(swept, []string{"enabledPlugins gone@official"}) {
+		t.Errorf("swept records = %v", swept)
+	}
+}
+
+func TestFormatStatsJSON(t *testing.T) {
+	t.Parallel()
+	input := `{"permissions": {"allow": ["Read(//dead)"]}}`
+	sweeper, err := NewPermissionSweeper(testutil.NoPathsExist{}, "", nil)
+	if err != nil {
+		t.Fatalf("NewPermissionSweeper: %v", err)
+	}
+	result, err := NewSettingsJSONFormatter(sweeper).Format(t.Context(), []byte(input))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, err := json.Marshal(result.Stats)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	want := `{"sizeBefore":44,"sizeAfter":43,"sweptAllow":1,"sweptAsk":0,` +
+		`"records":[{"category":"allow","entry":"Read(//dead)","tool":"Read","decision":"swept","reason":"path-missing"}]}`
+	if string(got) != want {
+		t.Errorf("json mismatch:\ngot:  %s\nwant: %s", got, want)
+	}
+}
+
 func TestFormatComma(t *testing.T) {
 	t.Parallel()
 	tests := []struct {
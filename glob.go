@@ -0,0 +1,163 @@
+package cctidy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DefaultGlobWalkBudget is the number of directory entries visited
+// per pattern when [permission.glob] walk_budget is not configured.
+const DefaultGlobWalkBudget = 10000
+
+// errWalkBudget stops a walk that exceeds its budget.
+var errWalkBudget = errors.New("walk budget exceeded")
+
+// splitGlob splits a cleaned absolute pattern into the directory
+// before the first glob character and the remaining slash-separated
+// pattern segments. Unless anchored, the pattern matches at any
+// depth below base, as a gitignore pattern without a slash does,
+// so "**" is prepended to the segments.
+func splitGlob(pattern string, anchored bool) (base string, segments []string) {
+	i := strings.IndexAny(pattern, "*?[")
+	base = pattern[:strings.LastIndexByte(pattern[:i], '/')+1]
+	segments = strings.Split(filepath.ToSlash(pattern[len(base):]), "/")
+	if !anchored && segments[0] != "**" {
+		segments = append([]string{"**"}, segments...)
+	}
+	return filepath.Clean(base), segments
+}
+
+// matchSegments reports whether the segments of a relative name
+// match the pattern segments. "**" matches zero or more segments;
+// other segments use path.Match. With prefix set, it also reports
+// true when a descendant of name could match.
+func matchSegments(pattern, name []string, prefix bool) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			if prefix {
+				return true
+			}
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:], false) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return prefix
+		}
+		if ok, err := path.Match(pattern[0], name[0]); err != nil || !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// globMatches walks base looking for an entry matching segments.
+// At most budget entries are visited; a larger tree returns an
+// error wrapping errWalkBudget. Directories that cannot match are
+// not descended into. When nothing matches and some directory could
+// not be read, the first read error is returned.
+func globMatches(ctx context.Context, base string, segments []string, budget int) (bool, error) {
+	var (
+		found   bool
+		visited int
+		readErr error
+	)
+	err := fs.WalkDir(os.DirFS(base), ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if readErr == nil {
+				readErr = err
+			}
+			return nil
+		}
+		if name == "." {
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		visited++
+		if visited > budget {
+			return errWalkBudget
+		}
+		parts := strings.Split(name, "/")
+		if matchSegments(segments, parts, false) {
+			found = true
+			return fs.SkipAll
+		}
+		if d.IsDir() && !matchSegments(segments, parts, true) {
+			return fs.SkipDir
+		}
+		return nil
+	})
+	switch {
+	case found:
+		return true, nil
+	case errors.Is(err, errWalkBudget):
+		return false, fmt.Errorf("%w: more than %d entries under %s", errWalkBudget, budget, base)
+	case err != nil:
+		return false, err
+	}
+	return false, readErr
+}
+
+// globMatchesWithin runs globMatches bounded by timeout. A walk that
+// does not finish in time, for example on a hung network mount,
+// returns ErrCheckTimeout; it stops at its next entry once the
+// blocking read returns. A timeout of zero or less disables the
+// deadline.
+func globMatchesWithin(ctx context.Context, base string, segments []string, budget int, timeout time.Duration) (bool, error) {
+	if timeout <= 0 {
+		return globMatches(ctx, base, segments, budget)
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	type result struct {
+		matched bool
+		err     error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		matched, err := globMatches(ctx, base, segments, budget)
+		ch <- result{matched: matched, err: err}
+	}()
+	select {
+	case r := <-ch:
+		if errors.Is(r.err, context.DeadlineExceeded) && ctx.Err() != nil {
+			return false, fmt.Errorf("%w after %s: walking %s", ErrCheckTimeout, timeout, base)
+		}
+		return r.matched, r.err
+	case <-ctx.Done():
+		if err := context.Cause(ctx); !errors.Is(err, context.DeadlineExceeded) {
+			return false, err
+		}
+		return false, fmt.Errorf("%w after %s: walking %s", ErrCheckTimeout, timeout, base)
+	}
+}
+
+// checkWalkWithin runs mounts.CheckWalk bounded by timeout, so that
+// probing the mount point of a hung network mount does not block
+// the walk that follows it. A timeout of zero or less disables the
+// deadline.
+func checkWalkWithin(ctx context.Context, mounts *MountAwareChecker, dir string, timeout time.Duration) error {
+	if timeout <= 0 {
+		return mounts.CheckWalk(ctx, dir)
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	err := mounts.CheckWalk(ctx, dir)
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("%w after %s: probing mount point of %s", ErrCheckTimeout, timeout, dir)
+	}
+	return err
+}
@@ -0,0 +1,147 @@
+package cctidy
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestSplitGlob(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name         string
+		pattern      string
+		anchored     bool
+		wantBase     string
+		wantSegments []string
+	}{
+		{
+			name:         "trailing double star",
+			pattern:      "/home/me/old-repo/**",
+			anchored:     true,
+			wantBase:     "/home/me/old-repo",
+			wantSegments: []string{"**"},
+		},
+		{
+			name:         "double star in the middle",
+			pattern:      "/src/legacy/**/*.ts",
+			anchored:     true,
+			wantBase:     "/src/legacy",
+			wantSegments: []string{"**", "*.ts"},
+		},
+		{
+			name:         "glob in a directory name",
+			pattern:      "/src/pkg-*/main.go",
+			anchored:     true,
+			wantBase:     "/src",
+			wantSegments: []string{"pkg-*", "main.go"},
+		},
+		{
+			name:         "unanchored pattern matches at any depth",
+			pattern:      "/project/*.env",
+			wantBase:     "/project",
+			wantSegments: []string{"**", "*.env"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			base, segments := splitGlob(tt.pattern, tt.anchored)
+			if base != tt.wantBase {
+				t.Errorf("base = %q, want %q", base, tt.wantBase)
+			}
+			if !slices.Equal(segments, tt.wantSegments) {
+				t.Errorf("segments = %q, want %q", segments, tt.wantSegments)
+			}
+		})
+	}
+}
+
+func TestMatchSegments(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		pattern []string
+		name    []string
+		prefix  bool
+		want    bool
+	}{
+		{pattern: []string{"**"}, name: []string{"a"}, want: true},
+		{pattern: []string{"**"}, name: []string{"a", "b", "c"}, want: true},
+		{pattern: []string{"**", "*.ts"}, name: []string{"x.ts"}, want: true},
+		{pattern: []string{"**", "*.ts"}, name: []string{"a", "b", "x.ts"}, want: true},
+		{pattern: []string{"**", "*.ts"}, name: []string{"a", "x.go"}, want: false},
+		{pattern: []string{"a", "**", "b"}, name: []string{"a", "b"}, want: true},
+		{pattern: []string{"pkg-*", "main.go"}, name: []string{"pkg-x", "main.go"}, want: true},
+		{pattern: []string{"pkg-*", "main.go"}, name: []string{"pkg-x"}, want: false},
+		{pattern: []string{"pkg-*", "main.go"}, name: []string{"pkg-x"}, prefix: true, want: true},
+		{pattern: []string{"pkg-*", "main.go"}, name: []string{"other"}, prefix: true, want: false},
+		{pattern: []string{"*"}, name: []string{"a", "b"}, want: false},
+	}
+	for _, tt := range tests {
+		if got := matchSegments(tt.pattern, tt.name, tt.prefix); got != tt.want {
+			t.Errorf("matchSegments(%q, %q, %v) = %v, want %v", tt.pattern, tt.name, tt.prefix, got, tt.want)
+		}
+	}
+}
+
+func TestGlobMatches(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	for _, p := range []string{"src/a/b/x.ts", "src/c/y.go", "docs/readme.md"} {
+		path := filepath.Join(dir, p)
+		os.MkdirAll(filepath.Dir(path), 0o755)
+		os.WriteFile(path, nil, 0o644)
+	}
+
+	t.Run("nested match", func(t *testing.T) {
+		t.Parallel()
+		ok, err := globMatches(t.Context(), dir, []string{"src", "**", "*.ts"}, 100)
+		if err != nil || !ok {
+			t.Errorf("got %v, %v; want match", ok, err)
+		}
+	})
+
+	t.Run("no match", func(t *testing.T) {
+		t.Parallel()
+		ok, err := globMatches(t.Context(), dir, []string{"**", "*.rs"}, 100)
+		if err != nil || ok {
+			t.Errorf("got %v, %v; want no match", ok, err)
+		}
+	})
+
+	t.Run("budget exceeded", func(t *testing.T) {
+		t.Parallel()
+		_, err := globMatches(t.Context(), dir, []string{"**", "*.rs"}, 3)
+		if !errors.Is(err, errWalkBudget) {
+			t.Errorf("err = %v, want errWalkBudget", err)
+		}
+	})
+
+	t.Run("non-matching directories are pruned", func(t *testing.T) {
+		t.Parallel()
+		// Only docs and docs/readme.md are visited.
+		ok, err := globMatches(t.Context(), dir, []string{"docs", "*.md"}, 4)
+		if err != nil || !ok {
+			t.Errorf("got %v, %v; want match within budget", ok, err)
+		}
+	})
+
+	t.Run("within timeout", func(t *testing.T) {
+		t.Parallel()
+		ok, err := globMatchesWithin(t.Context(), dir, []string{"**", "*.go"}, 100, time.Minute)
+		if err != nil || !ok {
+			t.Errorf("got %v, %v; want match", ok, err)
+		}
+	})
+
+	t.Run("timeout exceeded", func(t *testing.T) {
+		t.Parallel()
+		_, err := globMatchesWithin(t.Context(), dir, []string{"**", "*.rs"}, 100, time.Nanosecond)
+		if !errors.Is(err, ErrCheckTimeout) {
+			t.Errorf("err = %v, want ErrCheckTimeout", err)
+		}
+	})
+}
@@ -7,6 +7,7 @@ require (
 	github.com/pelletier/go-toml/v2 v2.2.4
 	github.com/yuin/goldmark v1.7.16
 	go.abhg.dev/goldmark/frontmatter v0.3.0
+	mvdan.cc/sh/v3 v3.7.0
 )
 
 require (
@@ -0,0 +1,268 @@
+package cctidy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/708u/cctidy/internal/set"
+)
+
+// ErrGracePending is returned by GraceChecker for a missing path
+// that has not yet stayed missing for the configured grace period.
+var ErrGracePending = errors.New("grace period pending")
+
+// graceStateTTL is how long a record of a missing path is kept
+// after it was last observed. Records of paths that are no longer
+// referenced anywhere expire instead of accumulating forever.
+const graceStateTTL = 90 * 24 * time.Hour
+
+// GracePolicy decides when a missing path may be swept.
+// A zero policy sweeps immediately. When both Runs and Duration
+// are set, both must be satisfied.
+type GracePolicy struct {
+	// Runs is the number of consecutive runs a path must be
+	// observed missing, including the current one.
+	Runs int
+	// Duration is the minimum time since the path was first
+	// observed missing.
+	Duration time.Duration
+}
+
+// Enabled reports whether the policy delays sweeping at all.
+func (p GracePolicy) Enabled() bool {
+	return p.Runs > 1 || p.Duration > 0
+}
+
+func (p GracePolicy) satisfied(r *graceRecord, now time.Time) bool {
+	if p.Runs > 1 && r.Runs < p.Runs {
+		return false
+	}
+	if p.Duration > 0 && now.Sub(r.FirstMissing) < p.Duration {
+		return false
+	}
+	return true
+}
+
+// graceRecord tracks how long a single path has been missing.
+type graceRecord struct {
+	FirstMissing time.Time `json:"firstMissing"`
+	LastMissing  time.Time `json:"lastMissing"`
+	Runs         int       `json:"runs"`
+}
+
+// GraceState is the persisted record of paths observed missing.
+// It is safe for concurrent use.
+type GraceState struct {
+	path string
+
+	mu    sync.Mutex
+	Paths map[string]*graceRecord `json:"paths"`
+	// base holds the records as loaded and reset the paths found
+	// existing since, so that Save can merge this process's changes
+	// into a file other processes have written meanwhile.
+	base  map[string]graceRecord
+	reset set.Value[string]
+}
+
+// StateDir returns $XDG_STATE_HOME/cctidy, falling back to
+// ~/.local/state/cctidy when XDG_STATE_HOME is unset or relative.
+func StateDir(homeDir string) string {
+	dir := os.Getenv("XDG_STATE_HOME")
+	if dir == "" || !filepath.IsAbs(dir) {
+		dir = filepath.Join(homeDir, ".local", "state")
+	}
+	return filepath.Join(dir, "cctidy")
+}
+
+// DefaultGraceStatePath returns grace.json in StateDir.
+func DefaultGraceStatePath(homeDir string) string {
+	return filepath.Join(StateDir(homeDir), "grace.json")
+}
+
+// LoadGraceState reads the state file at path.
+// Returns an empty state when the file does not exist.
+func LoadGraceState(path string) (*GraceState, error) {
+	s, err := readGraceState(path)
+	s.snapshot()
+	return s, err
+}
+
+// readGraceState reads the state file at path. It returns a usable
+// empty state alongside any error.
+func readGraceState(path string) (*GraceState, error) {
+	s := &GraceState{path: path, Paths: map[string]*graceRecord{}}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return s, fmt.Errorf("reading grace state %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, s); err != nil {
+		return &GraceState{path: path, Paths: map[string]*graceRecord{}},
+			fmt.Errorf("parsing grace state %s: %w", path, err)
+	}
+	if s.Paths == nil {
+		s.Paths = map[string]*graceRecord{}
+	}
+	return s, nil
+}
+
+// Path returns the file the state is loaded from and saved to.
+func (s *GraceState) Path() string {
+	return s.path
+}
+
+// snapshot records the current records as the base for the next
+// merge.
+func (s *GraceState) snapshot() {
+	s.base = make(map[string]graceRecord, len(s.Paths))
+	for p, r := range s.Paths {
+		s.base[p] = *r
+	}
+	s.reset = set.New[string]()
+}
+
+// mergeInto applies the changes made since the last snapshot to
+// current, the records in the file now. A path found existing is
+// removed, or replaced by the record started after it. Runs counted
+// since the snapshot are added to the current record.
+func (s *GraceState) mergeInto(current map[string]*graceRecord) {
+	for p := range s.base {
+		if _, ok := s.Paths[p]; !ok {
+			delete(current, p)
+		}
+	}
+	for p, r := range s.Paths {
+		b, hadBase := s.base[p]
+		if hadBase && *r == b && !s.reset.Has(p) {
+			continue
+		}
+		c, ok := current[p]
+		if !ok || s.reset.Has(p) {
+			rec := *r
+			current[p] = &rec
+			continue
+		}
+		added := r.Runs
+		if hadBase {
+			added -= b.Runs
+		}
+		c.Runs += added
+		if r.FirstMissing.Before(c.FirstMissing) {
+			c.FirstMissing = r.FirstMissing
+		}
+		if r.LastMissing.After(c.LastMissing) {
+			c.LastMissing = r.LastMissing
+		}
+	}
+}
+
+// Save merges the changes made since the state was loaded into the
+// file's current content and writes it atomically, dropping records
+// that have not been observed within graceStateTTL of now. Processes
+// sharing the file must not call Save concurrently; the caller
+// serializes them with a lock.
+func (s *GraceState) Save(now time.Time) error {
+	s.mu.Lock()
+	// An unreadable file is replaced, as at load.
+	current, _ := readGraceState(s.path)
+	s.mergeInto(current.Paths)
+	s.Paths = current.Paths
+	s.snapshot()
+	for p, r := range s.Paths {
+		if now.Sub(r.LastMissing) > graceStateTTL {
+			delete(s.Paths, p)
+		}
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	s.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("encoding grace state: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return fmt.Errorf("creating state directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".tmp.*")
+	if err != nil {
+		return fmt.Errorf("creating temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("writing grace state: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("closing temp file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("renaming temp file: %w", err)
+	}
+	return nil
+}
+
+// observe records that path is missing in the run started at now
+// and returns its record. Repeated observations within the same
+// run count once.
+func (s *GraceState) observe(path string, now time.Time) graceRecord {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.Paths[path]
+	if !ok {
+		r = &graceRecord{FirstMissing: now}
+		s.Paths[path] = r
+	}
+	if !r.LastMissing.Equal(now) {
+		r.Runs++
+		r.LastMissing = now
+	}
+	return *r
+}
+
+// forget removes path from the state because it exists again.
+func (s *GraceState) forget(path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.Paths, path)
+	s.reset.Add(path)
+}
+
+// GraceChecker wraps a PathChecker and reports a missing path as
+// missing only once it has stayed missing for the grace policy.
+// Until then it returns ErrGracePending so that the entry is kept.
+// Errors from the inner checker are passed through unchanged.
+type GraceChecker struct {
+	inner  PathChecker
+	state  *GraceState
+	policy GracePolicy
+	now    time.Time
+}
+
+// NewGraceChecker creates a GraceChecker for a run started at now.
+func NewGraceChecker(inner PathChecker, state *GraceState, policy GracePolicy, now time.Time) *GraceChecker {
+	return &GraceChecker{inner: inner, state: state, policy: policy, now: now}
+}
+
+func (g *GraceChecker) Exists(ctx context.Context, path string) (bool, error) {
+	exists, err := g.inner.Exists(ctx, path)
+	if err != nil {
+		return exists, err
+	}
+	if exists {
+		g.state.forget(path)
+		return true, nil
+	}
+	r := g.state.observe(path, g.now)
+	if g.policy.satisfied(&r, g.now) {
+		return false, nil
+	}
+	return false, fmt.Errorf("%w: missing for %d run(s) since %s",
+		ErrGracePending, r.Runs, r.FirstMissing.Format(time.RFC3339))
+}
@@ -0,0 +1,224 @@
+package cctidy
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/708u/cctidy/internal/testutil"
+)
+
+func TestGraceChecker(t *testing.T) {
+	t.Parallel()
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		policy GracePolicy
+		// runs lists the time offset of each run; the path is missing in all.
+		runs     []time.Duration
+		wantLast error
+	}{
+		{
+			name:     "runs threshold not reached",
+			policy:   GracePolicy{Runs: 3},
+			runs:     []time.Duration{0, time.Hour},
+			wantLast: ErrGracePending,
+		},
+		{
+			name:   "runs threshold reached",
+			policy: GracePolicy{Runs: 3},
+			runs:   []time.Duration{0, time.Hour, 2 * time.Hour},
+		},
+		{
+			name:     "duration not elapsed",
+			policy:   GracePolicy{Duration: 24 * time.Hour},
+			runs:     []time.Duration{0, 23 * time.Hour},
+			wantLast: ErrGracePending,
+		},
+		{
+			name:   "duration elapsed",
+			policy: GracePolicy{Duration: 24 * time.Hour},
+			runs:   []time.Duration{0, 25 * time.Hour},
+		},
+		{
+			name:     "both set requires both",
+			policy:   GracePolicy{Runs: 2, Duration: 24 * time.Hour},
+			runs:     []time.Duration{0, time.Hour},
+			wantLast: ErrGracePending,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			state, err := LoadGraceState(filepath.Join(t.TempDir(), "grace.json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var lastErr error
+			for _, off := range tt.runs {
+				g := NewGraceChecker(testutil.NoPathsExist{}, state, tt.policy, start.Add(off))
+				// Checking twice within a run counts once.
+				g.Exists(t.Context(), "/gone")
+				_, lastErr = g.Exists(t.Context(), "/gone")
+			}
+			if !errors.Is(lastErr, tt.wantLast) || (tt.wantLast == nil && lastErr != nil) {
+				t.Errorf("last run error = %v, want %v", lastErr, tt.wantLast)
+			}
+		})
+	}
+}
+
+func TestGraceCheckerResetsWhenPathReturns(t *testing.T) {
+	t.Parallel()
+	state, _ := LoadGraceState(filepath.Join(t.TempDir(), "grace.json"))
+	policy := GracePolicy{Runs: 2}
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	NewGraceChecker(testutil.NoPathsExist{}, state, policy, start).Exists(t.Context(), "/flaky")
+	if exists, err := NewGraceChecker(testutil.CheckerFor("/flaky"), state, policy, start.Add(time.Hour)).Exists(t.Context(), "/flaky"); !exists || err != nil {
+		t.Fatalf("Exists() = %v, %v, want true, nil", exists, err)
+	}
+	_, err := NewGraceChecker(testutil.NoPathsExist{}, state, policy, start.Add(2*time.Hour)).Exists(t.Context(), "/flaky")
+	if !errors.Is(err, ErrGracePending) {
+		t.Errorf("expected grace to restart after path returned, got %v", err)
+	}
+}
+
+func TestGraceCheckerPassesThroughErrors(t *testing.T) {
+	t.Parallel()
+	state, _ := LoadGraceState(filepath.Join(t.TempDir(), "grace.json"))
+	errDenied := errors.New("permission denied")
+	g := NewGraceChecker(testutil.UnknownFor(errDenied, "/denied"), state, GracePolicy{Runs: 2}, time.Now())
+	if _, err := g.Exists(t.Context(), "/denied"); !errors.Is(err, errDenied) {
+		t.Errorf("err = %v, want %v", err, errDenied)
+	}
+	if len(state.Paths) != 0 {
+		t.Errorf("unknown paths must not be recorded: %v", state.Paths)
+	}
+}
+
+func TestGraceStateSaveLoad(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "state", "cctidy", "grace.json")
+	now := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	state, err := LoadGraceState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state.observe("/recent", now)
+	state.observe("/stale", now.Add(-graceStateTTL-time.Hour))
+	if err := state.Save(now); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	loaded, err := LoadGraceState(path)
+	if err != nil {
+		t.Fatalf("LoadGraceState: %v", err)
+	}
+	r, ok := loaded.Paths["/recent"]
+	if !ok || r.Runs != 1 || !r.FirstMissing.Equal(now) {
+		t.Errorf("recent record = %+v", r)
+	}
+	if _, ok := loaded.Paths["/stale"]; ok {
+		t.Error("stale record should have been pruned")
+	}
+}
+
+func TestGraceStateSaveMergesConcurrentRuns(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "grace.json")
+	t0 := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	t1, t2 := t0.Add(time.Hour), t0.Add(2*time.Hour)
+
+	initial, _ := LoadGraceState(path)
+	initial.observe("/shared", t0)
+	initial.observe("/returned", t0)
+	if err := initial.Save(t0); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	// Both runs load the same state before either saves.
+	a, _ := LoadGraceState(path)
+	b, _ := LoadGraceState(path)
+	a.observe("/a", t1)
+	a.observe("/shared", t1)
+	a.forget("/returned")
+	b.observe("/b", t2)
+	b.observe("/shared", t2)
+	if err := a.Save(t1); err != nil {
+		t.Fatalf("Save a: %v", err)
+	}
+	if err := b.Save(t2); err != nil {
+		t.Fatalf("Save b: %v", err)
+	}
+
+	loaded, err := LoadGraceState(path)
+	if err != nil {
+		t.Fatalf("LoadGraceState: %v", err)
+	}
+	want := map[string]graceRecord{
+		"/a":      {FirstMissing: t1, LastMissing: t1, Runs: 1},
+		"/b":      {FirstMissing: t2, LastMissing: t2, Runs: 1},
+		"/shared": {FirstMissing: t0, LastMissing: t2, Runs: 3},
+	}
+	if len(loaded.Paths) != len(want) {
+		t.Errorf("paths = %v, want %v", loaded.Paths, want)
+	}
+	for p, w := range want {
+		r, ok := loaded.Paths[p]
+		if !ok || !r.FirstMissing.Equal(w.FirstMissing) || !r.LastMissing.Equal(w.LastMissing) || r.Runs != w.Runs {
+			t.Errorf("%s = %+v, want %+v", p, r, w)
+		}
+	}
+}
+
+func TestGraceStateSaveRestartsReturnedPath(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "grace.json")
+	t0 := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	initial, _ := LoadGraceState(path)
+	initial.observe("/flaky", t0)
+	initial.Save(t0)
+
+	s, _ := LoadGraceState(path)
+	s.forget("/flaky")
+	s.observe("/flaky", t0.Add(time.Hour))
+	if err := s.Save(t0.Add(time.Hour)); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	loaded, _ := LoadGraceState(path)
+	r := loaded.Paths["/flaky"]
+	if r == nil || r.Runs != 1 || !r.FirstMissing.Equal(t0.Add(time.Hour)) {
+		t.Errorf("record = %+v, want a restarted record", r)
+	}
+}
+
+func TestLoadGraceStateCorrupt(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "grace.json")
+	os.WriteFile(path, []byte("{broken"), 0o644)
+	state, err := LoadGraceState(path)
+	if err == nil {
+		t.Fatal("expected error for corrupt state")
+	}
+	if state == nil || len(state.Paths) != 0 {
+		t.Errorf("expected usable empty state, got %+v", state)
+	}
+}
+
+func TestDefaultGraceStatePath(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", "/xdg/state")
+	if got := DefaultGraceStatePath("/home/u"); got != "/xdg/state/cctidy/grace.json" {
+		t.Errorf("got %s", got)
+	}
+	t.Setenv("XDG_STATE_HOME", "")
+	if got := DefaultGraceStatePath("/home/u"); got != "/home/u/.local/state/cctidy/grace.json" {
+		t.Errorf("got %s", got)
+	}
+}
@@ -0,0 +1,369 @@
+package diff
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// contextLines is the number of unchanged lines shown around
+// each change, matching the default of diff -u.
+const contextLines = 3
+
+// ANSI escape sequences used by Colorize.
+const (
+	colorReset = "\x1b[0m"
+	colorBold  = "\x1b[1m"
+	colorRed   = "\x1b[31m"
+	colorGreen = "\x1b[32m"
+	colorCyan  = "\x1b[36m"
+)
+
+// maxCost bounds the number of edit rounds bisect explores before
+// giving up on a minimal diff for a region. Large, mostly unrelated
+// inputs (e.g. a minified file being pretty-printed) would otherwise
+// take quadratic time; the fallback reports the region as replaced.
+const maxCost = 1 << 11
+
+// noNewline marks a final line that lacks a trailing newline.
+const noNewline = "\\ No newline at end of file\n"
+
+type opKind int
+
+const (
+	opEqual opKind = iota
+	opDelete
+	opInsert
+)
+
+// op is a single line of the edit script.
+// For opEqual and opDelete, line indexes a; for opInsert, it indexes b.
+type op struct {
+	kind opKind
+	a, b int
+}
+
+// Unified returns a unified diff that turns a into b.
+// oldName and newName are used for the --- and +++ header lines.
+// Returns "" when a and b are identical.
+func Unified(oldName, newName string, a, b []byte) string {
+	if bytes.Equal(a, b) {
+		return ""
+	}
+	al := splitLines(a)
+	bl := splitLines(b)
+	ops := editScript(al, bl)
+
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "--- %s\n+++ %s\n", oldName, newName)
+	for _, h := range hunks(ops) {
+		writeHunk(&buf, al, bl, ops[h[0]:h[1]])
+	}
+	return buf.String()
+}
+
+// Colorize wraps the lines of a unified diff in ANSI color codes:
+// headers in bold, hunk ranges in cyan, deletions in red and
+// insertions in green.
+func Colorize(s string) string {
+	var buf strings.Builder
+	for line := range strings.SplitAfterSeq(s, "\n") {
+		if line == "" {
+			continue
+		}
+		body, nl := strings.CutSuffix(line, "\n")
+		var color string
+		switch {
+		case strings.HasPrefix(body, "--- "), strings.HasPrefix(body, "+++ "):
+			color = colorBold
+		case strings.HasPrefix(body, "@@"):
+			color = colorCyan
+		case strings.HasPrefix(body, "-"):
+			color = colorRed
+		case strings.HasPrefix(body, "+"):
+			color = colorGreen
+		}
+		if color == "" {
+			buf.WriteString(line)
+			continue
+		}
+		buf.WriteString(color)
+		buf.WriteString(body)
+		buf.WriteString(colorReset)
+		if nl {
+			buf.WriteByte('\n')
+		}
+	}
+	return buf.String()
+}
+
+// splitLines splits data into lines, keeping the trailing newline
+// on each line so that a missing final newline is detected as a change.
+func splitLines(data []byte) []string {
+	if len(data) == 0 {
+		return nil
+	}
+	var lines []string
+	for line := range strings.SplitAfterSeq(string(data), "\n") {
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// hunks groups ops into [start, end) ranges, each containing at
+// least one change surrounded by up to contextLines equal lines.
+// Changes separated by at most 2*contextLines equal lines share a hunk.
+func hunks(ops []op) [][2]int {
+	var result [][2]int
+	start, end := -1, -1
+	for i, o := range ops {
+		if o.kind == opEqual {
+			continue
+		}
+		lo := max(i-contextLines, 0)
+		hi := min(i+contextLines+1, len(ops))
+		if start >= 0 && lo <= end {
+			end = max(end, hi)
+			continue
+		}
+		if start >= 0 {
+			result = append(result, [2]int{start, end})
+		}
+		start, end = lo, hi
+	}
+	if start >= 0 {
+		result = append(result, [2]int{start, end})
+	}
+	return result
+}
+
+func writeHunk(buf *strings.Builder, a, b []string, ops []op) {
+	aStart, bStart := -1, -1
+	aCount, bCount := 0, 0
+	for _, o := range ops {
+		switch o.kind {
+		case opEqual:
+			if aStart < 0 {
+				aStart = o.a
+			}
+			if bStart < 0 {
+				bStart = o.b
+			}
+			aCount++
+			bCount++
+		case opDelete:
+			if aStart < 0 {
+				aStart = o.a
+			}
+			aCount++
+		case opInsert:
+			if bStart < 0 {
+				bStart = o.b
+			}
+			bCount++
+		}
+	}
+	// A side with no lines in the hunk is positioned after the
+	// preceding line, which is where the first op would have been.
+	if aStart < 0 {
+		aStart = ops[0].a
+	}
+	if bStart < 0 {
+		bStart = ops[0].b
+	}
+	fmt.Fprintf(buf, "@@ -%s +%s @@\n", hunkRange(aStart, aCount), hunkRange(bStart, bCount))
+	for _, o := range ops {
+		switch o.kind {
+		case opEqual:
+			writeLine(buf, ' ', a[o.a])
+		case opDelete:
+			writeLine(buf, '-', a[o.a])
+		case opInsert:
+			writeLine(buf, '+', b[o.b])
+		}
+	}
+}
+
+// hunkRange formats a 0-based start and line count as a
+// unified diff range. An empty range refers to the line
+// before the position, as in diff -u.
+func hunkRange(start, count int) string {
+	switch count {
+	case 0:
+		return fmt.Sprintf("%d,0", start)
+	case 1:
+		return fmt.Sprintf("%d", start+1)
+	default:
+		return fmt.Sprintf("%d,%d", start+1, count)
+	}
+}
+
+func writeLine(buf *strings.Builder, prefix byte, line string) {
+	buf.WriteByte(prefix)
+	buf.WriteString(line)
+	if !strings.HasSuffix(line, "\n") {
+		buf.WriteByte('\n')
+		buf.WriteString(noNewline)
+	}
+}
+
+// editScript computes a minimal line edit script from a to b.
+// Each op carries its position in both sequences so that hunks
+// can report line numbers for either side.
+func editScript(a, b []string) []op {
+	d := &differ{
+		a:       a,
+		b:       b,
+		deleted: make([]bool, len(a)),
+		added:   make([]bool, len(b)),
+	}
+	d.compare(0, len(a), 0, len(b))
+
+	ops := make([]op, 0, max(len(a), len(b)))
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && d.deleted[i]:
+			ops = append(ops, op{kind: opDelete, a: i, b: j})
+			i++
+		case j < len(b) && d.added[j]:
+			ops = append(ops, op{kind: opInsert, a: i, b: j})
+			j++
+		default:
+			ops = append(ops, op{kind: opEqual, a: i, b: j})
+			i++
+			j++
+		}
+	}
+	return ops
+}
+
+// differ implements the linear-space variant of Myers' O(ND)
+// algorithm, marking deleted lines of a and added lines of b.
+type differ struct {
+	a, b    []string
+	deleted []bool
+	added   []bool
+}
+
+func (d *differ) compare(aLo, aHi, bLo, bHi int) {
+	for aLo < aHi && bLo < bHi && d.a[aLo] == d.b[bLo] {
+		aLo++
+		bLo++
+	}
+	for aLo < aHi && bLo < bHi && d.a[aHi-1] == d.b[bHi-1] {
+		aHi--
+		bHi--
+	}
+	switch {
+	case aLo == aHi:
+		for j := bLo; j < bHi; j++ {
+			d.added[j] = true
+		}
+	case bLo == bHi:
+		for i := aLo; i < aHi; i++ {
+			d.deleted[i] = true
+		}
+	default:
+		x, y, ok := d.bisect(aLo, aHi, bLo, bHi)
+		if !ok {
+			for i := aLo; i < aHi; i++ {
+				d.deleted[i] = true
+			}
+			for j := bLo; j < bHi; j++ {
+				d.added[j] = true
+			}
+			return
+		}
+		d.compare(aLo, x, bLo, y)
+		d.compare(x, aHi, y, bHi)
+	}
+}
+
+// bisect finds the middle snake of an optimal edit path by running
+// the forward and reverse searches until they overlap, and returns
+// the point where the path should be split. Diagonals that run off
+// the edit grid are trimmed from subsequent rounds. Callers must
+// strip common prefixes and suffixes first so that both halves are
+// strictly smaller than the input. Returns ok=false when no split is
+// found within maxCost rounds.
+func (d *differ) bisect(aLo, aHi, bLo, bHi int) (x, y int, ok bool) {
+	n, m := aHi-aLo, bHi-bLo
+	maxD := (n + m + 1) / 2
+	off := maxD
+	rounds := min(maxD, maxCost)
+	size := 2*maxD + 2
+	vf := make([]int, size)
+	vb := make([]int, size)
+	for i := range size {
+		vf[i] = -1
+		vb[i] = -1
+	}
+	vf[off+1] = 0
+	vb[off+1] = 0
+	delta := n - m
+	front := delta%2 != 0
+	var fStart, fEnd, bStart, bEnd int
+
+	for k := range rounds {
+		for diag := -k + fStart; diag <= k-fEnd; diag += 2 {
+			i := off + diag
+			var x1 int
+			if diag == -k || (diag != k && vf[i-1] < vf[i+1]) {
+				x1 = vf[i+1]
+			} else {
+				x1 = vf[i-1] + 1
+			}
+			y1 := x1 - diag
+			for x1 < n && y1 < m && d.a[aLo+x1] == d.b[bLo+y1] {
+				x1++
+				y1++
+			}
+			vf[i] = x1
+			switch {
+			case x1 > n:
+				fEnd += 2
+			case y1 > m:
+				fStart += 2
+			case front:
+				j := off + delta - diag
+				if j >= 0 && j < size && vb[j] != -1 && x1 >= n-vb[j] {
+					return aLo + x1, bLo + y1, true
+				}
+			}
+		}
+		for diag := -k + bStart; diag <= k-bEnd; diag += 2 {
+			i := off + diag
+			var x2 int
+			if diag == -k || (diag != k && vb[i-1] < vb[i+1]) {
+				x2 = vb[i+1]
+			} else {
+				x2 = vb[i-1] + 1
+			}
+			y2 := x2 - diag
+			for x2 < n && y2 < m && d.a[aHi-1-x2] == d.b[bHi-1-y2] {
+				x2++
+				y2++
+			}
+			vb[i] = x2
+			switch {
+			case x2 > n:
+				bEnd += 2
+			case y2 > m:
+				bStart += 2
+			case !front:
+				j := off + delta - diag
+				if j >= 0 && j < size && vf[j] != -1 {
+					x1 := vf[j]
+					y1 := x1 - (j - off)
+					if x1 >= n-x2 {
+						return aLo + x1, bLo + y1, true
+					}
+				}
+			}
+		}
+	}
+	return 0, 0, false
+}
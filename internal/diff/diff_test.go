@@ -0,0 +1,146 @@
+package diff
+
+import (
+	"math/rand/v2"
+	"strings"
+	"testing"
+)
+
+func TestUnified(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		a    string
+		b    string
+		want string
+	}{
+		{
+			name: "identical input returns empty",
+			a:    "a\nb\n",
+			b:    "a\nb\n",
+			want: "",
+		},
+		{
+			name: "single line removed",
+			a:    "a\nb\nc\n",
+			b:    "a\nc\n",
+			want: "--- old\n+++ new\n@@ -1,3 +1,2 @@\n a\n-b\n c\n",
+		},
+		{
+			name: "single line added",
+			a:    "a\nc\n",
+			b:    "a\nb\nc\n",
+			want: "--- old\n+++ new\n@@ -1,2 +1,3 @@\n a\n+b\n c\n",
+		},
+		{
+			name: "insert into empty input",
+			a:    "",
+			b:    "a\n",
+			want: "--- old\n+++ new\n@@ -0,0 +1 @@\n+a\n",
+		},
+		{
+			name: "missing final newline",
+			a:    "a",
+			b:    "a\n",
+			want: "--- old\n+++ new\n@@ -1 +1 @@\n-a\n\\ No newline at end of file\n+a\n",
+		},
+		{
+			name: "distant changes produce separate hunks",
+			a:    "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n",
+			b:    "x\n2\n3\n4\n5\n6\n7\n8\n9\ny\n",
+			want: "--- old\n+++ new\n" +
+				"@@ -1,4 +1,4 @@\n-1\n+x\n 2\n 3\n 4\n" +
+				"@@ -7,4 +7,4 @@\n 7\n 8\n 9\n-10\n+y\n",
+		},
+		{
+			name: "nearby changes share a hunk",
+			a:    "1\n2\n3\n4\n5\n",
+			b:    "x\n2\n3\n4\ny\n",
+			want: "--- old\n+++ new\n@@ -1,5 +1,5 @@\n-1\n+x\n 2\n 3\n 4\n-5\n+y\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got := Unified("old", "new", []byte(tt.a), []byte(tt.b))
+			if got != tt.want {
+				t.Errorf("Unified() mismatch:\ngot:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEditScriptRandom(t *testing.T) {
+	t.Parallel()
+	r := rand.New(rand.NewPCG(1, 2))
+	alphabet := []string{"a\n", "b\n", "c\n", "d\n"}
+	gen := func() []string {
+		s := make([]string, r.IntN(20))
+		for i := range s {
+			s[i] = alphabet[r.IntN(len(alphabet))]
+		}
+		return s
+	}
+	for range 500 {
+		a, b := gen(), gen()
+		ops := editScript(a, b)
+
+		var gotA, gotB []string
+		edits := 0
+		for _, o := range ops {
+			switch o.kind {
+			case opEqual:
+				if a[o.a] != b[o.b] {
+					t.Fatalf("equal op pairs different lines: %q vs %q", a[o.a], b[o.b])
+				}
+				gotA = append(gotA, a[o.a])
+				gotB = append(gotB, b[o.b])
+			case opDelete:
+				gotA = append(gotA, a[o.a])
+				edits++
+			case opInsert:
+				gotB = append(gotB, b[o.b])
+				edits++
+			}
+		}
+		if strings.Join(gotA, "") != strings.Join(a, "") || strings.Join(gotB, "") != strings.Join(b, "") {
+			t.Fatalf("edit script does not reproduce inputs: a=%q b=%q", a, b)
+		}
+		if want := len(a) + len(b) - 2*lcsLen(a, b); edits != want {
+			t.Fatalf("edit script is not minimal: got %d edits, want %d (a=%q b=%q)", edits, want, a, b)
+		}
+	}
+}
+
+// lcsLen computes the longest common subsequence length by
+// dynamic programming as a reference for minimality.
+func lcsLen(a, b []string) int {
+	dp := make([][]int, len(a)+1)
+	for i := range dp {
+		dp[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				dp[i][j] = dp[i+1][j+1] + 1
+			} else {
+				dp[i][j] = max(dp[i+1][j], dp[i][j+1])
+			}
+		}
+	}
+	return dp[0][0]
+}
+
+func TestColorize(t *testing.T) {
+	t.Parallel()
+	in := "--- old\n+++ new\n@@ -1 +1 @@\n-a\n+b\n c\n"
+	want := colorBold + "--- old" + colorReset + "\n" +
+		colorBold + "+++ new" + colorReset + "\n" +
+		colorCyan + "@@ -1 +1 @@" + colorReset + "\n" +
+		colorRed + "-a" + colorReset + "\n" +
+		colorGreen + "+b" + colorReset + "\n" +
+		" c\n"
+	if got := Colorize(in); got != want {
+		t.Errorf("Colorize() = %q, want %q", got, want)
+	}
+}
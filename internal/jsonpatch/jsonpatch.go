@@ -0,0 +1,316 @@
+package jsonpatch
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Operation names defined by RFC 6902 that Diff emits and
+// Apply understands.
+const (
+	OpAdd     = "add"
+	OpRemove  = "remove"
+	OpReplace = "replace"
+	OpTest    = "test"
+)
+
+// maxArrayCells bounds the size of the LCS table used to diff
+// arrays. Larger arrays are replaced as a whole.
+const maxArrayCells = 1 << 20
+
+// Operation is a single JSON Patch operation.
+type Operation struct {
+	Op    string `json:"op"`
+	Path  string `json:"path"`
+	Value any    `json:"value"`
+}
+
+// MarshalJSON omits value for remove operations and keeps it,
+// even when null, for all others.
+func (o Operation) MarshalJSON() ([]byte, error) {
+	type plain struct {
+		Op   string `json:"op"`
+		Path string `json:"path"`
+	}
+	type withValue Operation
+	if o.Op == OpRemove {
+		return json.Marshal(plain{Op: o.Op, Path: o.Path})
+	}
+	return json.Marshal(withValue(o))
+}
+
+// Patch is an ordered list of operations as defined by RFC 6902.
+type Patch []Operation
+
+// Decode parses JSON preserving numbers as json.Number so that
+// values compare and re-encode exactly.
+func Decode(data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	return v, nil
+}
+
+// Diff returns a patch that turns a into b. Every remove and
+// replace is preceded by a test of the old value so that applying
+// the patch to a document that has since changed fails instead of
+// removing the wrong entry.
+func Diff(a, b any) Patch {
+	var p Patch
+	diffValue(&p, "", a, b)
+	return p
+}
+
+func diffValue(p *Patch, path string, a, b any) {
+	if reflect.DeepEqual(a, b) {
+		return
+	}
+	switch av := a.(type) {
+	case map[string]any:
+		if bv, ok := b.(map[string]any); ok {
+			diffObject(p, path, av, bv)
+			return
+		}
+	case []any:
+		if bv, ok := b.([]any); ok {
+			diffArray(p, path, av, bv)
+			return
+		}
+	}
+	*p = append(*p,
+		Operation{Op: OpTest, Path: path, Value: a},
+		Operation{Op: OpReplace, Path: path, Value: b},
+	)
+}
+
+func diffObject(p *Patch, path string, a, b map[string]any) {
+	for _, k := range slices.Sorted(maps.Keys(a)) {
+		child := path + "/" + EscapeToken(k)
+		bv, ok := b[k]
+		if !ok {
+			*p = append(*p,
+				Operation{Op: OpTest, Path: child, Value: a[k]},
+				Operation{Op: OpRemove, Path: child},
+			)
+			continue
+		}
+		diffValue(p, child, a[k], bv)
+	}
+	for _, k := range slices.Sorted(maps.Keys(b)) {
+		if _, ok := a[k]; !ok {
+			*p = append(*p, Operation{Op: OpAdd, Path: path + "/" + EscapeToken(k), Value: b[k]})
+		}
+	}
+}
+
+// diffArray emits element-wise removes and adds along a longest
+// common subsequence, tracking the index each operation sees after
+// the preceding operations have been applied.
+func diffArray(p *Patch, path string, a, b []any) {
+	if len(a)*len(b) > maxArrayCells {
+		*p = append(*p,
+			Operation{Op: OpTest, Path: path, Value: a},
+			Operation{Op: OpReplace, Path: path, Value: b},
+		)
+		return
+	}
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if reflect.DeepEqual(a[i], b[j]) {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	i, j, idx := 0, 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && reflect.DeepEqual(a[i], b[j]):
+			i++
+			j++
+			idx++
+		case j == len(b) || (i < len(a) && lcs[i+1][j] >= lcs[i][j+1]):
+			elem := path + "/" + strconv.Itoa(idx)
+			*p = append(*p,
+				Operation{Op: OpTest, Path: elem, Value: a[i]},
+				Operation{Op: OpRemove, Path: elem},
+			)
+			i++
+		default:
+			*p = append(*p, Operation{Op: OpAdd, Path: path + "/" + strconv.Itoa(idx), Value: b[j]})
+			j++
+			idx++
+		}
+	}
+}
+
+// Apply applies p to doc and returns the resulting document.
+// doc is modified in place where possible. A failed test
+// operation returns an error and leaves the result undefined.
+func Apply(doc any, p Patch) (any, error) {
+	for i, op := range p {
+		tokens, err := parsePointer(op.Path)
+		if err != nil {
+			return nil, fmt.Errorf("operation %d: %w", i, err)
+		}
+		switch op.Op {
+		case OpTest:
+			cur, err := get(doc, tokens)
+			if err != nil {
+				return nil, fmt.Errorf("operation %d: test %s: %w", i, op.Path, err)
+			}
+			if !reflect.DeepEqual(cur, op.Value) {
+				return nil, fmt.Errorf("operation %d: test %s: value does not match", i, op.Path)
+			}
+		case OpAdd, OpRemove, OpReplace:
+			doc, err = mutate(doc, tokens, op)
+			if err != nil {
+				return nil, fmt.Errorf("operation %d: %s %s: %w", i, op.Op, op.Path, err)
+			}
+		default:
+			return nil, fmt.Errorf("operation %d: unsupported op %q", i, op.Op)
+		}
+	}
+	return doc, nil
+}
+
+func get(doc any, tokens []string) (any, error) {
+	cur := doc
+	for _, tok := range tokens {
+		switch v := cur.(type) {
+		case map[string]any:
+			next, ok := v[tok]
+			if !ok {
+				return nil, fmt.Errorf("member %q not found", tok)
+			}
+			cur = next
+		case []any:
+			i, err := arrayIndex(tok, len(v)-1)
+			if err != nil {
+				return nil, err
+			}
+			cur = v[i]
+		default:
+			return nil, fmt.Errorf("cannot index %T with %q", cur, tok)
+		}
+	}
+	return cur, nil
+}
+
+// mutate applies a single add, remove or replace operation and
+// returns the (possibly new) root. Arrays are rebuilt on every
+// change, so the parent container is updated with the new slice.
+func mutate(doc any, tokens []string, op Operation) (any, error) {
+	if len(tokens) == 0 {
+		if op.Op == OpRemove {
+			return nil, fmt.Errorf("cannot remove the document root")
+		}
+		return op.Value, nil
+	}
+	parentTokens, last := tokens[:len(tokens)-1], tokens[len(tokens)-1]
+	parent, err := get(doc, parentTokens)
+	if err != nil {
+		return nil, err
+	}
+	switch v := parent.(type) {
+	case map[string]any:
+		_, exists := v[last]
+		switch op.Op {
+		case OpAdd:
+			v[last] = op.Value
+		case OpRemove, OpReplace:
+			if !exists {
+				return nil, fmt.Errorf("member %q not found", last)
+			}
+			if op.Op == OpRemove {
+				delete(v, last)
+			} else {
+				v[last] = op.Value
+			}
+		}
+		return doc, nil
+	case []any:
+		var arr []any
+		switch op.Op {
+		case OpAdd:
+			i := len(v)
+			if last != "-" {
+				if i, err = arrayIndex(last, len(v)); err != nil {
+					return nil, err
+				}
+			}
+			arr = make([]any, 0, len(v)+1)
+			arr = append(arr, v[:i]...)
+			arr = append(arr, op.Value)
+			arr = append(arr, v[i:]...)
+		case OpRemove:
+			i, err := arrayIndex(last, len(v)-1)
+			if err != nil {
+				return nil, err
+			}
+			arr = make([]any, 0, len(v)-1)
+			arr = append(arr, v[:i]...)
+			arr = append(arr, v[i+1:]...)
+		case OpReplace:
+			i, err := arrayIndex(last, len(v)-1)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = op.Value
+			return doc, nil
+		}
+		return mutate(doc, parentTokens, Operation{Op: OpReplace, Value: arr})
+	default:
+		return nil, fmt.Errorf("cannot index %T with %q", parent, last)
+	}
+}
+
+func arrayIndex(tok string, maxIdx int) (int, error) {
+	i, err := strconv.Atoi(tok)
+	if err != nil || i < 0 || (len(tok) > 1 && tok[0] == '0') {
+		return 0, fmt.Errorf("invalid array index %q", tok)
+	}
+	if i > maxIdx {
+		return 0, fmt.Errorf("array index %d out of range", i)
+	}
+	return i, nil
+}
+
+func parsePointer(path string) ([]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(path, "/") {
+		return nil, fmt.Errorf("invalid JSON pointer %q", path)
+	}
+	parts := strings.Split(path[1:], "/")
+	for i, p := range parts {
+		parts[i] = UnescapeToken(p)
+	}
+	return parts, nil
+}
+
+// EscapeToken escapes a reference token for use in a JSON
+// Pointer (RFC 6901): "~" becomes "~0" and "/" becomes "~1".
+func EscapeToken(s string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(s)
+}
+
+// UnescapeToken reverses EscapeToken.
+func UnescapeToken(s string) string {
+	return strings.NewReplacer("~1", "/", "~0", "~").Replace(s)
+}
@@ -0,0 +1,174 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func mustDecode(t *testing.T, s string) any {
+	t.Helper()
+	v, err := Decode([]byte(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestDiff(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		a    string
+		b    string
+		want string
+	}{
+		{
+			name: "identical documents produce empty patch",
+			a:    `{"a":1}`,
+			b:    `{"a":1}`,
+			want: `null`,
+		},
+		{
+			name: "array element removal is tested first",
+			a:    `{"permissions":{"allow":["Read(/a)","Read(/b)","Read(/c)"]}}`,
+			b:    `{"permissions":{"allow":["Read(/a)","Read(/c)"]}}`,
+			want: `[{"op":"test","path":"/permissions/allow/1","value":"Read(/b)"},` +
+				`{"op":"remove","path":"/permissions/allow/1"}]`,
+		},
+		{
+			name: "consecutive removals use shifted indexes",
+			a:    `{"allow":["a","b","c","d"]}`,
+			b:    `{"allow":["a","d"]}`,
+			want: `[{"op":"test","path":"/allow/1","value":"b"},{"op":"remove","path":"/allow/1"},` +
+				`{"op":"test","path":"/allow/1","value":"c"},{"op":"remove","path":"/allow/1"}]`,
+		},
+		{
+			name: "object member with slash is escaped",
+			a:    `{"projects":{"/home/u/a":{"x":1},"/home/u/b~c":{}}}`,
+			b:    `{"projects":{"/home/u/a":{"x":1}}}`,
+			want: `[{"op":"test","path":"/projects/~1home~1u~1b~0c","value":{}},` +
+				`{"op":"remove","path":"/projects/~1home~1u~1b~0c"}]`,
+		},
+		{
+			name: "scalar change is replaced",
+			a:    `{"n":1}`,
+			b:    `{"n":2}`,
+			want: `[{"op":"test","path":"/n","value":1},{"op":"replace","path":"/n","value":2}]`,
+		},
+		{
+			name: "new member is added",
+			a:    `{}`,
+			b:    `{"k":null}`,
+			want: `[{"op":"add","path":"/k","value":null}]`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			p := Diff(mustDecode(t, tt.a), mustDecode(t, tt.b))
+			got, err := json.Marshal(p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Diff() =\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffApplyRoundTrip(t *testing.T) {
+	t.Parallel()
+	pairs := [][2]string{
+		{`{"a":["x","y","z"],"b":{"c":1}}`, `{"a":["z","x"],"b":{"d":[1,2]}}`},
+		{`{"a":[1,2,3,4,5]}`, `{"a":[5,4,3,2,1]}`},
+		{`{"a":[{"k":1},{"k":2}]}`, `{"a":[{"k":2},{"k":3}]}`},
+		{`{"a":"str"}`, `{"a":{"nested":true}}`},
+	}
+	for _, pair := range pairs {
+		a, b := mustDecode(t, pair[0]), mustDecode(t, pair[1])
+		p := Diff(mustDecode(t, pair[0]), b)
+		got, err := Apply(a, p)
+		if err != nil {
+			t.Fatalf("Apply(%s): %v", pair[0], err)
+		}
+		if !reflect.DeepEqual(got, b) {
+			t.Errorf("Apply(Diff(%s, %s)) = %v", pair[0], pair[1], got)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name    string
+		doc     string
+		patch   Patch
+		want    string
+		wantErr string
+	}{
+		{
+			name: "remove after successful test",
+			doc:  `{"allow":["a","b"]}`,
+			patch: Patch{
+				{Op: OpTest, Path: "/allow/1", Value: "b"},
+				{Op: OpRemove, Path: "/allow/1"},
+			},
+			want: `{"allow":["a"]}`,
+		},
+		{
+			name: "failed test aborts",
+			doc:  `{"allow":["a","c"]}`,
+			patch: Patch{
+				{Op: OpTest, Path: "/allow/1", Value: "b"},
+				{Op: OpRemove, Path: "/allow/1"},
+			},
+			wantErr: "value does not match",
+		},
+		{
+			name:    "missing member",
+			doc:     `{"projects":{}}`,
+			patch:   Patch{{Op: OpRemove, Path: "/projects/~1x"}},
+			wantErr: `member "/x" not found`,
+		},
+		{
+			name:  "add appends with dash",
+			doc:   `{"allow":["a"]}`,
+			patch: Patch{{Op: OpAdd, Path: "/allow/-", Value: "b"}},
+			want:  `{"allow":["a","b"]}`,
+		},
+		{
+			name:    "index out of range",
+			doc:     `{"allow":["a"]}`,
+			patch:   Patch{{Op: OpRemove, Path: "/allow/1"}},
+			wantErr: "out of range",
+		},
+		{
+			name:    "unsupported op",
+			doc:     `{}`,
+			patch:   Patch{{Op: "move", Path: "/a"}},
+			wantErr: `unsupported op "move"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := Apply(mustDecode(t, tt.doc), tt.patch)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Apply() error = %v, want containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			out, _ := json.Marshal(got)
+			if string(out) != tt.want {
+				t.Errorf("Apply() = %s, want %s", out, tt.want)
+			}
+		})
+	}
+}
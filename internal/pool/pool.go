@@ -0,0 +1,68 @@
+// Package pool runs indexed work items on a bounded number of
+// goroutines.
+package pool
+
+import (
+	"context"
+	"sync"
+)
+
+// Run calls fn for each index in [0, n) using at most jobs
+// goroutines. Items are started in index order, so every item
+// below the highest started index has been started too. Callers
+// store results by index to keep output order deterministic.
+//
+// Once fn returns a non-nil error or ctx is done, no further items
+// are started; items already running are waited for. Run returns
+// ctx.Err() when ctx ended the run, the first error returned by fn
+// otherwise. A jobs value below 1 is treated as 1.
+func Run(ctx context.Context, jobs, n int, fn func(ctx context.Context, i int) error) error {
+	if jobs < 1 {
+		jobs = 1
+	}
+	jobs = min(jobs, n)
+
+	var (
+		mu       sync.Mutex
+		next     int
+		firstErr error
+		wg       sync.WaitGroup
+	)
+	// claim returns the next index to run, or false when the run
+	// is finished or stopped.
+	claim := func() (int, bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		if firstErr != nil || ctx.Err() != nil || next >= n {
+			return 0, false
+		}
+		i := next
+		next++
+		return i, true
+	}
+	for range jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				i, ok := claim()
+				if !ok {
+					return
+				}
+				if err := fn(ctx, i); err != nil {
+					mu.Lock()
+					if firstErr == nil {
+						firstErr = err
+					}
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil && next < n {
+		return err
+	}
+	return firstErr
+}
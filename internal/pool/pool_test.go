@@ -0,0 +1,127 @@
+package pool_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/708u/cctidy/internal/pool"
+)
+
+func TestRunAllItems(t *testing.T) {
+	t.Parallel()
+
+	got := make([]int, 100)
+	err := pool.Run(t.Context(), 8, len(got), func(_ context.Context, i int) error {
+		got[i] = i * 2
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for i, v := range got {
+		if v != i*2 {
+			t.Fatalf("got[%d] = %d, want %d", i, v, i*2)
+		}
+	}
+}
+
+func TestRunBoundsConcurrency(t *testing.T) {
+	t.Parallel()
+
+	var running, peak atomic.Int32
+	release := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- pool.Run(t.Context(), 3, 20, func(_ context.Context, _ int) error {
+			n := running.Add(1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			<-release
+			running.Add(-1)
+			return nil
+		})
+	}()
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if p := peak.Load(); p > 3 {
+		t.Errorf("peak concurrency = %d, want <= 3", p)
+	}
+}
+
+func TestRunZeroJobsIsSequential(t *testing.T) {
+	t.Parallel()
+
+	var order []int
+	err := pool.Run(t.Context(), 0, 5, func(_ context.Context, i int) error {
+		order = append(order, i)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for i, v := range order {
+		if v != i {
+			t.Fatalf("order = %v, want ascending", order)
+		}
+	}
+}
+
+func TestRunStopsOnError(t *testing.T) {
+	t.Parallel()
+
+	errBoom := errors.New("boom")
+	var calls atomic.Int32
+	err := pool.Run(t.Context(), 1, 10, func(_ context.Context, i int) error {
+		calls.Add(1)
+		if i == 2 {
+			return errBoom
+		}
+		return nil
+	})
+	if !errors.Is(err, errBoom) {
+		t.Fatalf("err = %v, want %v", err, errBoom)
+	}
+	if n := calls.Load(); n != 3 {
+		t.Errorf("calls = %d, want 3", n)
+	}
+}
+
+func TestRunCanceled(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(t.Context())
+	var calls atomic.Int32
+	err := pool.Run(ctx, 1, 10, func(_ context.Context, i int) error {
+		calls.Add(1)
+		if i == 1 {
+			cancel()
+		}
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("calls = %d, want 2", n)
+	}
+}
+
+func TestRunEmpty(t *testing.T) {
+	t.Parallel()
+
+	err := pool.Run(t.Context(), 4, 0, func(_ context.Context, _ int) error {
+		t.Error("fn should not be called")
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
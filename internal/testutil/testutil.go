@@ -12,19 +12,41 @@ type PathSet struct {
 }
 
 // Exists reports whether p is in the set.
-func (ps PathSet) Exists(_ context.Context, p string) bool { return ps.s.Has(p) }
+func (ps PathSet) Exists(_ context.Context, p string) (bool, error) { return ps.s.Has(p), nil }
 
 // CheckerFor returns a PathSet containing the given paths.
 func CheckerFor(paths ...string) PathSet {
 	return PathSet{s: set.New(paths...)}
 }
 
+// UnknownSet is a PathChecker stub that fails with Err for paths in
+// Unknown and reports all other paths as existing if they are in Known.
+type UnknownSet struct {
+	Known   PathSet
+	Unknown set.Value[string]
+	Err     error
+}
+
+// Exists implements PathChecker.
+func (u UnknownSet) Exists(ctx context.Context, p string) (bool, error) {
+	if u.Unknown.Has(p) {
+		return false, u.Err
+	}
+	return u.Known.Exists(ctx, p)
+}
+
+// UnknownFor returns an UnknownSet that fails with err for the given
+// paths and reports every other path as non-existent.
+func UnknownFor(err error, paths ...string) UnknownSet {
+	return UnknownSet{Unknown: set.New(paths...), Err: err}
+}
+
 // AllPathsExist is a PathChecker stub that reports all paths as existing.
 type AllPathsExist struct{}
 
-func (AllPathsExist) Exists(context.Context, string) bool { return true }
+func (AllPathsExist) Exists(context.Context, string) (bool, error) { return true, nil }
 
 // NoPathsExist is a PathChecker stub that reports all paths as non-existent.
 type NoPathsExist struct{}
 
-func (NoPathsExist) Exists(context.Context, string) bool { return false }
+func (NoPathsExist) Exists(context.Context, string) (bool, error) { return false, nil }
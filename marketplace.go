@@ -0,0 +1,144 @@
+package cctidy
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/708u/cctidy/internal/set"
+)
+
+// LoadKnownMarketplaces reads known_marketplaces.json in pluginsDir
+// (normally ~/.claude/plugins) and returns the names of the
+// marketplaces Claude Code has added. A missing file or one that
+// lists no marketplaces yields nil, meaning the registry is
+// unavailable.
+func LoadKnownMarketplaces(pluginsDir string) (set.Value[string], error) {
+	path := filepath.Join(pluginsDir, "known_marketplaces.json")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	var file map[string]json.RawMessage
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if len(file) == 0 {
+		return nil, nil
+	}
+	return set.New(slices.Collect(maps.Keys(file))...), nil
+}
+
+// PluginSettingsResult holds the result of a PluginSettingsSweeper
+// sweep.
+type PluginSettingsResult struct {
+	SweptPlugins      int
+	SweptMarketplaces int
+	Records           []SweepRecord
+}
+
+// PluginSettingsSweeper sweeps stale "enabledPlugins" and
+// "extraKnownMarketplaces" entries from settings objects by
+// checking them against the local plugin and marketplace
+// registries. A nil registry is unavailable, and the entries it
+// would decide are kept.
+type PluginSettingsSweeper struct {
+	installed    set.Value[string]
+	marketplaces set.Value[string]
+}
+
+// NewPluginSettingsSweeper creates a PluginSettingsSweeper from the
+// installed plugins (see LoadInstalledPlugins) and the known
+// marketplace names (see LoadKnownMarketplaces). No installed
+// plugins means the plugin registry is unavailable.
+func NewPluginSettingsSweeper(plugins []InstalledPlugin, marketplaces set.Value[string]) *PluginSettingsSweeper {
+	s := &PluginSettingsSweeper{marketplaces: marketplaces}
+	if len(plugins) > 0 {
+		s.installed = set.New[string]()
+		for _, p := range plugins {
+			s.installed.Add(p.Name + "@" + p.Marketplace)
+		}
+	}
+	return s
+}
+
+// Sweep removes stale entries from obj. An "enabledPlugins" key
+// ("name@marketplace") is kept while its marketplace is declared in
+// "extraKnownMarketplaces" of the same file, and otherwise swept
+// when its marketplace is unknown or the plugin is not installed.
+// An "extraKnownMarketplaces" declaration is swept when the
+// marketplace is unknown and no remaining enabledPlugins key uses it.
+func (s *PluginSettingsSweeper) Sweep(obj map[string]any) *PluginSettingsResult {
+	result := &PluginSettingsResult{}
+	if s == nil {
+		return result
+	}
+	declared, _ := obj["extraKnownMarketplaces"].(map[string]any)
+	used := set.New[string]()
+
+	if enabled, ok := obj["enabledPlugins"].(map[string]any); ok {
+		for _, key := range slices.Sorted(maps.Keys(enabled)) {
+			rec := SweepRecord{Category: "enabledPlugins", Entry: key, Decision: DecisionKept}
+			rec.Reason = s.pluginReason(key, declared)
+			switch rec.Reason {
+			case ReasonPluginMissing, ReasonMarketplaceMissing:
+				rec.Decision = DecisionSwept
+				delete(enabled, key)
+				result.SweptPlugins++
+			default:
+				if _, marketplace, ok := strings.Cut(key, "@"); ok {
+					used.Add(marketplace)
+				}
+			}
+			result.Records = append(result.Records, rec)
+		}
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(declared)) {
+		rec := SweepRecord{Category: "extraKnownMarketplaces", Entry: name, Decision: DecisionKept}
+		switch {
+		case s.marketplaces == nil:
+			rec.Reason = ReasonPluginKept
+		case s.marketplaces.Has(name):
+			rec.Reason = ReasonMarketplaceKnown
+		case used.Has(name):
+			rec.Reason = ReasonMarketplaceInUse
+		default:
+			rec.Decision = DecisionSwept
+			rec.Reason = ReasonMarketplaceMissing
+			delete(declared, name)
+			result.SweptMarketplaces++
+		}
+		result.Records = append(result.Records, rec)
+	}
+	return result
+}
+
+// pluginReason decides an enabledPlugins key. An installed plugin
+// is kept even when its marketplace is not in the registry.
+func (s *PluginSettingsSweeper) pluginReason(key string, declared map[string]any) SweepReason {
+	_, marketplace, ok := strings.Cut(key, "@")
+	if !ok || marketplace == "" {
+		return ReasonUnrecognized
+	}
+	if _, ok := declared[marketplace]; ok {
+		return ReasonMarketplaceDeclared
+	}
+	if s.installed.Has(key) {
+		return ReasonPluginInstalled
+	}
+	if s.marketplaces != nil && !s.marketplaces.Has(marketplace) {
+		return ReasonMarketplaceMissing
+	}
+	if s.installed == nil {
+		return ReasonPluginKept
+	}
+	return ReasonPluginMissing
+}
@@ -0,0 +1,196 @@
+package cctidy
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/708u/cctidy/internal/set"
+)
+
+func TestLoadKnownMarketplaces(t *testing.T) {
+	t.Parallel()
+
+	t.Run("names are keys", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		os.WriteFile(filepath.Join(dir, "known_marketplaces.json"),
+			[]byte(`{"official":{"source":{"source":"github"}},"team":{}}`), 0o644)
+		got, err := LoadKnownMarketplaces(dir)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got.Len() != 2 || !got.Has("official") || !got.Has("team") {
+			t.Errorf("got %v, want official and team", got)
+		}
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		t.Parallel()
+		got, err := LoadKnownMarketplaces(t.TempDir())
+		if err != nil || got != nil {
+			t.Errorf("got %v, %v; want nil, nil", got, err)
+		}
+	})
+
+	t.Run("empty registry is unavailable", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		os.WriteFile(filepath.Join(dir, "known_marketplaces.json"), []byte(`{}`), 0o644)
+		got, err := LoadKnownMarketplaces(dir)
+		if err != nil || got != nil {
+			t.Errorf("got %v, %v; want nil, nil", got, err)
+		}
+	})
+
+	t.Run("invalid JSON", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		os.WriteFile(filepath.Join(dir, "known_marketplaces.json"), []byte("["), 0o644)
+		if _, err := LoadKnownMarketplaces(dir); err == nil {
+			t.Error("expected error for invalid JSON")
+		}
+	})
+}
+
+func TestPluginSettingsSweeper(t *testing.T) {
+	t.Parallel()
+
+	installed := []InstalledPlugin{{Name: "tools", Marketplace: "official"}}
+	known := set.New("official")
+	newObj := func() map[string]any {
+		return map[string]any{
+			"enabledPlugins": map[string]any{
+				"tools@official":   true,
+				"removed@official": true,
+				"old@gone":         false,
+				"shared@team":      true,
+				"malformed":        true,
+			},
+			"extraKnownMarketplaces": map[string]any{
+				"team":   map[string]any{},
+				"unused": map[string]any{},
+			},
+		}
+	}
+
+	tests := []struct {
+		name              string
+		sweeper           *PluginSettingsSweeper
+		wantReasons       map[string]SweepReason
+		wantPlugins       int
+		wantMarketplaces  int
+		wantRemainPlugins []string
+	}{
+		{
+			name:    "registries available",
+			sweeper: NewPluginSettingsSweeper(installed, known),
+			wantReasons: map[string]SweepReason{
+				"tools@official":   ReasonPluginInstalled,
+				"removed@official": ReasonPluginMissing,
+				"old@gone":         ReasonMarketplaceMissing,
+				"shared@team":      ReasonMarketplaceDeclared,
+				"malformed":        ReasonUnrecognized,
+				"team":             ReasonMarketplaceInUse,
+				"unused":           ReasonMarketplaceMissing,
+			},
+			wantPlugins:       2,
+			wantMarketplaces:  1,
+			wantRemainPlugins: []string{"malformed", "shared@team", "tools@official"},
+		},
+		{
+			name:    "registries unavailable",
+			sweeper: NewPluginSettingsSweeper(nil, nil),
+			wantReasons: map[string]SweepReason{
+				"tools@official":   ReasonPluginKept,
+				"removed@official": ReasonPluginKept,
+				"old@gone":         ReasonPluginKept,
+				"shared@team":      ReasonMarketplaceDeclared,
+				"malformed":        ReasonUnrecognized,
+				"team":             ReasonPluginKept,
+				"unused":           ReasonPluginKept,
+			},
+			wantRemainPlugins: []string{"malformed", "old@gone", "removed@official", "shared@team", "tools@official"},
+		},
+		{
+			name:    "empty plugin registry is unavailable",
+			sweeper: NewPluginSettingsSweeper([]InstalledPlugin{}, known),
+			wantReasons: map[string]SweepReason{
+				"tools@official":   ReasonPluginKept,
+				"removed@official": ReasonPluginKept,
+				"old@gone":         ReasonMarketplaceMissing,
+				"shared@team":      ReasonMarketplaceDeclared,
+				"malformed":        ReasonUnrecognized,
+				"team":             ReasonMarketplaceInUse,
+				"unused":           ReasonMarketplaceMissing,
+			},
+			wantPlugins:       1,
+			wantMarketplaces:  1,
+			wantRemainPlugins: []string{"malformed", "removed@official", "shared@team", "tools@official"},
+		},
+		{
+			name:    "empty marketplace registry is unavailable",
+			sweeper: NewPluginSettingsSweeper(installed, nil),
+			wantReasons: map[string]SweepReason{
+				"tools@official":   ReasonPluginInstalled,
+				"removed@official": ReasonPluginMissing,
+				"old@gone":         ReasonPluginMissing,
+				"shared@team":      ReasonMarketplaceDeclared,
+				"malformed":        ReasonUnrecognized,
+				"team":             ReasonPluginKept,
+				"unused":           ReasonPluginKept,
+			},
+			wantPlugins:       2,
+			wantRemainPlugins: []string{"malformed", "shared@team", "tools@official"},
+		},
+		{
+			name:    "installed plugin with unknown marketplace is kept",
+			sweeper: NewPluginSettingsSweeper(installed, set.New("other")),
+			wantReasons: map[string]SweepReason{
+				"tools@official":   ReasonPluginInstalled,
+				"removed@official": ReasonMarketplaceMissing,
+				"old@gone":         ReasonMarketplaceMissing,
+				"shared@team":      ReasonMarketplaceDeclared,
+				"malformed":        ReasonUnrecognized,
+				"team":             ReasonMarketplaceInUse,
+				"unused":           ReasonMarketplaceMissing,
+			},
+			wantPlugins:       2,
+			wantMarketplaces:  1,
+			wantRemainPlugins: []string{"malformed", "shared@team", "tools@official"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			obj := newObj()
+			result := tt.sweeper.Sweep(obj)
+			for _, rec := range result.Records {
+				if rec.Reason != tt.wantReasons[rec.Entry] {
+					t.Errorf("%s: reason = %s, want %s", rec.Entry, rec.Reason, tt.wantReasons[rec.Entry])
+				}
+			}
+			if len(result.Records) != len(tt.wantReasons) {
+				t.Errorf("records = %d, want %d", len(result.Records), len(tt.wantReasons))
+			}
+			if result.SweptPlugins != tt.wantPlugins || result.SweptMarketplaces != tt.wantMarketplaces {
+				t.Errorf("swept = %d plugins, %d marketplaces; want %d, %d",
+					result.SweptPlugins, result.SweptMarketplaces, tt.wantPlugins, tt.wantMarketplaces)
+			}
+			enabled := obj["enabledPlugins"].(map[string]any)
+			if got := slices.Sorted(maps.Keys(enabled)); !slices.Equal(got, tt.wantRemainPlugins) {
+				t.Errorf("enabledPlugins = %v, want %v", got, tt.wantRemainPlugins)
+			}
+		})
+	}
+
+	t.Run("nil sweeper", func(t *testing.T) {
+		t.Parallel()
+		var s *PluginSettingsSweeper
+		if r := s.Sweep(newObj()); len(r.Records) != 0 {
+			t.Errorf("records = %v, want none", r.Records)
+		}
+	})
+}
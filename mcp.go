@@ -185,7 +185,7 @@ func NewMCPToolSweeper(servers set.Value[string]) *MCPToolSweeper {
 // server is not in the known set.
 func (m *MCPToolSweeper) ShouldSweep(_ context.Context, entry MCPEntry) ToolSweepResult {
 	if m.servers.Has(entry.ServerName) {
-		return ToolSweepResult{}
+		return ToolSweepResult{Reason: ReasonMCPServerKnown}
 	}
-	return ToolSweepResult{Sweep: true}
+	return ToolSweepResult{Sweep: true, Reason: ReasonMCPServerUnknown}
 }
@@ -0,0 +1,258 @@
+package cctidy
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/708u/cctidy/internal/set"
+)
+
+// Errors returned by MountAwareChecker for paths whose existence
+// cannot be trusted. Callers treat them like any other unknown
+// status and keep the entry.
+var (
+	ErrVolatileRoot     = errors.New("under volatile root")
+	ErrMountUnavailable = errors.New("mount point unavailable")
+)
+
+// removableRoot describes a directory under which removable media
+// are mounted by convention, and how many path components below it
+// form the mount point (e.g. /run/media/<user>/<label>).
+type removableRoot struct {
+	dir   string
+	depth int
+}
+
+var removableRoots = []removableRoot{
+	{dir: "/run/media", depth: 2},
+	{dir: "/media", depth: 2},
+	{dir: "/mnt", depth: 1},
+	{dir: "/Volumes", depth: 1},
+}
+
+// MountTable holds the currently mounted filesystems and the mount
+// points expected from static configuration.
+type MountTable struct {
+	// Mounted is the set of active mount points.
+	Mounted set.Value[string]
+	// Expected lists mount points declared in fstab.
+	Expected []string
+}
+
+// LoadMountTable reads /proc/self/mountinfo and /etc/fstab.
+// Missing or unreadable files (e.g. on macOS) yield empty fields.
+func LoadMountTable() MountTable {
+	t := MountTable{Mounted: set.New[string]()}
+	if f, err := os.Open("/proc/self/mountinfo"); err == nil {
+		t.Mounted = parseMountInfo(f)
+		_ = f.Close()
+	}
+	if f, err := os.Open("/etc/fstab"); err == nil {
+		t.Expected = parseFstab(f)
+		_ = f.Close()
+	}
+	return t
+}
+
+// parseMountInfo extracts mount points (field 5) from the
+// proc(5) mountinfo format.
+func parseMountInfo(r io.Reader) set.Value[string] {
+	s := set.New[string]()
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) < 5 {
+			continue
+		}
+		s.Add(unescapeMountPath(fields[4]))
+	}
+	return s
+}
+
+// parseFstab extracts mount points (field 2) from fstab(5),
+// ignoring comments, swap and the root filesystem.
+func parseFstab(r io.Reader) []string {
+	var points []string
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		p := unescapeMountPath(fields[1])
+		if !filepath.IsAbs(p) || p == "/" {
+			continue
+		}
+		points = append(points, filepath.Clean(p))
+	}
+	return points
+}
+
+// unescapeMountPath decodes the octal escapes (\040 etc.) used
+// for whitespace and backslashes in mountinfo and fstab.
+func unescapeMountPath(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+4 <= len(s) {
+			if n, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// MountAwareChecker wraps a PathChecker and refuses to report a
+// path as missing when it lies under a configured volatile root or
+// when the volume that should contain it is not mounted.
+type MountAwareChecker struct {
+	inner         PathChecker
+	volatileRoots []string
+	mounts        MountTable
+	// readDir lists a mount point; tests replace it to simulate a
+	// hung network mount.
+	readDir func(string) ([]os.DirEntry, error)
+}
+
+// NewMountAwareChecker creates a MountAwareChecker.
+func NewMountAwareChecker(inner PathChecker, volatileRoots []string, mounts MountTable) *MountAwareChecker {
+	roots := make([]string, len(volatileRoots))
+	for i, r := range volatileRoots {
+		roots[i] = filepath.Clean(r)
+	}
+	if mounts.Mounted == nil {
+		mounts.Mounted = set.New[string]()
+	}
+	return &MountAwareChecker{inner: inner, volatileRoots: roots, mounts: mounts, readDir: os.ReadDir}
+}
+
+// Exists returns ErrVolatileRoot for paths under a volatile root
+// without consulting the inner checker. A path the inner checker
+// reports as missing is returned with ErrMountUnavailable when its
+// expected mount point is missing or empty and not mounted. The
+// mount point probe stops waiting when ctx is done, and
+// TimeoutChecker bounds the call as a whole.
+func (m *MountAwareChecker) Exists(ctx context.Context, path string) (bool, error) {
+	for _, root := range m.volatileRoots {
+		if isUnder(root, path) {
+			return false, fmt.Errorf("%w %s", ErrVolatileRoot, root)
+		}
+	}
+	exists, err := m.inner.Exists(ctx, path)
+	if err != nil || exists {
+		return exists, err
+	}
+	reason, err := m.unavailableMount(ctx, path)
+	if err != nil {
+		return false, err
+	}
+	if reason != "" {
+		return false, fmt.Errorf("%w: %s", ErrMountUnavailable, reason)
+	}
+	return false, nil
+}
+
+// CheckWalk returns an error when the contents of the existing
+// directory dir cannot be trusted: it lies under a volatile root
+// (ErrVolatileRoot), or the volume that should contain it is not
+// mounted and its mount point is empty (ErrMountUnavailable). An
+// empty mount point exists, so Exists alone does not catch it.
+// A probe still blocked when ctx is done returns ctx's error.
+func (m *MountAwareChecker) CheckWalk(ctx context.Context, dir string) error {
+	for _, root := range m.volatileRoots {
+		if isUnder(root, dir) {
+			return fmt.Errorf("%w %s", ErrVolatileRoot, root)
+		}
+	}
+	reason, err := m.unavailableMount(ctx, dir)
+	if err != nil {
+		return err
+	}
+	if reason != "" {
+		return fmt.Errorf("%w: %s", ErrMountUnavailable, reason)
+	}
+	return nil
+}
+
+// unavailableMount returns a description of why the mount point
+// expected to contain path is unavailable, or "" when it is
+// mounted or no mount point is expected. Listing a hung network
+// mount point can block, so the listing runs in the background and
+// ctx's error is returned once ctx is done.
+func (m *MountAwareChecker) unavailableMount(ctx context.Context, path string) (string, error) {
+	mp := m.expectedMountPoint(path)
+	if mp == "" || m.mounts.Mounted.Has(mp) {
+		return "", nil
+	}
+	type result struct {
+		entries []os.DirEntry
+		err     error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		entries, err := m.readDir(mp)
+		ch <- result{entries: entries, err: err}
+	}()
+	var r result
+	select {
+	case r = <-ch:
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+	switch {
+	case errors.Is(r.err, os.ErrNotExist):
+		return fmt.Sprintf("%s is missing", mp), nil
+	case r.err == nil && len(r.entries) == 0:
+		return fmt.Sprintf("%s is empty", mp), nil
+	}
+	return "", nil
+}
+
+// expectedMountPoint returns the deepest fstab mount point or
+// removable media mount point that contains path.
+func (m *MountAwareChecker) expectedMountPoint(path string) string {
+	best := ""
+	for _, p := range m.mounts.Expected {
+		if isUnder(p, path) && len(p) > len(best) {
+			best = p
+		}
+	}
+	for _, r := range removableRoots {
+		rel, err := filepath.Rel(r.dir, path)
+		if err != nil || !filepath.IsLocal(rel) || rel == "." {
+			continue
+		}
+		parts := strings.Split(rel, string(filepath.Separator))
+		if len(parts) < r.depth {
+			continue
+		}
+		p := filepath.Join(append([]string{r.dir}, parts[:r.depth]...)...)
+		if len(p) > len(best) {
+			best = p
+		}
+	}
+	return best
+}
+
+// isUnder reports whether path equals root or lies below it.
+func isUnder(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && filepath.IsLocal(rel)
+}
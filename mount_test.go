@@ -0,0 +1,176 @@
+package cctidy
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/708u/cctidy/internal/set"
+	"github.com/708u/cctidy/internal/testutil"
+)
+
+func TestParseMountInfo(t *testing.T) {
+	t.Parallel()
+	input := `22 1 8:1 / / rw,relatime shared:1 - ext4 /dev/sda1 rw
+36 22 8:17 / /mnt/My\040Drive rw,nosuid shared:2 - ext4 /dev/sdb1 rw
+short line
+`
+	got := parseMountInfo(strings.NewReader(input))
+	for _, p := range []string{"/", "/mnt/My Drive"} {
+		if !got.Has(p) {
+			t.Errorf("missing mount point %q", p)
+		}
+	}
+	if got.Len() != 2 {
+		t.Errorf("Len() = %d, want 2", got.Len())
+	}
+}
+
+func TestParseFstab(t *testing.T) {
+	t.Parallel()
+	input := `# comment
+UUID=1 / ext4 defaults 0 1
+UUID=2 none swap sw 0 0
+UUID=3 /data ext4 noauto 0 2
+
+/dev/mapper/vault /home/u/vault\040box ext4 noauto 0 2
+`
+	got := parseFstab(strings.NewReader(input))
+	want := []string{"/data", "/home/u/vault box"}
+	if !slices.Equal(got, want) {
+		t.Errorf("parseFstab() = %v, want %v", got, want)
+	}
+}
+
+func TestMountAwareChecker(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	emptyMount := filepath.Join(dir, "empty")
+	os.Mkdir(emptyMount, 0o755)
+	fullMount := filepath.Join(dir, "full")
+	os.Mkdir(fullMount, 0o755)
+	os.WriteFile(filepath.Join(fullMount, "other"), nil, 0o644)
+	mountedEmpty := filepath.Join(dir, "mounted")
+	os.Mkdir(mountedEmpty, 0o755)
+	missingMount := filepath.Join(dir, "missing")
+	volatile := filepath.Join(dir, "volatile")
+
+	mounts := MountTable{
+		Mounted:  set.New(mountedEmpty),
+		Expected: []string{emptyMount, fullMount, mountedEmpty, missingMount},
+	}
+	alive := filepath.Join(volatile, "alive")
+	checker := NewMountAwareChecker(testutil.CheckerFor(alive), []string{volatile + "/"}, mounts)
+
+	tests := []struct {
+		name       string
+		path       string
+		wantExists bool
+		wantErr    error
+	}{
+		{name: "volatile root wins over existence", path: alive, wantErr: ErrVolatileRoot},
+		{name: "volatile root itself", path: volatile, wantErr: ErrVolatileRoot},
+		{name: "empty unmounted mount point", path: filepath.Join(emptyMount, "proj"), wantErr: ErrMountUnavailable},
+		{name: "missing mount point", path: filepath.Join(missingMount, "a", "b"), wantErr: ErrMountUnavailable},
+		{name: "non-empty mount point is trusted", path: filepath.Join(fullMount, "proj")},
+		{name: "mounted volume is trusted", path: filepath.Join(mountedEmpty, "proj")},
+		{name: "no expected mount point", path: filepath.Join(dir, "plain", "proj")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			exists, err := checker.Exists(t.Context(), tt.path)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("Exists() error = %v, want %v", err, tt.wantErr)
+			}
+			if exists != tt.wantExists {
+				t.Errorf("Exists() = %v, want %v", exists, tt.wantExists)
+			}
+		})
+	}
+}
+
+func TestMountAwareCheckerCheckWalk(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	emptyMount := filepath.Join(dir, "empty")
+	os.Mkdir(emptyMount, 0o755)
+	fullMount := filepath.Join(dir, "full")
+	os.Mkdir(fullMount, 0o755)
+	os.WriteFile(filepath.Join(fullMount, "other"), nil, 0o644)
+	volatile := filepath.Join(dir, "volatile")
+
+	mounts := MountTable{Expected: []string{emptyMount, fullMount}}
+	checker := NewMountAwareChecker(testutil.AllPathsExist{}, []string{volatile}, mounts)
+
+	tests := []struct {
+		name    string
+		dir     string
+		wantErr error
+	}{
+		{name: "volatile root", dir: filepath.Join(volatile, "src"), wantErr: ErrVolatileRoot},
+		{name: "existing empty mount point", dir: emptyMount, wantErr: ErrMountUnavailable},
+		{name: "non-empty mount point", dir: fullMount},
+		{name: "no expected mount point", dir: dir},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			err := checker.CheckWalk(t.Context(), tt.dir)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Errorf("CheckWalk() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMountAwareCheckerHungMountPoint(t *testing.T) {
+	t.Parallel()
+	block := make(chan struct{})
+	t.Cleanup(func() { close(block) })
+	mounts := MountTable{Expected: []string{"/net/share"}}
+	checker := NewMountAwareChecker(testutil.NoPathsExist{}, nil, mounts)
+	checker.readDir = func(string) ([]os.DirEntry, error) {
+		<-block
+		return nil, nil
+	}
+
+	t.Run("Exists under TimeoutChecker", func(t *testing.T) {
+		t.Parallel()
+		bounded := NewTimeoutChecker(checker, 10*time.Millisecond)
+		_, err := bounded.Exists(t.Context(), "/net/share/proj")
+		if !errors.Is(err, ErrCheckTimeout) {
+			t.Errorf("Exists() error = %v, want %v", err, ErrCheckTimeout)
+		}
+	})
+
+	t.Run("CheckWalk", func(t *testing.T) {
+		t.Parallel()
+		err := checkWalkWithin(t.Context(), checker, "/net/share/proj", 10*time.Millisecond)
+		if !errors.Is(err, ErrCheckTimeout) {
+			t.Errorf("checkWalkWithin() error = %v, want %v", err, ErrCheckTimeout)
+		}
+	})
+}
+
+func TestMountAwareCheckerRecords(t *testing.T) {
+	t.Parallel()
+	checker := NewMountAwareChecker(testutil.NoPathsExist{}, []string{"/vol"}, MountTable{})
+	input := `{"projects": {"/vol/proj": {}, "/gone": {}}}`
+	result, err := NewClaudeJSONFormatter(checker).Format(t.Context(), []byte(input))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	s := result.Stats.(*ClaudeJSONFormatterStats)
+	want := []SweepRecord{
+		{Category: "projects", Entry: "/gone", Decision: DecisionSwept, Reason: ReasonPathMissing},
+		{Category: "projects", Entry: "/vol/proj", Decision: DecisionWarned, Reason: ReasonVolatileRoot, Detail: "under volatile root /vol"},
+	}
+	if !slices.Equal(s.Records, want) {
+		t.Errorf("Records mismatch:\ngot:  %+v\nwant: %+v", s.Records, want)
+	}
+}
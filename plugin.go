@@ -0,0 +1,367 @@
+package cctidy
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/708u/cctidy/internal/md"
+	"github.com/708u/cctidy/internal/set"
+)
+
+// InstalledPlugin is a plugin recorded in
+// ~/.claude/plugins/installed_plugins.json.
+type InstalledPlugin struct {
+	// Name is the plugin name, used as the namespace of its agents
+	// and skills ("name:agent").
+	Name string
+	// Marketplace is the marketplace the plugin was installed from.
+	Marketplace string
+	// InstallPath is the plugin root containing agents/, skills/
+	// and commands/.
+	InstallPath string
+	// Agents, Skills and MCPServers are the components the plugin
+	// provides, read from its root, its .claude-plugin/plugin.json
+	// manifest and its marketplace entry. They are nil when the
+	// install path cannot be read.
+	Agents     set.Value[string]
+	Skills     set.Value[string]
+	MCPServers set.Value[string]
+}
+
+// installedPluginRecord is one installation in installed_plugins.json.
+type installedPluginRecord struct {
+	InstallPath string `json:"installPath"`
+}
+
+// pluginManifest holds the component fields shared by a plugin's
+// .claude-plugin/plugin.json and its marketplace.json entry. Path
+// fields hold a string or a list of strings relative to the plugin
+// root; mcpServers may also be an inline server map.
+type pluginManifest struct {
+	Name       string          `json:"name"`
+	Agents     json.RawMessage `json:"agents"`
+	Commands   json.RawMessage `json:"commands"`
+	Skills     json.RawMessage `json:"skills"`
+	MCPServers json.RawMessage `json:"mcpServers"`
+}
+
+// LoadInstalledPlugins reads installed_plugins.json in pluginsDir
+// (normally ~/.claude/plugins) and the components of each plugin.
+// Plugins are keyed by "name@marketplace"; both the version 1
+// format (one record per key) and the version 2 format (a list of
+// records per key) are accepted, and every listed install path is
+// returned. Marketplace entries are read from the marketplace
+// caches in <pluginsDir>/marketplaces. Results are sorted by key.
+//
+// A missing file, or one listing no installed plugins, yields nil,
+// meaning the registry is unavailable. An empty registry is more
+// likely reset or partially written than a real state, and treating
+// it as authoritative would sweep every plugin entry.
+func LoadInstalledPlugins(pluginsDir string) ([]InstalledPlugin, error) {
+	path := filepath.Join(pluginsDir, "installed_plugins.json")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+
+	var file struct {
+		Plugins map[string]json.RawMessage `json:"plugins"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	marketplaces := map[string]map[string]pluginManifest{}
+	var plugins []InstalledPlugin
+	for _, key := range slices.Sorted(maps.Keys(file.Plugins)) {
+		name, marketplace, _ := strings.Cut(key, "@")
+		var records []installedPluginRecord
+		if err := json.Unmarshal(file.Plugins[key], &records); err != nil {
+			var record installedPluginRecord
+			if err := json.Unmarshal(file.Plugins[key], &record); err != nil {
+				return nil, fmt.Errorf("parsing %s: plugin %s: %w", path, key, err)
+			}
+			records = []installedPluginRecord{record}
+		}
+		entries, ok := marketplaces[marketplace]
+		if !ok {
+			entries = loadMarketplaceEntries(filepath.Join(pluginsDir, "marketplaces", marketplace))
+			marketplaces[marketplace] = entries
+		}
+		for _, r := range records {
+			if r.InstallPath == "" {
+				continue
+			}
+			p := InstalledPlugin{
+				Name:        name,
+				Marketplace: marketplace,
+				InstallPath: r.InstallPath,
+			}
+			entry, hasEntry := entries[name]
+			loadPluginComponents(&p, entry, hasEntry)
+			plugins = append(plugins, p)
+		}
+	}
+	return plugins, nil
+}
+
+// loadMarketplaceEntries reads the plugin entries of the
+// marketplace cached in dir, keyed by plugin name. A missing or
+// invalid marketplace.json yields no entries.
+func loadMarketplaceEntries(dir string) map[string]pluginManifest {
+	data, err := os.ReadFile(filepath.Join(dir, ".claude-plugin", "marketplace.json"))
+	if err != nil {
+		return nil
+	}
+	var file struct {
+		Plugins []pluginManifest `json:"plugins"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil
+	}
+	entries := make(map[string]pluginManifest, len(file.Plugins))
+	for _, e := range file.Plugins {
+		entries[e.Name] = e
+	}
+	return entries
+}
+
+// loadPluginComponents fills the component sets of p from the
+// default agents/, commands/, skills/ and .mcp.json locations and
+// from the paths declared in the plugin manifest and, if present,
+// the marketplace entry. Components stay nil when the install path
+// is not a readable directory.
+func loadPluginComponents(p *InstalledPlugin, entry pluginManifest, hasEntry bool) {
+	root := p.InstallPath
+	if _, err := os.ReadDir(root); err != nil {
+		return
+	}
+	p.Agents = LoadAgentNames(filepath.Join(root, "agents"))
+	p.Skills = LoadSkillNames(root)
+	p.MCPServers = set.New[string]()
+	addMCPServerFile(filepath.Join(root, ".mcp.json"), p.MCPServers)
+
+	var manifests []pluginManifest
+	if data, err := os.ReadFile(filepath.Join(root, ".claude-plugin", "plugin.json")); err == nil {
+		var m pluginManifest
+		if json.Unmarshal(data, &m) == nil {
+			manifests = append(manifests, m)
+		}
+	}
+	if hasEntry {
+		manifests = append(manifests, entry)
+	}
+	for _, m := range manifests {
+		for _, path := range manifestPaths(root, m.Agents) {
+			addAgentPath(path, p.Agents)
+		}
+		for _, path := range manifestPaths(root, m.Commands) {
+			addCommandPath(path, p.Skills)
+		}
+		for _, path := range manifestPaths(root, m.Skills) {
+			loadSkillsDir(path, "", p.Skills)
+		}
+		addManifestMCPServers(root, m.MCPServers, p.MCPServers)
+	}
+}
+
+// manifestPaths decodes a manifest path field holding a string or
+// a list of strings and resolves each path against root.
+func manifestPaths(root string, raw json.RawMessage) []string {
+	if len(raw) == 0 {
+		return nil
+	}
+	var paths []string
+	if err := json.Unmarshal(raw, &paths); err != nil {
+		var path string
+		if err := json.Unmarshal(raw, &path); err != nil {
+			return nil
+		}
+		paths = []string{path}
+	}
+	for i, path := range paths {
+		paths[i] = filepath.Join(root, path)
+	}
+	return paths
+}
+
+// addAgentPath adds the agents defined in path, a directory or a
+// single .md file, to s.
+func addAgentPath(path string, s set.Value[string]) {
+	if filepath.Ext(path) != ".md" {
+		loadAgentsDir(path, s)
+		return
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	if name := md.ParseName(data); name != "" {
+		s.Add(name)
+	}
+}
+
+// addCommandPath adds the commands defined in path, a directory or
+// a single .md file, to s.
+func addCommandPath(path string, s set.Value[string]) {
+	if filepath.Ext(path) != ".md" {
+		loadCommandsDir(path, "", s)
+		return
+	}
+	addSkillName(s, path, "", strings.TrimSuffix(filepath.Base(path), ".md"))
+}
+
+// addManifestMCPServers adds the MCP servers declared by a manifest
+// mcpServers field: an inline server map, or the path or paths of
+// JSON files defining servers.
+func addManifestMCPServers(root string, raw json.RawMessage, servers set.Value[string]) {
+	if len(raw) == 0 {
+		return
+	}
+	var inline map[string]json.RawMessage
+	if json.Unmarshal(raw, &inline) == nil {
+		for name := range inline {
+			servers.Add(name)
+		}
+		return
+	}
+	for _, path := range manifestPaths(root, raw) {
+		addMCPServerFile(path, servers)
+	}
+}
+
+// addMCPServerFile adds the servers defined in a plugin MCP file.
+// The servers are read from the mcpServers key, or from the top
+// level when the key is absent. Missing or invalid files are
+// ignored.
+func addMCPServerFile(path string, servers set.Value[string]) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	var obj map[string]json.RawMessage
+	if json.Unmarshal(data, &obj) != nil {
+		return
+	}
+	if raw, ok := obj["mcpServers"]; ok {
+		collectServerNames(raw, servers)
+		return
+	}
+	for name := range obj {
+		servers.Add(name)
+	}
+}
+
+// PluginIndex resolves plugin-namespaced permission entries against
+// installed plugins. A nil *PluginIndex means the plugin registry is
+// unavailable, and plugin entries are kept.
+type PluginIndex struct {
+	plugins map[string]*InstalledPlugin
+}
+
+// NewPluginIndex indexes plugins by name, merging the components of
+// every install of the same plugin. It returns nil when plugins is
+// empty (registry unavailable).
+func NewPluginIndex(plugins []InstalledPlugin) *PluginIndex {
+	if len(plugins) == 0 {
+		return nil
+	}
+	idx := &PluginIndex{plugins: make(map[string]*InstalledPlugin, len(plugins))}
+	for _, p := range plugins {
+		merged, ok := idx.plugins[p.Name]
+		if !ok {
+			p.Agents = maps.Clone(p.Agents)
+			p.Skills = maps.Clone(p.Skills)
+			p.MCPServers = maps.Clone(p.MCPServers)
+			idx.plugins[p.Name] = &p
+			continue
+		}
+		// An unreadable install makes the merged components
+		// unknown, since it may provide what the others lack.
+		if merged.Agents == nil || p.Agents == nil {
+			merged.Agents, merged.Skills, merged.MCPServers = nil, nil, nil
+			continue
+		}
+		maps.Copy(merged.Agents, p.Agents)
+		maps.Copy(merged.Skills, p.Skills)
+		maps.Copy(merged.MCPServers, p.MCPServers)
+	}
+	return idx
+}
+
+// componentResult decides a "plugin:name" agent or skill entry that
+// no scope provides. Entries of uninstalled plugins are swept with
+// ReasonPluginMissing, entries of installed plugins with missing.
+// Entries are kept when the registry or the plugin's install path
+// is unavailable.
+func (x *PluginIndex) componentResult(name string, missing SweepReason) ToolSweepResult {
+	if x == nil {
+		return ToolSweepResult{Reason: ReasonPluginKept}
+	}
+	plugin, _, _ := strings.Cut(name, ":")
+	p, ok := x.plugins[plugin]
+	switch {
+	case !ok:
+		return ToolSweepResult{Sweep: true, Reason: ReasonPluginMissing}
+	case p.Agents == nil:
+		return ToolSweepResult{Reason: ReasonPluginKept}
+	}
+	return ToolSweepResult{Sweep: true, Reason: missing}
+}
+
+// mcpResult decides an mcp__plugin_<plugin>_<server>__<tool> entry.
+// Claude Code names plugin servers "plugin:<plugin>:<server>" and
+// normalizes the name for tool names, so plugin and server names
+// are matched in that normalized form.
+func (x *PluginIndex) mcpResult(entry string) ToolSweepResult {
+	if x == nil {
+		return ToolSweepResult{Reason: ReasonPluginKept}
+	}
+	rest := strings.TrimPrefix(entry, "mcp__")
+	// Normalized names can be ambiguous ("a_b" + "c" vs "a" +
+	// "b_c"), so every plugin with a matching prefix is checked.
+	var matched, unknown bool
+	for _, p := range x.plugins {
+		if !strings.HasPrefix(rest, normalizeMCPName("plugin:"+p.Name+":")) {
+			continue
+		}
+		matched = true
+		if p.MCPServers == nil {
+			unknown = true
+			continue
+		}
+		for server := range p.MCPServers {
+			name := normalizeMCPName("plugin:" + p.Name + ":" + server)
+			if rest == name || strings.HasPrefix(rest, name+"__") {
+				return ToolSweepResult{Reason: ReasonMCPServerKnown, Scope: ScopePlugin}
+			}
+		}
+	}
+	switch {
+	case unknown:
+		return ToolSweepResult{Reason: ReasonPluginKept}
+	case matched:
+		return ToolSweepResult{Sweep: true, Reason: ReasonMCPServerUnknown}
+	}
+	return ToolSweepResult{Sweep: true, Reason: ReasonPluginMissing}
+}
+
+// normalizeMCPName replaces characters Claude Code does not allow in
+// MCP tool names with "_".
+func normalizeMCPName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '-':
+			return r
+		}
+		return '_'
+	}, name)
+}
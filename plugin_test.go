@@ -0,0 +1,180 @@
+package cctidy
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/708u/cctidy/internal/set"
+)
+
+func TestLoadInstalledPlugins(t *testing.T) {
+	t.Parallel()
+
+	t.Run("version 2 lists every install", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		data := `{
+  "version": 2,
+  "plugins": {
+    "tools@market": [
+      {"scope": "user", "installPath": "/plugins/tools/1.0.0"},
+      {"scope": "project", "installPath": "/plugins/tools/2.0.0"}
+    ],
+    "alpha@other": [{"installPath": "/plugins/alpha"}]
+  }
+}`
+		os.WriteFile(filepath.Join(dir, "installed_plugins.json"), []byte(data), 0o644)
+
+		got, err := LoadInstalledPlugins(dir)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := []string{
+			"alpha@other /plugins/alpha",
+			"tools@market /plugins/tools/1.0.0",
+			"tools@market /plugins/tools/2.0.0",
+		}
+		if got := pluginKeys(got); !slices.Equal(got, want) {
+			t.Errorf("got %+v, want %+v", got, want)
+		}
+	})
+
+	t.Run("version 1 single record", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		data := `{"version": 1, "plugins": {"tools@market": {"installPath": "/plugins/tools"}}}`
+		os.WriteFile(filepath.Join(dir, "installed_plugins.json"), []byte(data), 0o644)
+
+		got, err := LoadInstalledPlugins(dir)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := []string{"tools@market /plugins/tools"}
+		if got := pluginKeys(got); !slices.Equal(got, want) {
+			t.Errorf("got %+v, want %+v", got, want)
+		}
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		t.Parallel()
+		got, err := LoadInstalledPlugins(t.TempDir())
+		if err != nil || got != nil {
+			t.Errorf("got %v, %v; want nil, nil", got, err)
+		}
+	})
+
+	t.Run("no plugins is unavailable", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		os.WriteFile(filepath.Join(dir, "installed_plugins.json"), []byte(`{"version": 2, "plugins": {}}`), 0o644)
+		got, err := LoadInstalledPlugins(dir)
+		if err != nil || got != nil {
+			t.Errorf("got %v, %v; want nil, nil", got, err)
+		}
+	})
+
+	t.Run("components from root, manifest and marketplace", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		root := filepath.Join(dir, "cache", "tools")
+		write := func(path, data string) {
+			os.MkdirAll(filepath.Dir(path), 0o755)
+			os.WriteFile(path, []byte(data), 0o644)
+		}
+		write(filepath.Join(root, "agents", "reviewer.md"), "---\nname: reviewer\n---\n")
+		write(filepath.Join(root, "commands", "lint.md"), "# Lint")
+		write(filepath.Join(root, "skills", "fmt", "SKILL.md"), "# Fmt")
+		write(filepath.Join(root, ".mcp.json"), `{"mcpServers": {"github": {}}}`)
+		write(filepath.Join(root, "extra", "helper.md"), "---\nname: helper\n---\n")
+		write(filepath.Join(root, "more", "release.md"), "# Release")
+		write(filepath.Join(root, ".claude-plugin", "plugin.json"),
+			`{"name": "tools", "agents": "./extra/helper.md", "mcpServers": {"db": {}}}`)
+		write(filepath.Join(dir, "marketplaces", "market", ".claude-plugin", "marketplace.json"),
+			`{"plugins": [{"name": "tools", "commands": ["./more"]}]}`)
+		write(filepath.Join(dir, "installed_plugins.json"),
+			`{"plugins": {"tools@market": [{"installPath": "`+root+`"}], "gone@market": [{"installPath": "`+filepath.Join(dir, "nope")+`"}]}}`)
+
+		got, err := LoadInstalledPlugins(dir)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(got) != 2 {
+			t.Fatalf("got %d plugins, want 2", len(got))
+		}
+		if got[0].Agents != nil {
+			t.Errorf("unreadable install should have nil components, got %v", got[0].Agents)
+		}
+		tools := got[1]
+		for _, c := range []struct {
+			set  map[string]struct{}
+			want []string
+		}{
+			{tools.Agents, []string{"reviewer", "helper"}},
+			{tools.Skills, []string{"lint", "fmt", "release"}},
+			{tools.MCPServers, []string{"github", "db"}},
+		} {
+			for _, name := range c.want {
+				if _, ok := c.set[name]; !ok {
+					t.Errorf("%q missing from %v", name, c.set)
+				}
+			}
+		}
+	})
+
+	t.Run("invalid JSON", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		os.WriteFile(filepath.Join(dir, "installed_plugins.json"), []byte("{"), 0o644)
+		if _, err := LoadInstalledPlugins(dir); err == nil {
+			t.Error("expected error for invalid JSON")
+		}
+	})
+}
+
+func pluginKeys(plugins []InstalledPlugin) []string {
+	var keys []string
+	for _, p := range plugins {
+		keys = append(keys, p.Name+"@"+p.Marketplace+" "+p.InstallPath)
+	}
+	return keys
+}
+
+func TestPluginIndex(t *testing.T) {
+	t.Parallel()
+	idx := NewPluginIndex([]InstalledPlugin{
+		{Name: "tools", Agents: set.New("reviewer"), Skills: set.New("lint"), MCPServers: set.New("github", "my.db")},
+		{Name: "broken"},
+	})
+
+	tests := []struct {
+		name      string
+		result    ToolSweepResult
+		wantSweep bool
+		wantWhy   SweepReason
+	}{
+		{"installed plugin, missing agent", idx.componentResult("tools:gone", ReasonAgentMissing), true, ReasonAgentMissing},
+		{"uninstalled plugin", idx.componentResult("old:agent", ReasonAgentMissing), true, ReasonPluginMissing},
+		{"unreadable plugin", idx.componentResult("broken:agent", ReasonAgentMissing), false, ReasonPluginKept},
+		{"nil index", (*PluginIndex)(nil).componentResult("old:agent", ReasonAgentMissing), false, ReasonPluginKept},
+		{"known server", idx.mcpResult("mcp__plugin_tools_github__search"), false, ReasonMCPServerKnown},
+		{"known server without tool", idx.mcpResult("mcp__plugin_tools_github"), false, ReasonMCPServerKnown},
+		{"normalized server name", idx.mcpResult("mcp__plugin_tools_my_db__query"), false, ReasonMCPServerKnown},
+		{"missing server", idx.mcpResult("mcp__plugin_tools_slack__post"), true, ReasonMCPServerUnknown},
+		{"uninstalled plugin server", idx.mcpResult("mcp__plugin_old_github__search"), true, ReasonPluginMissing},
+		{"unreadable plugin server", idx.mcpResult("mcp__plugin_broken_x__y"), false, ReasonPluginKept},
+		{"nil index server", (*PluginIndex)(nil).mcpResult("mcp__plugin_old_x__y"), false, ReasonPluginKept},
+		{"empty index", NewPluginIndex([]InstalledPlugin{}).componentResult("old:agent", ReasonAgentMissing), false, ReasonPluginKept},
+		{"empty index server", NewPluginIndex([]InstalledPlugin{}).mcpResult("mcp__plugin_old_x__y"), false, ReasonPluginKept},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if tt.result.Sweep != tt.wantSweep || tt.result.Reason != tt.wantWhy {
+				t.Errorf("got sweep=%v reason=%s, want sweep=%v reason=%s",
+					tt.result.Sweep, tt.result.Reason, tt.wantSweep, tt.wantWhy)
+			}
+		})
+	}
+}
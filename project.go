@@ -0,0 +1,37 @@
+package cctidy
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+)
+
+// LoadProjectPaths reads ~/.claude.json and returns the keys of
+// its "projects" object in sorted order. A missing file or a
+// missing "projects" key yields no paths. Existence of the
+// returned paths is not checked.
+func LoadProjectPaths(claudeJSONPath string) ([]string, error) {
+	data, err := os.ReadFile(claudeJSONPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("reading %s: %w", claudeJSONPath, err)
+	}
+
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", claudeJSONPath, err)
+	}
+	raw, ok := obj["projects"]
+	if !ok {
+		return nil, nil
+	}
+	var projects map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &projects); err != nil {
+		return nil, nil
+	}
+	return slices.Sorted(maps.Keys(projects)), nil
+}
@@ -0,0 +1,67 @@
+package cctidy
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestLoadProjectPaths(t *testing.T) {
+	t.Parallel()
+
+	t.Run("returns sorted project keys", func(t *testing.T) {
+		t.Parallel()
+		path := filepath.Join(t.TempDir(), ".claude.json")
+		os.WriteFile(path, []byte(`{
+  "projects": {
+    "/work/zeta": {},
+    "/work/alpha": {"mcpServers": {}}
+  }
+}`), 0o644)
+
+		got, err := LoadProjectPaths(path)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := []string{"/work/alpha", "/work/zeta"}
+		if !slices.Equal(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	})
+
+	t.Run("missing file returns nothing", func(t *testing.T) {
+		t.Parallel()
+		got, err := LoadProjectPaths(filepath.Join(t.TempDir(), "missing.json"))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(got) != 0 {
+			t.Errorf("got %v, want none", got)
+		}
+	})
+
+	t.Run("missing projects key returns nothing", func(t *testing.T) {
+		t.Parallel()
+		path := filepath.Join(t.TempDir(), ".claude.json")
+		os.WriteFile(path, []byte(`{"numStartups": 1}`), 0o644)
+
+		got, err := LoadProjectPaths(path)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(got) != 0 {
+			t.Errorf("got %v, want none", got)
+		}
+	})
+
+	t.Run("invalid JSON returns error", func(t *testing.T) {
+		t.Parallel()
+		path := filepath.Join(t.TempDir(), ".claude.json")
+		os.WriteFile(path, []byte(`{invalid`), 0o644)
+
+		if _, err := LoadProjectPaths(path); err == nil {
+			t.Error("expected error for invalid JSON")
+		}
+	})
+}
@@ -0,0 +1,103 @@
+package cctidy
+
+import "fmt"
+
+// Removal counts the entries of one category in a file before
+// formatting and how many of them were removed.
+type Removal struct {
+	Category string
+	Before   int
+	Removed  int
+}
+
+// Removals returns the project and repo path removals.
+func (s *ClaudeJSONFormatterStats) Removals() []Removal {
+	return []Removal{
+		{Category: "projects", Before: s.ProjectsBefore, Removed: s.ProjectsBefore - s.ProjectsAfter},
+		{Category: "repo_paths", Before: s.RepoBefore, Removed: s.RepoBefore - s.RepoAfter},
+	}
+}
+
+// Removals returns the permission entries removed from allow, ask
+// and additionalDirectories, and the removed enabledPlugins and
+// extraKnownMarketplaces entries. Before counts every evaluated
+// entry in those categories.
+func (s *SettingsJSONFormatterStats) Removals() []Removal {
+	var permissions, plugins, marketplaces int
+	for _, r := range s.Records {
+		switch r.Category {
+		case "allow", "ask", "additionalDirectories":
+			permissions++
+		case "enabledPlugins":
+			plugins++
+		case "extraKnownMarketplaces":
+			marketplaces++
+		}
+	}
+	return []Removal{
+		{Category: "permissions", Before: permissions, Removed: s.SweptAllow + s.SweptAsk + s.SweptDirectories},
+		{Category: "plugins", Before: plugins, Removed: s.SweptPlugins},
+		{Category: "marketplaces", Before: marketplaces, Removed: s.SweptMarketplaces},
+	}
+}
+
+// DefaultSafety holds the thresholds used for categories that the
+// config does not set. They are meant to catch a run in which
+// every path looks missing (a bad home directory, a chroot or a
+// broken mount) without tripping on routine cleanups. Setting a
+// field to 0 in the config disables it.
+var DefaultSafety = SafetyConfig{
+	Projects:     SafetyLimit{MaxPercent: 50},
+	RepoPaths:    SafetyLimit{MaxPercent: 50},
+	Permissions:  SafetyLimit{MaxCount: 20},
+	Plugins:      SafetyLimit{MaxCount: 10},
+	Marketplaces: SafetyLimit{MaxCount: 5},
+}
+
+// SafetyPercentFloor is the number of removed entries up to which
+// MaxPercent is not checked, so that removing one stale entry of a
+// short list is not refused.
+const SafetyPercentFloor = 5
+
+// LimitViolation describes a safety threshold exceeded by a removal.
+type LimitViolation struct {
+	Removal
+	Limit string
+}
+
+func (v LimitViolation) String() string {
+	return fmt.Sprintf("%s: would remove %d of %d (limit %s)",
+		v.Category, v.Removed, v.Before, v.Limit)
+}
+
+// Check returns the thresholds exceeded by removals.
+func (c SafetyConfig) Check(removals []Removal) []LimitViolation {
+	var violations []LimitViolation
+	for _, r := range removals {
+		var l SafetyLimit
+		switch r.Category {
+		case "projects":
+			l = c.Projects
+		case "repo_paths":
+			l = c.RepoPaths
+		case "permissions":
+			l = c.Permissions
+		case "plugins":
+			l = c.Plugins
+		case "marketplaces":
+			l = c.Marketplaces
+		default:
+			continue
+		}
+		if r.Removed == 0 {
+			continue
+		}
+		if l.MaxCount > 0 && r.Removed > l.MaxCount {
+			violations = append(violations, LimitViolation{Removal: r, Limit: fmt.Sprintf("max_count %d", l.MaxCount)})
+		}
+		if l.MaxPercent > 0 && r.Removed > SafetyPercentFloor && r.Removed*100 > l.MaxPercent*r.Before {
+			violations = append(violations, LimitViolation{Removal: r, Limit: fmt.Sprintf("max_percent %d%%", l.MaxPercent)})
+		}
+	}
+	return violations
+}
@@ -0,0 +1,187 @@
+package cctidy
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/708u/cctidy/internal/set"
+	"github.com/708u/cctidy/internal/testutil"
+)
+
+func TestSafetyConfigCheck(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name     string
+		cfg      SafetyConfig
+		removals []Removal
+		want     []string
+	}{
+		{
+			name:     "zero config never trips",
+			removals: []Removal{{Category: "projects", Before: 10, Removed: 10}},
+		},
+		{
+			name:     "count exceeded",
+			cfg:      SafetyConfig{Projects: SafetyLimit{MaxCount: 3}},
+			removals: []Removal{{Category: "projects", Before: 10, Removed: 4}},
+			want:     []string{"projects: would remove 4 of 10 (limit max_count 3)"},
+		},
+		{
+			name:     "count at limit is allowed",
+			cfg:      SafetyConfig{Projects: SafetyLimit{MaxCount: 3}},
+			removals: []Removal{{Category: "projects", Before: 10, Removed: 3}},
+		},
+		{
+			name:     "percent exceeded",
+			cfg:      SafetyConfig{RepoPaths: SafetyLimit{MaxPercent: 50}},
+			removals: []Removal{{Category: "repo_paths", Before: 10, Removed: 6}},
+			want:     []string{"repo_paths: would remove 6 of 10 (limit max_percent 50%)"},
+		},
+		{
+			name:     "percent at limit is allowed",
+			cfg:      SafetyConfig{Permissions: SafetyLimit{MaxPercent: 50}},
+			removals: []Removal{{Category: "permissions", Before: 12, Removed: 6}},
+		},
+		{
+			name:     "percent ignored for 1 of 1",
+			cfg:      DefaultSafety,
+			removals: []Removal{{Category: "repo_paths", Before: 1, Removed: 1}},
+		},
+		{
+			name:     "percent ignored for 2 of 3",
+			cfg:      DefaultSafety,
+			removals: []Removal{{Category: "projects", Before: 3, Removed: 2}},
+		},
+		{
+			name:     "percent ignored at the floor",
+			cfg:      DefaultSafety,
+			removals: []Removal{{Category: "projects", Before: SafetyPercentFloor, Removed: SafetyPercentFloor}},
+		},
+		{
+			name:     "percent checked above the floor",
+			cfg:      DefaultSafety,
+			removals: []Removal{{Category: "projects", Before: 6, Removed: 6}},
+			want:     []string{"projects: would remove 6 of 6 (limit max_percent 50%)"},
+		},
+		{
+			name:     "both limits reported",
+			cfg:      SafetyConfig{Permissions: SafetyLimit{MaxCount: 1, MaxPercent: 10}},
+			removals: []Removal{{Category: "permissions", Before: 20, Removed: 6}},
+			want: []string{
+				"permissions: would remove 6 of 20 (limit max_count 1)",
+				"permissions: would remove 6 of 20 (limit max_percent 10%)",
+			},
+		},
+		{
+			name:     "plugins exceeded",
+			cfg:      SafetyConfig{Plugins: SafetyLimit{MaxCount: 1}},
+			removals: []Removal{{Category: "plugins", Before: 3, Removed: 2}},
+			want:     []string{"plugins: would remove 2 of 3 (limit max_count 1)"},
+		},
+		{
+			name:     "marketplaces exceeded",
+			cfg:      SafetyConfig{Marketplaces: SafetyLimit{MaxPercent: 50}},
+			removals: []Removal{{Category: "marketplaces", Before: 8, Removed: 7}},
+			want:     []string{"marketplaces: would remove 7 of 8 (limit max_percent 50%)"},
+		},
+		{
+			name:     "nothing removed never trips",
+			cfg:      SafetyConfig{Projects: SafetyLimit{MaxPercent: 1}},
+			removals: []Removal{{Category: "projects", Before: 0, Removed: 0}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var got []string
+			for _, v := range tt.cfg.Check(tt.removals) {
+				got = append(got, v.String())
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Check() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRemovals(t *testing.T) {
+	t.Parallel()
+
+	t.Run("claude json", func(t *testing.T) {
+		t.Parallel()
+		input := `{"projects": {"/a": {}, "/b": {}, "/c": {}}, "githubRepoPaths": {"r": ["/a", "/d"]}}`
+		result, err := NewClaudeJSONFormatter(testutil.CheckerFor("/a")).Format(t.Context(), []byte(input))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := result.Stats.(*ClaudeJSONFormatterStats).Removals()
+		want := []Removal{
+			{Category: "projects", Before: 3, Removed: 2},
+			{Category: "repo_paths", Before: 2, Removed: 1},
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("Removals() = %+v, want %+v", got, want)
+		}
+	})
+
+	t.Run("settings", func(t *testing.T) {
+		t.Parallel()
+		input := `{"permissions": {"allow": ["Read(//a)", "Read(//b)"], "ask": ["Edit(//c)"], "deny": ["Read(//d)"]}}`
+		sweeper := mustNewPermissionSweeper(t, testutil.CheckerFor("/a", "/a/"), "", nil)
+		result, err := NewSettingsJSONFormatter(sweeper).Format(t.Context(), []byte(input))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := result.Stats.(*SettingsJSONFormatterStats).Removals()
+		want := []Removal{
+			{Category: "permissions", Before: 3, Removed: 2},
+			{Category: "plugins"},
+			{Category: "marketplaces"},
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("Removals() = %+v, want %+v", got, want)
+		}
+	})
+
+	t.Run("settings with additional directories", func(t *testing.T) {
+		t.Parallel()
+		input := `{"permissions": {"allow": ["Read(//a)"], "additionalDirectories": ["/a", "/gone"]}}`
+		sweeper := mustNewPermissionSweeper(t, testutil.CheckerFor("/a", "/a/"), "", nil)
+		result, err := NewSettingsJSONFormatter(sweeper).Format(t.Context(), []byte(input))
+		if err != nil {
+			t.Fatal(err)
+		}
+		stats := result.Stats.(*SettingsJSONFormatterStats)
+		if stats.SweptDirectories != 1 {
+			t.Errorf("SweptDirectories = %d, want 1", stats.SweptDirectories)
+		}
+		got := stats.Removals()
+		want := []Removal{
+			{Category: "permissions", Before: 3, Removed: 1},
+			{Category: "plugins"},
+			{Category: "marketplaces"},
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("Removals() = %+v, want %+v", got, want)
+		}
+	})
+	t.Run("settings with plugin settings", func(t *testing.T) {
+		t.Parallel()
+		input := `{"enabledPlugins": {"a@m": true, "b@m": true}, "extraKnownMarketplaces": {"gone": {}}}`
+		f := NewSettingsJSONFormatter(nil)
+		f.Plugins = NewPluginSettingsSweeper([]InstalledPlugin{{Name: "a", Marketplace: "m"}}, set.New("m"))
+		result, err := f.Format(t.Context(), []byte(input))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := result.Stats.(*SettingsJSONFormatterStats).Removals()
+		want := []Removal{
+			{Category: "permissions"},
+			{Category: "plugins", Before: 2, Removed: 1},
+			{Category: "marketplaces", Before: 1, Removed: 1},
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("Removals() = %+v, want %+v", got, want)
+		}
+	})
+}
@@ -0,0 +1,98 @@
+package cctidy
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/708u/cctidy/internal/set"
+)
+
+// Scope identifies where Claude Code found an agent or skill.
+type Scope string
+
+const (
+	ScopeProject Scope = "project"
+	ScopeUser    Scope = "user"
+	ScopePlugin  Scope = "plugin"
+)
+
+// ScopedNames maps agent or skill names to the scope that
+// provides them. Plugin names are namespaced as "plugin:name".
+type ScopedNames map[string]Scope
+
+// Add records names under scope. Names already present keep their
+// earlier scope, so scopes should be added in precedence order.
+func (n ScopedNames) Add(scope Scope, names set.Value[string]) {
+	for name := range names {
+		if _, ok := n[name]; !ok {
+			n[name] = scope
+		}
+	}
+}
+
+// Lookup returns the scope providing name.
+func (n ScopedNames) Lookup(name string) (Scope, bool) {
+	scope, ok := n[name]
+	return scope, ok
+}
+
+// hasLocal reports whether any name comes from a project or user
+// .claude directory. Without such names there is nothing to compare
+// unnamespaced entries against.
+func (n ScopedNames) hasLocal() bool {
+	for _, scope := range n {
+		if scope != ScopePlugin {
+			return true
+		}
+	}
+	return false
+}
+
+// hasLocalNamespace reports whether a project or user name is
+// namespaced under ns, as nested commands ("ns:name") are.
+func (n ScopedNames) hasLocalNamespace(ns string) bool {
+	for name, scope := range n {
+		if scope != ScopePlugin && strings.HasPrefix(name, ns+":") {
+			return true
+		}
+	}
+	return false
+}
+
+// scopeDir is a .claude directory searched for agents and skills.
+type scopeDir struct {
+	scope Scope
+	dir   string
+}
+
+// loadScopedNames collects names from each scope directory, in
+// order, using load, and then from installed plugins, using
+// components. Plugin names are prefixed with the plugin name.
+func loadScopedNames(dirs []scopeDir, load func(dir string) set.Value[string], plugins []InstalledPlugin, components func(InstalledPlugin) set.Value[string]) ScopedNames {
+	names := ScopedNames{}
+	for _, d := range dirs {
+		if d.dir != "" {
+			names.Add(d.scope, load(d.dir))
+		}
+	}
+	for _, p := range plugins {
+		namespaced := set.New[string]()
+		for name := range components(p) {
+			namespaced.Add(p.Name + ":" + name)
+		}
+		names.Add(ScopePlugin, namespaced)
+	}
+	return names
+}
+
+// loadAgents returns the agent names defined under a .claude
+// directory or plugin root.
+func loadAgents(dir string) set.Value[string] {
+	return LoadAgentNames(filepath.Join(dir, "agents"))
+}
+
+// pluginAgents returns the agents provided by p.
+func pluginAgents(p InstalledPlugin) set.Value[string] { return p.Agents }
+
+// pluginSkills returns the skills and commands provided by p.
+func pluginSkills(p InstalledPlugin) set.Value[string] { return p.Skills }
@@ -0,0 +1,253 @@
+package cctidy
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/708u/cctidy/internal/set"
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// shellBuiltins lists Bash builtins and keywords that can appear as
+// the first word of a simple command. They have no executable on
+// disk and are never reported missing. The parser turns most
+// keywords and declarations into their own clauses, but a quoted
+// or escaped one (\time, "export") is a simple command.
+var shellBuiltins = set.New(
+	// builtins
+	".", ":", "[", "alias", "bg", "bind", "break", "builtin",
+	"caller", "cd", "command", "compgen", "complete", "compopt",
+	"continue", "declare", "dirs", "disown", "echo", "enable",
+	"eval", "exec", "exit", "export", "false", "fc", "fg",
+	"getopts", "hash", "help", "history", "jobs", "kill", "let",
+	"local", "logout", "mapfile", "popd", "printf", "pushd", "pwd",
+	"read", "readarray", "readonly", "return", "set", "shift",
+	"shopt", "source", "suspend", "test", "times", "trap", "true",
+	"type", "typeset", "ulimit", "umask", "unalias", "unset", "wait",
+	// keywords
+	"!", "[[", "]]", "{", "}", "case", "coproc", "do", "done",
+	"elif", "else", "esac", "fi", "for", "function", "if", "in",
+	"select", "then", "time", "until", "while",
+)
+
+// commandPaths holds the path arguments found in a Bash specifier.
+// Abs holds cleaned absolute paths. Rel holds paths prefixed with
+// ./, ../ or ~/, with trailing slashes trimmed. Unknown holds the
+// variable references ($NAME) that could not be expanded in words
+// containing a /. Programs holds the first word of each simple
+// command when it is fully literal.
+type commandPaths struct {
+	Abs      []string
+	Rel      []string
+	Unknown  []string
+	Programs []string
+}
+
+// extractCommandPaths parses a Bash permission specifier as a shell
+// command line and collects path arguments from every simple
+// command, including those joined by &&, ||, ; and pipes and those
+// nested in $(...) or backquotes. Command names, arguments,
+// assignment values (VAR=/path) and redirection targets are
+// considered. A word is a path only when it is fully literal after
+// quote removal and variable expansion, and starts with /, ./, ../
+// or ~/ (optionally after a --flag= prefix). $NAME and ${NAME} are
+// expanded from vars; a word referencing any other variable is
+// recorded in Unknown when it contains a /. Words with other
+// parameter expansions or command substitutions are skipped; for
+// glob words, the directory before the first glob character is used.
+//
+// A trailing ":*" prefix-match marker is removed before parsing.
+// A specifier that does not parse returns an error.
+func extractCommandPaths(specifier string, vars pathVars) (commandPaths, error) {
+	src := strings.TrimSuffix(specifier, ":*")
+	f, err := syntax.NewParser(syntax.Variant(syntax.LangBash)).Parse(strings.NewReader(src), "")
+	if err != nil {
+		return commandPaths{}, err
+	}
+
+	var paths commandPaths
+	add := func(w *syntax.Word) {
+		if w == nil {
+			return
+		}
+		p, unknown := wordPath(w, vars)
+		if unknown != "" {
+			paths.Unknown = append(paths.Unknown, "$"+unknown)
+			return
+		}
+		switch {
+		case p == "":
+		case strings.HasPrefix(p, "/"):
+			if cleaned := filepath.Clean(p); cleaned != "/" {
+				paths.Abs = append(paths.Abs, cleaned)
+			}
+		default:
+			if trimmed := strings.TrimRight(p, "/"); trimmed != "" {
+				paths.Rel = append(paths.Rel, trimmed)
+			}
+		}
+	}
+	addAssigns := func(assigns []*syntax.Assign) {
+		for _, a := range assigns {
+			add(a.Value)
+		}
+	}
+
+	syntax.Walk(f, func(node syntax.Node) bool {
+		switch n := node.(type) {
+		case *syntax.CallExpr:
+			addAssigns(n.Assigns)
+			if len(n.Args) > 0 {
+				name, glob, unknown, ok := wordLiteral(n.Args[0], vars)
+				if ok && glob < 0 && unknown == "" && name != "" {
+					paths.Programs = append(paths.Programs, name)
+				}
+			}
+			for _, w := range n.Args {
+				add(w)
+			}
+		case *syntax.DeclClause:
+			addAssigns(n.Args)
+		case *syntax.Redirect:
+			switch n.Op {
+			case syntax.Hdoc, syntax.DashHdoc, syntax.WordHdoc, syntax.DplIn, syntax.DplOut:
+			default:
+				add(n.Word)
+			}
+		}
+		return true
+	})
+	return paths, nil
+}
+
+// wordPath returns the path a word refers to, or "" when the word
+// is not a literal path. unknown is the first variable that could
+// not be expanded in a word containing a /.
+func wordPath(w *syntax.Word, vars pathVars) (path, unknown string) {
+	value, glob, unknown, ok := wordLiteral(w, vars)
+	if !ok {
+		return "", ""
+	}
+	if unknown != "" {
+		if !strings.Contains(value, "/") {
+			unknown = ""
+		}
+		return "", unknown
+	}
+	if strings.HasPrefix(value, "-") {
+		i := strings.IndexByte(value, '=')
+		if i < 0 || glob >= 0 && glob < i {
+			return "", ""
+		}
+		value = value[i+1:]
+		if glob >= 0 {
+			glob -= i + 1
+		}
+	}
+	if glob >= 0 {
+		// Keep the directory containing the first glob character.
+		i := strings.LastIndexByte(value[:glob], '/')
+		if i < 0 {
+			return "", ""
+		}
+		value = value[:i+1]
+	}
+	for _, prefix := range []string{"/", "./", "../", "~/"} {
+		if strings.HasPrefix(value, prefix) {
+			return value, ""
+		}
+	}
+	return "", ""
+}
+
+// wordLiteral returns the value of w after quote removal and
+// expansion of $NAME and ${NAME} from vars. glob is the offset of
+// the first unquoted glob or brace character, or -1. unknown is the
+// first variable not found in vars; it expands to nothing. ok is
+// false when w contains other expansions or substitutions, or when
+// a leading ~ is quoted and therefore not expanded by the shell.
+func wordLiteral(w *syntax.Word, vars pathVars) (value string, glob int, unknown string, ok bool) {
+	var b strings.Builder
+	glob = -1
+	param := func(p *syntax.ParamExp) bool {
+		if p.Excl || p.Length || p.Width || p.Index != nil || p.Slice != nil ||
+			p.Repl != nil || p.Names != 0 || p.Exp != nil {
+			return false
+		}
+		if v, found := vars[p.Param.Value]; found {
+			b.WriteString(v)
+		} else if unknown == "" {
+			unknown = p.Param.Value
+		}
+		return true
+	}
+	for i, part := range w.Parts {
+		switch p := part.(type) {
+		case *syntax.Lit:
+			for j := 0; j < len(p.Value); j++ {
+				c := p.Value[j]
+				switch {
+				case c == '\\' && j+1 < len(p.Value):
+					j++
+					if p.Value[j] != '\n' {
+						b.WriteByte(p.Value[j])
+					}
+					continue
+				case strings.IndexByte("*?[{", c) >= 0 && glob < 0:
+					glob = b.Len()
+				}
+				b.WriteByte(c)
+			}
+		case *syntax.SglQuoted:
+			if p.Dollar || i == 0 && strings.HasPrefix(p.Value, "~") {
+				return "", 0, "", false
+			}
+			b.WriteString(p.Value)
+		case *syntax.DblQuoted:
+			if p.Dollar {
+				return "", 0, "", false
+			}
+			for k, dp := range p.Parts {
+				switch dp := dp.(type) {
+				case *syntax.Lit:
+					if i == 0 && k == 0 && strings.HasPrefix(dp.Value, "~") {
+						return "", 0, "", false
+					}
+					b.WriteString(unescapeDoubleQuoted(dp.Value))
+				case *syntax.ParamExp:
+					if !param(dp) {
+						return "", 0, "", false
+					}
+				default:
+					return "", 0, "", false
+				}
+			}
+		case *syntax.ParamExp:
+			if !param(p) {
+				return "", 0, "", false
+			}
+		default:
+			return "", 0, "", false
+		}
+	}
+	return b.String(), glob, unknown, true
+}
+
+// unescapeDoubleQuoted removes the backslashes that are special
+// inside double quotes.
+func unescapeDoubleQuoted(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) && strings.IndexByte("$`\"\\\n", s[i+1]) >= 0 {
+			i++
+			if s[i] == '\n' {
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
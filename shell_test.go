@@ -0,0 +1,242 @@
+package cctidy
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestExtractCommandPaths(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name        string
+		input       string
+		vars        pathVars
+		wantAbs     []string
+		wantRel     []string
+		wantUnknown []string
+	}{
+		{
+			name:    "single absolute path",
+			input:   "git -C /home/user/repo status",
+			wantAbs: []string{"/home/user/repo"},
+		},
+		{
+			name:  "glob word without directory",
+			input: "npm run *",
+		},
+		{
+			name:    "glob word keeps parent directory",
+			input:   "ls /src/legacy/*.ts",
+			wantAbs: []string{"/src/legacy"},
+		},
+		{
+			name:    "and list",
+			input:   "cd /path && make",
+			wantAbs: []string{"/path"},
+		},
+		{
+			name:    "semicolon, or list and pipeline",
+			input:   "cd /a;make || cat /b | grep x > /c",
+			wantAbs: []string{"/a", "/b", "/c"},
+		},
+		{
+			name:    "flag with equals",
+			input:   "app --config=/etc/app.conf",
+			wantAbs: []string{"/etc/app.conf"},
+		},
+		{
+			name:    "multiple paths",
+			input:   "cp /src/a /dst/b",
+			wantAbs: []string{"/src/a", "/dst/b"},
+		},
+		{
+			name:  "no paths",
+			input: "echo hello",
+		},
+		{
+			name:    "trailing slash trimmed",
+			input:   "ls /some/dir/",
+			wantAbs: []string{"/some/dir"},
+		},
+		{
+			name:    "trailing dot preserved",
+			input:   "ls /some/path.",
+			wantAbs: []string{"/some/path."},
+		},
+		{
+			name:  "root only path filtered",
+			input: "ls /",
+		},
+		{
+			name:    "command name path",
+			input:   "/opt/tools/bin/lint --fix",
+			wantAbs: []string{"/opt/tools/bin/lint"},
+		},
+		{
+			name:    "single-quoted path with spaces",
+			input:   "cat '/path with spaces/file'",
+			wantAbs: []string{"/path with spaces/file"},
+		},
+		{
+			name:    "double-quoted path with spaces",
+			input:   `cat "/path with spaces/file"`,
+			wantAbs: []string{"/path with spaces/file"},
+		},
+		{
+			name:    "escaped space",
+			input:   `cat /path\ with\ spaces/file`,
+			wantAbs: []string{"/path with spaces/file"},
+		},
+		{
+			name:    "assignment value",
+			input:   "GOPATH=/opt/go go build",
+			wantAbs: []string{"/opt/go"},
+		},
+		{
+			name:    "export assignment",
+			input:   "export PATH_DIR=/opt/bin",
+			wantAbs: []string{"/opt/bin"},
+		},
+		{
+			name:    "command substitution",
+			input:   "echo $(cat /etc/version)",
+			wantAbs: []string{"/etc/version"},
+		},
+		{
+			name:    "backquote substitution",
+			input:   "echo `ls /var/log`",
+			wantAbs: []string{"/var/log"},
+		},
+		{
+			name:  "URL is not a path",
+			input: "curl https://example.com/path/to/file",
+		},
+		{
+			name:        "parameter expansion without vars is unknown",
+			input:       "cat $HOME/file ${DIR}/x",
+			wantUnknown: []string{"$HOME", "$DIR"},
+		},
+		{
+			name:    "prefix match marker removed",
+			input:   "git -C /repo status:*",
+			wantAbs: []string{"/repo"},
+		},
+		{
+			name:    "relative paths",
+			input:   "cat ./src/main.go ../other/file ~/config.json",
+			wantRel: []string{"./src/main.go", "../other/file", "~/config.json"},
+		},
+		{
+			name:  "bare relative path not extracted",
+			input: "cat bare/path",
+		},
+		{
+			name:    "relative trailing slash trimmed",
+			input:   "ls ./dir/",
+			wantRel: []string{"./dir"},
+		},
+		{
+			name:    "flag with relative path",
+			input:   "app --config=./app.conf --data=~/data",
+			wantRel: []string{"./app.conf", "~/data"},
+		},
+		{
+			name:  "quoted tilde is not expanded",
+			input: `cat "~/config" '~/other'`,
+		},
+		{
+			name:  "heredoc delimiter is not a path",
+			input: "cat <<EOF\n/not/a/path\nEOF",
+		},
+		{
+			name:    "redirect target",
+			input:   "make 2>/tmp/build.log",
+			wantAbs: []string{"/tmp/build.log"},
+		},
+		{
+			name:    "known variable is expanded",
+			input:   `cat $HOME/.zshrc "${XDG_CONFIG_HOME}/git/config"`,
+			vars:    pathVars{"HOME": "/home/user", "XDG_CONFIG_HOME": "/home/user/.config"},
+			wantAbs: []string{"/home/user/.zshrc", "/home/user/.config/git/config"},
+		},
+		{
+			name:    "variable in the middle of a path",
+			input:   "ls /srv/$USER/logs",
+			vars:    pathVars{"USER": "alice"},
+			wantAbs: []string{"/srv/alice/logs"},
+		},
+		{
+			name:        "unknown variable in a path is reported",
+			input:       "cat $SECRET_DIR/key /etc/hosts",
+			wantAbs:     []string{"/etc/hosts"},
+			wantUnknown: []string{"$SECRET_DIR"},
+		},
+		{
+			name:  "unknown variable without slash is ignored",
+			input: `for f in *.go; do gofmt "$f"; done`,
+		},
+		{
+			name:  "other parameter expansions are skipped",
+			input: "cat ${HOME:-/root}/x ${#HOME}/y",
+			vars:  pathVars{"HOME": "/home/user"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := extractCommandPaths(tt.input, tt.vars)
+			if err != nil {
+				t.Fatalf("extractCommandPaths(%q) error: %v", tt.input, err)
+			}
+			if !slices.Equal(got.Abs, tt.wantAbs) {
+				t.Errorf("extractCommandPaths(%q).Abs = %v, want %v", tt.input, got.Abs, tt.wantAbs)
+			}
+			if !slices.Equal(got.Rel, tt.wantRel) {
+				t.Errorf("extractCommandPaths(%q).Rel = %v, want %v", tt.input, got.Rel, tt.wantRel)
+			}
+			if !slices.Equal(got.Unknown, tt.wantUnknown) {
+				t.Errorf("extractCommandPaths(%q).Unknown = %v, want %v", tt.input, got.Unknown, tt.wantUnknown)
+			}
+		})
+	}
+}
+
+func TestExtractCommandPrograms(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{name: "single command", input: "git status", want: []string{"git"}},
+		{name: "prefix match marker", input: "mytool build:*", want: []string{"mytool"}},
+		{name: "assignment before command", input: "GOOS=linux go build", want: []string{"go"}},
+		{name: "compound command", input: "cd /x && ./bin/run | tee out", want: []string{"cd", "./bin/run", "tee"}},
+		{name: "command substitution", input: "echo $(/opt/bin/ver)", want: []string{"echo", "/opt/bin/ver"}},
+		{name: "quoted program", input: `"/opt/my tools/run" -v`, want: []string{"/opt/my tools/run"}},
+		{name: "variable program skipped", input: "$EDITOR file"},
+		{name: "glob program skipped", input: "*"},
+		{name: "assignment only", input: "FOO=bar"},
+		{name: "escaped keyword", input: `\time make`, want: []string{"time"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := extractCommandPaths(tt.input, nil)
+			if err != nil {
+				t.Fatalf("extractCommandPaths(%q) error: %v", tt.input, err)
+			}
+			if !slices.Equal(got.Programs, tt.want) {
+				t.Errorf("extractCommandPaths(%q).Programs = %v, want %v", tt.input, got.Programs, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractCommandPathsParseError(t *testing.T) {
+	t.Parallel()
+	if _, err := extractCommandPaths(`cat "/unterminated`, nil); err == nil {
+		t.Error("expected parse error for unterminated quote")
+	}
+}
@@ -22,21 +22,30 @@ import (
 // If a file has a frontmatter name field, that name is used;
 // otherwise the filename without extension is used.
 //
+// Both directories are walked recursively. A skill or command
+// nested in subdirectories is namespaced by the directory names
+// joined with ":", so commands/frontend/build.md is
+// "frontend:build". Claude Code invokes nested commands by their
+// bare name ("/build", the subdirectory only appears in the
+// description), so nested commands are also added unprefixed.
+//
 // Returns an empty set if claudeDir is empty or unreadable.
 func LoadSkillNames(claudeDir string) set.Value[string] {
 	s := set.New[string]()
 	if claudeDir == "" {
 		return s
 	}
-	loadSkillsDir(filepath.Join(claudeDir, "skills"), s)
-	loadCommandsDir(filepath.Join(claudeDir, "commands"), s)
+	loadSkillsDir(filepath.Join(claudeDir, "skills"), "", s)
+	loadCommandsDir(filepath.Join(claudeDir, "commands"), "", s)
 	return s
 }
 
 // loadSkillsDir scans dir for subdirectories containing SKILL.md.
 // If SKILL.md has a frontmatter name field, that name is used;
-// otherwise the directory name is used.
-func loadSkillsDir(dir string, s set.Value[string]) {
+// otherwise the directory name is used. Subdirectories without
+// SKILL.md are scanned as namespaces; a skill directory is not
+// descended into, since it holds the skill's own resources.
+func loadSkillsDir(dir, namespace string, s set.Value[string]) {
 	entries, err := os.ReadDir(dir)
 	if err != nil {
 		return
@@ -45,20 +54,28 @@ func loadSkillsDir(dir string, s set.Value[string]) {
 		if !e.IsDir() {
 			continue
 		}
-		addSkillName(s, filepath.Join(dir, e.Name(), "SKILL.md"), e.Name())
+		sub := filepath.Join(dir, e.Name())
+		skillFile := filepath.Join(sub, "SKILL.md")
+		if _, err := os.Stat(skillFile); err == nil {
+			addSkillName(s, skillFile, namespace, e.Name())
+			continue
+		}
+		loadSkillsDir(sub, namespaced(namespace, e.Name()), s)
 	}
 }
 
-// loadCommandsDir scans dir for .md files.
+// loadCommandsDir scans dir and its subdirectories for .md files.
 // If a file has a frontmatter name field, that name is used;
-// otherwise the filename without extension is used.
-func loadCommandsDir(dir string, s set.Value[string]) {
+// otherwise the filename without extension is used. Nested
+// commands are added both namespaced and bare.
+func loadCommandsDir(dir, namespace string, s set.Value[string]) {
 	entries, err := os.ReadDir(dir)
 	if err != nil {
 		return
 	}
 	for _, e := range entries {
 		if e.IsDir() {
+			loadCommandsDir(filepath.Join(dir, e.Name()), namespaced(namespace, e.Name()), s)
 			continue
 		}
 		if filepath.Ext(e.Name()) != ".md" {
@@ -68,51 +85,69 @@ func loadCommandsDir(dir string, s set.Value[string]) {
 		if name == "" {
 			continue
 		}
-		addSkillName(s, filepath.Join(dir, e.Name()), name)
+		path := filepath.Join(dir, e.Name())
+		addSkillName(s, path, namespace, name)
+		if namespace != "" {
+			addSkillName(s, path, "", name)
+		}
 	}
 }
 
-// addSkillName reads a markdown file and adds the frontmatter name
-// to the set. Falls back to fallback when the file is
-// unreadable or has no name field.
-func addSkillName(s set.Value[string], path, fallback string) {
+// namespaced prefixes name with namespace, separated by ":".
+func namespaced(namespace, name string) string {
+	if namespace == "" {
+		return name
+	}
+	return namespace + ":" + name
+}
+
+// addSkillName reads a markdown file and adds the frontmatter name,
+// prefixed with namespace, to the set. Falls back to fallback when
+// the file has no name field; unreadable files are skipped.
+func addSkillName(s set.Value[string], path, namespace, fallback string) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return
 	}
 	if name := md.ParseName(data); name != "" {
-		s.Add(name)
+		s.Add(namespaced(namespace, name))
 	} else {
-		s.Add(fallback)
+		s.Add(namespaced(namespace, fallback))
 	}
 }
 
 // SkillToolSweeper sweeps Skill permission entries where the
-// referenced skill or command no longer exists. Plugin skills
-// (containing ":") are always kept.
+// referenced skill or command no longer exists in any scope. The
+// result reports the scope that provided a found skill. Namespaced
+// skills (containing ":") that are not found are checked against
+// local namespaces and then against plugins.
 type SkillToolSweeper struct {
-	skills set.Value[string]
+	skills  ScopedNames
+	plugins *PluginIndex
 }
 
-// NewSkillToolSweeper creates a SkillToolSweeper.
-func NewSkillToolSweeper(skills set.Value[string]) *SkillToolSweeper {
-	return &SkillToolSweeper{skills: skills}
+// NewSkillToolSweeper creates a SkillToolSweeper. A nil plugins
+// keeps every plugin skill.
+func NewSkillToolSweeper(skills ScopedNames, plugins *PluginIndex) *SkillToolSweeper {
+	return &SkillToolSweeper{skills: skills, plugins: plugins}
 }
 
 func (s *SkillToolSweeper) ShouldSweep(_ context.Context, entry StandardEntry) ToolSweepResult {
-	if s.skills.Len() == 0 {
-		return ToolSweepResult{}
+	// Extract name from specifier (e.g. "name *" -> "name").
+	name, _, _ := strings.Cut(entry.Specifier, " ")
+	if scope, ok := s.skills.Lookup(name); ok {
+		return ToolSweepResult{Reason: ReasonSkillExists, Scope: scope}
 	}
-	specifier := entry.Specifier
-	// Plugin skills use "plugin:name" convention
-	// and are managed by the plugin system.
-	if strings.Contains(specifier, ":") {
-		return ToolSweepResult{}
+	// Nested commands use "namespace:name"; plugin skills use
+	// "plugin:name".
+	if ns, _, ok := strings.Cut(name, ":"); ok {
+		if s.skills.hasLocalNamespace(ns) {
+			return ToolSweepResult{Sweep: true, Reason: ReasonSkillMissing}
+		}
+		return s.plugins.componentResult(name, ReasonSkillMissing)
 	}
-	// Extract name from specifier (e.g. "name *" -> "name").
-	name, _, _ := strings.Cut(specifier, " ")
-	if s.skills.Has(name) {
-		return ToolSweepResult{}
+	if !s.skills.hasLocal() {
+		return ToolSweepResult{Reason: ReasonNoContext}
 	}
-	return ToolSweepResult{Sweep: true}
+	return ToolSweepResult{Sweep: true, Reason: ReasonSkillMissing}
 }
@@ -4,8 +4,6 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
-
-	"github.com/708u/cctidy/internal/set"
 )
 
 func TestLoadSkillNames(t *testing.T) {
@@ -146,6 +144,44 @@ func TestLoadSkillNames(t *testing.T) {
 		}
 	})
 
+	t.Run("nested command is namespaced and bare", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		claudeDir := filepath.Join(dir, ".claude")
+		nested := filepath.Join(claudeDir, "commands", "frontend", "ui")
+		os.MkdirAll(nested, 0o755)
+		os.WriteFile(filepath.Join(claudeDir, "commands", "frontend", "build.md"), []byte("# Build"), 0o644)
+		os.WriteFile(filepath.Join(nested, "render.md"), []byte("---\nname: draw\n---\n"), 0o644)
+
+		s := LoadSkillNames(claudeDir)
+		for _, want := range []string{"frontend:build", "frontend:ui:draw", "build", "draw"} {
+			if !s.Has(want) {
+				t.Errorf("expected %q in set, got %v", want, s)
+			}
+		}
+		if s.Has("ui:draw") {
+			t.Error("nested command should not be registered under a partial namespace")
+		}
+	})
+
+	t.Run("nested skill is namespaced", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		claudeDir := filepath.Join(dir, ".claude")
+		skillDir := filepath.Join(claudeDir, "skills", "ops", "deploy")
+		os.MkdirAll(filepath.Join(skillDir, "scripts", "inner"), 0o755)
+		os.WriteFile(filepath.Join(skillDir, "SKILL.md"), []byte("# Deploy"), 0o644)
+		os.WriteFile(filepath.Join(skillDir, "scripts", "inner", "SKILL.md"), []byte("# Resource"), 0o644)
+
+		s := LoadSkillNames(claudeDir)
+		if !s.Has("ops:deploy") {
+			t.Errorf("expected ops:deploy in set, got %v", s)
+		}
+		if s.Len() != 1 {
+			t.Errorf("skill directory should not be descended into, got %v", s)
+		}
+	})
+
 	t.Run("skill frontmatter name overrides dir name", func(t *testing.T) {
 		t.Parallel()
 		dir := t.TempDir()
@@ -242,61 +278,97 @@ func TestLoadSkillNames(t *testing.T) {
 func TestSkillToolSweeperShouldSweep(t *testing.T) {
 	t.Parallel()
 
-	skillsWithReview := set.New("review")
-	skillsWithDeploy := set.New("deploy")
-	emptySkills := set.New[string]()
+	skillsWithReview := ScopedNames{"review": ScopeProject}
+	skillsWithDeploy := ScopedNames{"deploy": ScopeUser}
+	emptySkills := ScopedNames{}
+	pluginSkills := ScopedNames{"review": ScopeProject, "tools:lint": ScopePlugin}
 
 	tests := []struct {
 		name      string
 		sweeper   *SkillToolSweeper
 		specifier string
 		wantSweep bool
+		wantScope Scope
 	}{
 		{
 			name:      "plugin skill with colon is kept",
-			sweeper:   NewSkillToolSweeper(emptySkills),
+			sweeper:   NewSkillToolSweeper(emptySkills, nil),
 			specifier: "plugin:skill-name",
 			wantSweep: false,
 		},
 		{
 			name:      "skill in set is kept",
-			sweeper:   NewSkillToolSweeper(skillsWithReview),
+			sweeper:   NewSkillToolSweeper(skillsWithReview, nil),
 			specifier: "review",
 			wantSweep: false,
+			wantScope: ScopeProject,
 		},
 		{
 			name:      "command in set is kept",
-			sweeper:   NewSkillToolSweeper(skillsWithDeploy),
+			sweeper:   NewSkillToolSweeper(skillsWithDeploy, nil),
 			specifier: "deploy",
 			wantSweep: false,
+			wantScope: ScopeUser,
 		},
 		{
 			name:      "skill not in set is swept",
-			sweeper:   NewSkillToolSweeper(skillsWithReview),
+			sweeper:   NewSkillToolSweeper(skillsWithReview, nil),
 			specifier: "dead-skill",
 			wantSweep: true,
 		},
 		{
 			name:      "prefix match extracts name",
-			sweeper:   NewSkillToolSweeper(skillsWithReview),
+			sweeper:   NewSkillToolSweeper(skillsWithReview, nil),
 			specifier: "review *",
 			wantSweep: false,
+			wantScope: ScopeProject,
 		},
 		{
 			name:      "prefix match with dead name is swept",
-			sweeper:   NewSkillToolSweeper(skillsWithReview),
+			sweeper:   NewSkillToolSweeper(skillsWithReview, nil),
 			specifier: "dead-skill *",
 			wantSweep: true,
 		},
 		{
 			name:      "empty set keeps conservatively",
-			sweeper:   NewSkillToolSweeper(emptySkills),
+			sweeper:   NewSkillToolSweeper(emptySkills, nil),
 			specifier: "unknown",
 			wantSweep: false,
 		},
 		{
 			name:      "nil set keeps conservatively",
-			sweeper:   NewSkillToolSweeper(nil),
+			sweeper:   NewSkillToolSweeper(nil, nil),
+			specifier: "unknown",
+			wantSweep: false,
+		},
+		{
+			name:      "installed plugin skill reports plugin scope",
+			sweeper:   NewSkillToolSweeper(pluginSkills, nil),
+			specifier: "tools:lint",
+			wantSweep: false,
+			wantScope: ScopePlugin,
+		},
+		{
+			name:      "unresolved plugin skill is kept without registry",
+			sweeper:   NewSkillToolSweeper(pluginSkills, nil),
+			specifier: "tools:gone",
+			wantSweep: false,
+		},
+		{
+			name:      "missing command in local namespace is swept",
+			sweeper:   NewSkillToolSweeper(ScopedNames{"frontend:build": ScopeProject}, nil),
+			specifier: "frontend:gone",
+			wantSweep: true,
+		},
+		{
+			name:      "uninstalled plugin skill is swept",
+			sweeper:   NewSkillToolSweeper(pluginSkills, NewPluginIndex([]InstalledPlugin{{Name: "other"}})),
+			specifier: "tools:gone",
+			wantSweep: true,
+		},
+		{
+			name:      "plugin skills alone keep unknown conservatively",
+			sweeper:   NewSkillToolSweeper(ScopedNames{"tools:lint": ScopePlugin}, nil),
 			specifier: "unknown",
 			wantSweep: false,
 		},
@@ -310,6 +382,9 @@ func TestSkillToolSweeperShouldSweep(t *testing.T) {
 			if result.Sweep != tt.wantSweep {
 				t.Errorf("ShouldSweep(%q) = %v, want %v", tt.specifier, result.Sweep, tt.wantSweep)
 			}
+			if result.Scope != tt.wantScope {
+				t.Errorf("ShouldSweep(%q).Scope = %q, want %q", tt.specifier, result.Scope, tt.wantScope)
+			}
 		})
 	}
 }
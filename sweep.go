@@ -2,25 +2,17 @@ package cctidy
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"path/filepath"
 	"regexp"
 	"strings"
+	"time"
 
+	"github.com/708u/cctidy/internal/pool"
 	"github.com/708u/cctidy/internal/set"
 )
 
-// absPathRe matches absolute paths starting with / in a command string.
-// Used by extractAbsolutePaths to find paths like /home/user/repo.
-// The leading alternative handles word boundaries without lookbehind.
-var absPathRe = regexp.MustCompile(`(?:^|[^A-Za-z0-9_.~])/[A-Za-z0-9_./-]+`)
-
-// relPathRe matches relative paths prefixed with ./, ../, or ~/
-// in a command string. Bare relative paths (e.g. src/file) are
-// intentionally excluded to avoid false positives.
-// Capture group 1 contains the path including its prefix.
-var relPathRe = regexp.MustCompile(`(?:^|\s|=)(\.\./[A-Za-z0-9_./-]+|\./[A-Za-z0-9_./-]+|~/[A-Za-z0-9_./-]+)`)
-
 // toolEntryRe matches a permission entry like "Read(/path/to/file)"
 // and captures the tool name and specifier.
 var toolEntryRe = regexp.MustCompile(`^([A-Za-z][A-Za-z0-9_]*)\((.*)\)$`)
@@ -63,14 +55,90 @@ func extractToolEntry(entry string) ToolEntry {
 	return StandardEntry{Tool: ToolName(m[1]), Specifier: m[2]}
 }
 
+// SweepDecision is the outcome of evaluating a single entry.
+type SweepDecision string
+
+const (
+	DecisionSwept  SweepDecision = "swept"
+	DecisionKept   SweepDecision = "kept"
+	DecisionWarned SweepDecision = "warned"
+)
+
+// SweepReason is a machine-readable code explaining a SweepDecision.
+type SweepReason string
+
+const (
+	ReasonPathMissing      SweepReason = "path-missing"
+	ReasonPathExists       SweepReason = "path-exists"
+	ReasonPathUnknown      SweepReason = "path-unknown"
+	ReasonVolatileRoot     SweepReason = "volatile-root"
+	ReasonMountUnavailable SweepReason = "mount-unavailable"
+	ReasonGracePending     SweepReason = "grace-pending"
+	ReasonCheckTimeout     SweepReason = "check-timeout"
+	ReasonAgentMissing     SweepReason = "agent-missing"
+	ReasonAgentExists      SweepReason = "agent-exists"
+	ReasonBuiltinAgent     SweepReason = "builtin-agent"
+	ReasonSkillMissing     SweepReason = "skill-missing"
+	ReasonSkillExists      SweepReason = "skill-exists"
+	ReasonMCPServerUnknown SweepReason = "mcp-server-unknown"
+	ReasonMCPServerKnown   SweepReason = "mcp-server-known"
+	ReasonGlobSkipped      SweepReason = "glob-skipped"
+	ReasonPluginKept       SweepReason = "plugin-kept"
+	ReasonPluginMissing    SweepReason = "plugin-missing"
+	ReasonPluginInstalled  SweepReason = "plugin-installed"
+
+	ReasonMarketplaceMissing  SweepReason = "marketplace-missing"
+	ReasonMarketplaceKnown    SweepReason = "marketplace-known"
+	ReasonMarketplaceDeclared SweepReason = "marketplace-declared"
+	ReasonMarketplaceInUse    SweepReason = "marketplace-in-use"
+
+	ReasonExcludedByConfig SweepReason = "excluded-by-config"
+	ReasonRemoveCommands   SweepReason = "remove_commands"
+	ReasonAllowOnly        SweepReason = "allow-only"
+	ReasonNoPaths          SweepReason = "no-paths"
+	ReasonUnparsable       SweepReason = "unparsable-command"
+	ReasonUnknownVar       SweepReason = "unknown-variable"
+	ReasonProgramMissing   SweepReason = "program-missing"
+	ReasonGlobUnmatched    SweepReason = "glob-unmatched"
+	ReasonWalkBudget       SweepReason = "walk-budget-exceeded"
+	ReasonContextCanceled  SweepReason = "context-canceled"
+	ReasonUnresolvable     SweepReason = "unresolvable"
+	ReasonNoContext        SweepReason = "no-context"
+	ReasonInactive         SweepReason = "sweeper-inactive"
+	ReasonNotSwept         SweepReason = "tool-not-swept"
+	ReasonUnrecognized     SweepReason = "unrecognized-entry"
+)
+
 // ToolSweepResult holds the result of a single tool sweeper evaluation.
 // When Warn is non-empty the entry is kept and the warning is recorded.
 // AllowOnly indicates this sweep applies only to the allow category;
 // entries in other categories (e.g. ask) are kept.
+// Reason explains the decision whether or not the entry is swept.
+// Scope names the scope that satisfied a kept agent or skill entry.
 type ToolSweepResult struct {
 	Sweep     bool
 	AllowOnly bool
 	Warn      string
+	Reason    SweepReason
+	Scope     Scope
+}
+
+// SweepRecord describes the evaluation of a single entry.
+// Category is the containing collection (e.g. "allow", "projects").
+// Key identifies the enclosing object for nested collections, such
+// as the repository name for "githubRepoPaths"; it is empty otherwise.
+// Detail carries the warning message for warned entries. Scope
+// names the scope (project, user or plugin) that provided a kept
+// agent or skill.
+type SweepRecord struct {
+	Category string        `json:"category"`
+	Key      string        `json:"key,omitempty"`
+	Entry    string        `json:"entry"`
+	Tool     ToolName      `json:"tool,omitempty"`
+	Decision SweepDecision `json:"decision"`
+	Reason   SweepReason   `json:"reason"`
+	Scope    Scope         `json:"scope,omitempty"`
+	Detail   string        `json:"detail,omitempty"`
 }
 
 // ToolSweeper decides whether a permission entry should be swept.
@@ -125,16 +193,42 @@ var builtinAgents = set.New(
 // that reference non-existent paths.
 //
 // Specifier resolution rules:
-//   - glob (*, ?, [)  → skip (kept unchanged)
+//   - glob (*, ?, [)  → skip unless globs is enabled
 //   - //path          → /path  (absolute; always resolvable)
 //   - ~/path          → homeDir/path (requires homeDir)
-//   - /path           → project root relative (requires projectDir)
+//   - /path           → relative to the settings file (requires
+//     baseDir, or projectDir at project level)
 //   - ./path, ../path, bare path → cwd relative (requires projectDir)
+//
+// baseDir is the directory Claude Code resolves /path rules
+// against: the parent of the .claude directory holding the settings
+// file, or the file's own directory otherwise. Other relative rules
+// follow the working directory, which is only known at project
+// level; a user-level Edit(package.json) applies in every project.
+//
+// $NAME and ${NAME} references are expanded from vars first. A
+// specifier starting with a variable must expand to an absolute
+// path. Specifiers referencing unknown variables are kept with a
+// warning.
+//
+// When globs is set, glob specifiers are resolved by the same rules
+// and swept when the directory before the first glob character is
+// missing, or optionally when no file matches; see sweepGlob.
 type ReadEditToolSweeper struct {
 	checker    PathChecker
 	homeDir    string
 	projectDir string
+	baseDir    string
 	level      SettingsLevel
+	vars       pathVars
+	globs      *GlobPermissionConfig
+	// walkTimeout and mounts bound glob walks, which read
+	// directories directly rather than through checker.
+	walkTimeout time.Duration
+	mounts      *MountAwareChecker
+	// dirsOnly requires a non-glob path to be a directory. It is
+	// set on the copy that evaluates additionalDirectories.
+	dirsOnly bool
 }
 
 // containsGlob reports whether s contains glob metacharacters.
@@ -142,66 +236,131 @@ func containsGlob(s string) bool {
 	return strings.ContainsAny(s, "*?[")
 }
 
+// globsEnabled reports whether glob specifiers are evaluated.
+func (r *ReadEditToolSweeper) globsEnabled() bool {
+	return r.globs != nil && r.globs.Enabled
+}
+
 func (r *ReadEditToolSweeper) ShouldSweep(ctx context.Context, entry StandardEntry) ToolSweepResult {
 	specifier := entry.Specifier
-	if containsGlob(specifier) {
-		return ToolSweepResult{}
+	glob := containsGlob(specifier)
+	if glob && !r.globsEnabled() {
+		return ToolSweepResult{Reason: ReasonGlobSkipped}
+	}
+
+	expanded, err := r.vars.expand(specifier)
+	if err != nil {
+		return ToolSweepResult{Warn: err.Error(), Reason: ReasonUnknownVar}
 	}
 
 	var resolved string
 	switch {
-	case strings.HasPrefix(specifier, "//"):
-		resolved = specifier[1:]
-	case strings.HasPrefix(specifier, "~/"):
+	case strings.HasPrefix(specifier, "$"):
+		if !filepath.IsAbs(expanded) {
+			return ToolSweepResult{Reason: ReasonUnresolvable}
+		}
+		resolved = filepath.Clean(expanded)
+	case strings.HasPrefix(expanded, "//"):
+		resolved = expanded[1:]
+	case strings.HasPrefix(expanded, "~/"):
 		if r.homeDir == "" {
-			return ToolSweepResult{}
+			return ToolSweepResult{Reason: ReasonUnresolvable}
 		}
-		rest, _ := strings.CutPrefix(specifier, "~/")
+		rest, _ := strings.CutPrefix(expanded, "~/")
 		resolved = filepath.Join(r.homeDir, rest)
-	default: // /path, ./path, ../path, bare path — all project-relative
+	case strings.HasPrefix(expanded, "/"): // settings-relative
+		base := r.baseDir
+		if base == "" && r.level == ProjectLevel {
+			base = r.projectDir
+		}
+		if base == "" {
+			return ToolSweepResult{Reason: ReasonUnresolvable}
+		}
+		resolved = filepath.Join(base, expanded)
+	default: // ./path, ../path, bare path — all cwd relative
 		if r.level != ProjectLevel {
-			return ToolSweepResult{}
+			return ToolSweepResult{Reason: ReasonUnresolvable}
 		}
-		resolved = filepath.Join(r.projectDir, specifier)
+		resolved = filepath.Join(r.projectDir, expanded)
 	}
+	return r.sweepResolved(ctx, specifier, resolved, glob)
+}
 
-	if !r.checker.Exists(ctx, resolved) {
-		return ToolSweepResult{Sweep: true}
+// sweepAbsolute evaluates path, an absolute path used as is rather
+// than relative to the settings file. Variables are expanded and
+// globs handled as in ShouldSweep.
+func (r *ReadEditToolSweeper) sweepAbsolute(ctx context.Context, path string) ToolSweepResult {
+	glob := containsGlob(path)
+	if glob && !r.globsEnabled() {
+		return ToolSweepResult{Reason: ReasonGlobSkipped}
+	}
+	expanded, err := r.vars.expand(path)
+	if err != nil {
+		return ToolSweepResult{Warn: err.Error(), Reason: ReasonUnknownVar}
 	}
-	return ToolSweepResult{}
+	return r.sweepResolved(ctx, path, filepath.Clean(expanded), glob)
 }
 
-// extractAbsolutePaths extracts all absolute paths from a string.
-// Paths stop at glob metacharacters, shell metacharacters, whitespace,
-// parentheses, dollar signs, and braces.
-func extractAbsolutePaths(s string) []string {
-	matches := absPathRe.FindAllString(s, -1)
-	var paths []string
-	for _, m := range matches {
-		// The regex may include a leading non-path char from the
-		// lookbehind alternative; trim to the first '/'.
-		idx := strings.IndexByte(m, '/')
-		cleaned := filepath.Clean(m[idx:])
-		if cleaned == "/" {
-			continue
-		}
-		paths = append(paths, cleaned)
+// sweepResolved checks resolved, the path specifier resolves to.
+func (r *ReadEditToolSweeper) sweepResolved(ctx context.Context, specifier, resolved string, glob bool) ToolSweepResult {
+	if glob {
+		// As in gitignore, a pattern containing a / other than a
+		// trailing one is anchored to its base directory.
+		anchored := strings.Contains(strings.TrimSuffix(specifier, "/"), "/")
+		return r.sweepGlob(ctx, resolved, anchored)
 	}
-	return paths
+
+	// A trailing separator asks the checker for a directory, so
+	// that a path replaced by a regular file counts as missing
+	// while timeouts, mounts and grace periods still apply.
+	if r.dirsOnly && !strings.HasSuffix(resolved, string(filepath.Separator)) {
+		resolved += string(filepath.Separator)
+	}
+	exists, err := r.checker.Exists(ctx, resolved)
+	switch {
+	case err != nil:
+		return ToolSweepResult{Warn: err.Error(), Reason: unknownReason(err)}
+	case !exists:
+		return ToolSweepResult{Sweep: true, Reason: ReasonPathMissing}
+	}
+	return ToolSweepResult{Reason: ReasonPathExists}
 }
 
-// extractRelativePaths extracts all relative paths (../, ./, ~/) from a string.
-func extractRelativePaths(s string) []string {
-	matches := relPathRe.FindAllStringSubmatch(s, -1)
-	var paths []string
-	for _, m := range matches {
-		cleaned := strings.TrimRight(m[1], "/")
-		if cleaned == "" {
-			continue
+// sweepGlob evaluates a resolved glob pattern. The entry is swept
+// when the directory before the first glob character is missing.
+// With SweepUnmatched, an existing base directory is walked, within
+// the walk budget, and the entry is swept when nothing matches.
+func (r *ReadEditToolSweeper) sweepGlob(ctx context.Context, pattern string, anchored bool) ToolSweepResult {
+	base, segments := splitGlob(pattern, anchored)
+	exists, err := r.checker.Exists(ctx, base)
+	switch {
+	case err != nil:
+		return ToolSweepResult{Warn: err.Error(), Reason: unknownReason(err)}
+	case !exists:
+		return ToolSweepResult{Sweep: true, Reason: ReasonPathMissing}
+	case !r.globs.SweepUnmatched:
+		return ToolSweepResult{Reason: ReasonPathExists}
+	}
+
+	if r.mounts != nil {
+		if err := checkWalkWithin(ctx, r.mounts, base, r.walkTimeout); err != nil {
+			return ToolSweepResult{Warn: err.Error(), Reason: unknownReason(err)}
 		}
-		paths = append(paths, cleaned)
 	}
-	return paths
+	budget := r.globs.WalkBudget
+	if budget <= 0 {
+		budget = DefaultGlobWalkBudget
+	}
+	matched, err := globMatchesWithin(ctx, base, segments, budget, r.walkTimeout)
+	switch {
+	case errors.Is(err, errWalkBudget):
+		return ToolSweepResult{Warn: err.Error(), Reason: ReasonWalkBudget}
+	case err != nil:
+		return ToolSweepResult{Warn: err.Error(), Reason: unknownReason(err)}
+	case !matched:
+		return ToolSweepResult{Sweep: true, Reason: ReasonGlobUnmatched}
+	}
+	return ToolSweepResult{Reason: ReasonPathExists}
 }
 
 // BashExcluder decides whether a Bash permission specifier should be
@@ -258,15 +417,31 @@ func (e *BashExcluder) IsExcluded(specifier string, absPaths []string) bool {
 // BashToolSweeper sweeps Bash permission entries where all
 // resolvable paths in the specifier are non-existent.
 // Entries with no resolvable paths or at least one existing path are kept.
+// The specifier is parsed as a shell command line; see
+// extractCommandPaths. Specifiers that do not parse are kept with a
+// warning, as are specifiers whose only candidate paths reference
+// unknown variables.
+//
+// When programs is set, the program run by each command is also
+// resolved, and entries running a program that no longer exists are
+// swept regardless of their path arguments.
 type BashToolSweeper struct {
 	checker    PathChecker
 	homeDir    string
 	projectDir string
 	level      SettingsLevel
+	vars       pathVars
 	excluder   *BashExcluder
+	programs   *programCheck
 	active     bool
 }
 
+// programCheck configures the opt-in check of Bash command programs.
+type programCheck struct {
+	keep       set.Value[string]
+	searchPath []string
+}
+
 // NewBashToolSweeper creates a BashToolSweeper.
 // active controls whether sweeping is performed at all;
 // when false, ShouldSweep always returns a zero result.
@@ -279,6 +454,7 @@ func NewBashToolSweeper(checker PathChecker, homeDir, projectDir string, level S
 		homeDir:    homeDir,
 		projectDir: projectDir,
 		level:      level,
+		vars:       newPathVars(homeDir, projectDir, level, nil, nil),
 		excluder:   excluder,
 		active:     active,
 	}, nil
@@ -286,37 +462,38 @@ func NewBashToolSweeper(checker PathChecker, homeDir, projectDir string, level S
 
 func (b *BashToolSweeper) ShouldSweep(ctx context.Context, entry StandardEntry) ToolSweepResult {
 	if !b.active {
-		return ToolSweepResult{}
+		return ToolSweepResult{Reason: ReasonInactive}
 	}
 	specifier := entry.Specifier
 
 	cmd, _, _ := strings.Cut(specifier, " ")
 	if b.excluder.removeCommands.Has(cmd) {
-		return ToolSweepResult{Sweep: true, AllowOnly: true}
+		return ToolSweepResult{Sweep: true, AllowOnly: true, Reason: ReasonRemoveCommands}
 	}
 
-	absPaths := extractAbsolutePaths(specifier)
+	paths, err := extractCommandPaths(specifier, b.vars)
+	if err != nil {
+		return ToolSweepResult{Warn: err.Error(), Reason: ReasonUnparsable}
+	}
+	absPaths := paths.Abs
 
 	if b.excluder.IsExcluded(specifier, absPaths) {
-		return ToolSweepResult{}
+		return ToolSweepResult{Reason: ReasonExcludedByConfig}
+	}
+
+	if b.programs != nil {
+		for _, name := range paths.Programs {
+			if exists, ok, err := b.programExists(ctx, name); ok && err == nil && !exists {
+				return ToolSweepResult{Sweep: true, Reason: ReasonProgramMissing}
+			}
+		}
 	}
-	relPaths := extractRelativePaths(specifier)
 
 	// Resolve relative paths to absolute paths.
 	var resolved []string
-	for _, p := range relPaths {
-		switch {
-		case strings.HasPrefix(p, "~/"):
-			if b.homeDir == "" {
-				continue
-			}
-			rest, _ := strings.CutPrefix(p, "~/")
-			resolved = append(resolved, filepath.Join(b.homeDir, rest))
-		case strings.HasPrefix(p, "./") || strings.HasPrefix(p, "../"):
-			if b.level != ProjectLevel {
-				continue
-			}
-			resolved = append(resolved, filepath.Join(b.projectDir, p))
+	for _, p := range paths.Rel {
+		if r, ok := b.resolve(p); ok {
+			resolved = append(resolved, r)
 		}
 	}
 
@@ -324,65 +501,171 @@ func (b *BashToolSweeper) ShouldSweep(ctx context.Context, entry StandardEntry)
 	allPaths = append(allPaths, absPaths...)
 	allPaths = append(allPaths, resolved...)
 	if len(allPaths) == 0 {
-		return ToolSweepResult{}
+		if len(paths.Unknown) > 0 {
+			return unknownVarResult(paths.Unknown)
+		}
+		return ToolSweepResult{Reason: ReasonNoPaths}
 	}
 
+	// Any existing path keeps the entry. Otherwise a single path
+	// of unknown status is enough to keep it with a warning.
+	var unknownErr error
 	for _, p := range allPaths {
-		if b.checker.Exists(ctx, p) {
-			return ToolSweepResult{}
+		exists, err := b.checker.Exists(ctx, p)
+		if err != nil {
+			if unknownErr == nil {
+				unknownErr = err
+			}
+			continue
+		}
+		if exists {
+			return ToolSweepResult{Reason: ReasonPathExists}
+		}
+	}
+	if len(paths.Unknown) > 0 {
+		return unknownVarResult(paths.Unknown)
+	}
+	if unknownErr != nil {
+		return ToolSweepResult{Warn: unknownErr.Error(), Reason: unknownReason(unknownErr)}
+	}
+	return ToolSweepResult{Sweep: true, Reason: ReasonPathMissing}
+}
+
+// resolve returns the absolute path for p. ~/ paths are joined with
+// the home directory; other relative paths are joined with the
+// project directory, so they resolve only at project level.
+func (b *BashToolSweeper) resolve(p string) (string, bool) {
+	switch {
+	case filepath.IsAbs(p):
+		return filepath.Clean(p), true
+	case strings.HasPrefix(p, "~/"):
+		if b.homeDir == "" {
+			return "", false
+		}
+		return filepath.Join(b.homeDir, p[2:]), true
+	default:
+		if b.level != ProjectLevel {
+			return "", false
 		}
+		return filepath.Join(b.projectDir, p), true
+	}
+}
+
+// programExists reports whether the program a command runs can be
+// found. Shell builtins and kept programs always exist. Names
+// containing a / are resolved like path arguments; bare names are
+// looked up in each search path directory, and relative directories
+// resolve against the project directory. ok is false when nothing
+// could be checked. err is the first unknown status when the
+// program was not found elsewhere.
+func (b *BashToolSweeper) programExists(ctx context.Context, name string) (exists, ok bool, err error) {
+	if shellBuiltins.Has(name) || b.programs.keep.Has(name) {
+		return true, true, nil
+	}
+	if strings.Contains(name, "/") {
+		p, resolved := b.resolve(name)
+		if !resolved {
+			return false, false, nil
+		}
+		exists, err := b.checker.Exists(ctx, p)
+		return exists, true, err
+	}
+	var firstErr error
+	for _, dir := range b.programs.searchPath {
+		if dir == "" {
+			continue
+		}
+		dir, resolved := b.resolve(dir)
+		if !resolved {
+			continue
+		}
+		ok = true
+		exists, err := b.checker.Exists(ctx, filepath.Join(dir, name))
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if exists {
+			return true, true, nil
+		}
+	}
+	return false, ok, firstErr
+}
+
+// unknownVarResult keeps an entry whose paths reference variables
+// that could not be expanded.
+func unknownVarResult(names []string) ToolSweepResult {
+	return ToolSweepResult{
+		Warn:   fmt.Sprintf("%v: %s", ErrUnknownVar, strings.Join(names, ", ")),
+		Reason: ReasonUnknownVar,
 	}
-	return ToolSweepResult{Sweep: true}
 }
 
 // TaskToolSweeper sweeps Task permission entries where the
-// referenced agent no longer exists. Built-in agents, plugin
-// agents (containing ":"), and agents whose name appears in
-// the agent name set are always kept.
+// referenced agent no longer exists. Built-in agents and agents
+// found in any scope are always kept; the result reports the
+// scope that provided the agent. Plugin agents (containing ":")
+// are checked against plugins.
 type TaskToolSweeper struct {
-	agents set.Value[string]
+	agents  ScopedNames
+	plugins *PluginIndex
 }
 
-// NewTaskToolSweeper creates a TaskToolSweeper.
-func NewTaskToolSweeper(agents set.Value[string]) *TaskToolSweeper {
-	return &TaskToolSweeper{agents: agents}
+// NewTaskToolSweeper creates a TaskToolSweeper. A nil plugins keeps
+// every plugin agent.
+func NewTaskToolSweeper(agents ScopedNames, plugins *PluginIndex) *TaskToolSweeper {
+	return &TaskToolSweeper{agents: agents, plugins: plugins}
 }
 
 func (t *TaskToolSweeper) ShouldSweep(_ context.Context, entry StandardEntry) ToolSweepResult {
 	specifier := entry.Specifier
 	if builtinAgents.Has(specifier) {
-		return ToolSweepResult{}
+		return ToolSweepResult{Reason: ReasonBuiltinAgent}
 	}
-	// Plugin agents use "plugin-name:agent-name" convention
-	// and are managed by the plugin system, not by .md files.
-	if strings.Contains(specifier, ":") {
-		return ToolSweepResult{}
+	if scope, ok := t.agents.Lookup(specifier); ok {
+		return ToolSweepResult{Reason: ReasonAgentExists, Scope: scope}
 	}
-	if t.agents.Len() == 0 {
-		return ToolSweepResult{}
+	// Plugin agents use "plugin-name:agent-name" convention.
+	if strings.Contains(specifier, ":") {
+		return t.plugins.componentResult(specifier, ReasonAgentMissing)
 	}
-	if t.agents.Has(specifier) {
-		return ToolSweepResult{}
+	if !t.agents.hasLocal() {
+		return ToolSweepResult{Reason: ReasonNoContext}
 	}
-	return ToolSweepResult{Sweep: true}
+	return ToolSweepResult{Sweep: true, Reason: ReasonAgentMissing}
 }
 
 // SweepResult holds statistics from permission sweeping.
 // Deny entries are intentionally excluded from sweeping because they represent
 // explicit user prohibitions; removing stale deny rules costs nothing but
 // could silently re-enable a previously blocked action.
+//
+// Records holds one entry per evaluated string entry in allow, ask
+// and additionalDirectories, in the order they appear in the input.
 type SweepResult struct {
-	SweptAllow int
-	SweptAsk   int
-	Warns      []string
+	SweptAllow       int           `json:"sweptAllow"`
+	SweptAsk         int           `json:"sweptAsk"`
+	SweptDirectories int           `json:"sweptDirectories,omitempty"`
+	Warns            []string      `json:"warns,omitempty"`
+	Records          []SweepRecord `json:"records,omitempty"`
 }
 
-// sweepCategory pairs a permission category key with its swept count.
+// sweepCategory pairs a permission category key with the function
+// deciding its entries and its swept count.
 type sweepCategory struct {
 	key   string
+	eval  func(context.Context, string) (ToolName, ToolSweepResult)
 	count int
 }
 
+// pendingEntry is an entry awaiting evaluation by eval.
+type pendingEntry struct {
+	entry string
+	eval  func(context.Context, string) (ToolName, ToolSweepResult)
+}
+
 // PermissionSweeper sweeps stale permission entries from settings objects.
 // It dispatches to tool-specific ToolSweeper implementations based on the
 // tool name extracted from each entry. Entries for unregistered tools are
@@ -390,7 +673,10 @@ type sweepCategory struct {
 //
 // Ref: https://code.claude.com/docs/en/permissions#permission-rule-syntax
 type PermissionSweeper struct {
-	tools map[ToolName]ToolSweeper
+	tools   map[ToolName]ToolSweeper
+	dirs    *ReadEditToolSweeper
+	plugins *PluginIndex
+	jobs    int
 }
 
 // SettingsLevel distinguishes user-level (~/.claude/) from
@@ -419,8 +705,17 @@ type SweepOption func(*sweepConfig)
 type sweepConfig struct {
 	level      SettingsLevel
 	projectDir string
+	baseDir    string
 	unsafe     bool
 	bashCfg    *BashPermissionConfig
+	jobs       int
+	varNames   []string
+	lookupEnv  func(string) (string, bool)
+	searchPath []string
+	globCfg    *GlobPermissionConfig
+	timeout    time.Duration
+	mounts     *MountAwareChecker
+	plugins    []InstalledPlugin
 }
 
 // WithProjectLevel marks the target as project-level settings and
@@ -432,6 +727,28 @@ func WithProjectLevel(projectDir string) SweepOption {
 	}
 }
 
+// WithBaseDir sets the directory that /path Read/Edit specifiers
+// are resolved against, for settings files outside a project (e.g.
+// ~/.claude/settings.json). See SettingsBaseDir. At project level it
+// defaults to the project directory.
+func WithBaseDir(dir string) SweepOption {
+	return func(c *sweepConfig) {
+		c.baseDir = dir
+	}
+}
+
+// SettingsBaseDir returns the directory Claude Code resolves
+// settings-relative (/path) permission rules against: the parent of the
+// .claude directory containing the settings file, or the file's
+// own directory when it is not inside a .claude directory.
+func SettingsBaseDir(settingsPath string) string {
+	dir := filepath.Dir(settingsPath)
+	if filepath.Base(dir) == ".claude" {
+		return filepath.Dir(dir)
+	}
+	return dir
+}
+
 // WithBashConfig sets the BashPermissionConfig for Bash sweeping.
 // Exclude patterns filter entries from sweeping.
 // When cfg.Enabled is true, Bash sweep runs without --unsafe.
@@ -448,6 +765,70 @@ func WithUnsafe() SweepOption {
 	}
 }
 
+// WithJobs bounds the number of entries evaluated concurrently.
+// Values below 1 evaluate entries one at a time.
+func WithJobs(n int) SweepOption {
+	return func(c *sweepConfig) {
+		c.jobs = n
+	}
+}
+
+// WithExpandVars allows the named environment variables to be
+// expanded in Read, Edit and Bash path specifiers. lookup reads
+// their values (typically os.LookupEnv). HOME, and at project level
+// PWD and CLAUDE_PROJECT_DIR, are always available.
+func WithExpandVars(names []string, lookup func(string) (string, bool)) SweepOption {
+	return func(c *sweepConfig) {
+		c.varNames = names
+		c.lookupEnv = lookup
+	}
+}
+
+// WithGlobConfig sets the GlobPermissionConfig for Read/Edit glob
+// specifiers. Without it, glob specifiers are always kept.
+func WithGlobConfig(cfg *GlobPermissionConfig) SweepOption {
+	return func(c *sweepConfig) {
+		c.globCfg = cfg
+	}
+}
+
+// WithCheckTimeout bounds each glob walk by d, as TimeoutChecker
+// bounds existence checks. Walks that do not finish in time return
+// ErrCheckTimeout and the entry is kept.
+func WithCheckTimeout(d time.Duration) SweepOption {
+	return func(c *sweepConfig) {
+		c.timeout = d
+	}
+}
+
+// WithMountChecker sets the MountAwareChecker consulted before a
+// glob walk, so that directories under volatile roots or on
+// unmounted volumes are not walked.
+func WithMountChecker(m *MountAwareChecker) SweepOption {
+	return func(c *sweepConfig) {
+		c.mounts = m
+	}
+}
+
+// WithPlugins sets the installed plugins whose agents and skills
+// resolve "plugin:name" Task and Skill entries. See
+// LoadInstalledPlugins.
+func WithPlugins(plugins []InstalledPlugin) SweepOption {
+	return func(c *sweepConfig) {
+		c.plugins = plugins
+	}
+}
+
+// WithSearchPath sets the directories searched for bare command
+// names when [permission.bash] check_executables is enabled and
+// search_path is not configured (typically the PATH environment
+// variable).
+func WithSearchPath(dirs []string) SweepOption {
+	return func(c *sweepConfig) {
+		c.searchPath = dirs
+	}
+}
+
 // NewPermissionSweeper creates a PermissionSweeper.
 // homeDir is required for resolving ~/path specifiers.
 // servers is the set of known MCP server names for MCP sweep.
@@ -457,33 +838,33 @@ func NewPermissionSweeper(checker PathChecker, homeDir string, servers set.Value
 		o(&cfg)
 	}
 
+	vars := newPathVars(homeDir, cfg.projectDir, cfg.level, cfg.varNames, cfg.lookupEnv)
 	re := &ReadEditToolSweeper{
-		checker:    checker,
-		homeDir:    homeDir,
-		projectDir: cfg.projectDir,
-		level:      cfg.level,
+		checker:     checker,
+		homeDir:     homeDir,
+		projectDir:  cfg.projectDir,
+		baseDir:     cfg.baseDir,
+		level:       cfg.level,
+		vars:        vars,
+		globs:       cfg.globCfg,
+		walkTimeout: cfg.timeout,
+		mounts:      cfg.mounts,
 	}
 
 	mcp := NewMCPToolSweeper(servers)
 
-	var claudeDir string
-	switch cfg.level {
-	case ProjectLevel:
-		if cfg.projectDir != "" {
-			claudeDir = filepath.Join(cfg.projectDir, ".claude")
-		}
-	case UserLevel:
-		if homeDir != "" {
-			claudeDir = filepath.Join(homeDir, ".claude")
-		}
+	// Claude Code searches the project, then the user, then
+	// installed plugins for agents and skills.
+	var dirs []scopeDir
+	if cfg.level == ProjectLevel && cfg.projectDir != "" {
+		dirs = append(dirs, scopeDir{ScopeProject, filepath.Join(cfg.projectDir, ".claude")})
 	}
-
-	var agentsDir string
-	if claudeDir != "" {
-		agentsDir = filepath.Join(claudeDir, "agents")
+	if homeDir != "" {
+		dirs = append(dirs, scopeDir{ScopeUser, filepath.Join(homeDir, ".claude")})
 	}
-	task := NewTaskToolSweeper(LoadAgentNames(agentsDir))
-	skill := NewSkillToolSweeper(LoadSkillNames(claudeDir))
+	plugins := NewPluginIndex(cfg.plugins)
+	task := NewTaskToolSweeper(loadScopedNames(dirs, loadAgents, cfg.plugins, pluginAgents), plugins)
+	skill := NewSkillToolSweeper(loadScopedNames(dirs, LoadSkillNames, cfg.plugins, pluginSkills), plugins)
 
 	var bashCfg BashPermissionConfig
 	if cfg.bashCfg != nil {
@@ -497,6 +878,17 @@ func NewPermissionSweeper(checker PathChecker, homeDir string, servers set.Value
 	if err != nil {
 		return nil, fmt.Errorf("NewPermissionSweeper: %w", err)
 	}
+	bash.vars = vars
+	if bashCfg.CheckExecutables {
+		searchPath := bashCfg.SearchPath
+		if len(searchPath) == 0 {
+			searchPath = cfg.searchPath
+		}
+		bash.programs = &programCheck{
+			keep:       set.New(bashCfg.KeepExecutables...),
+			searchPath: searchPath,
+		}
+	}
 
 	tools := map[ToolName]ToolSweeper{
 		ToolRead:  NewToolSweeper(re.ShouldSweep),
@@ -507,12 +899,19 @@ func NewPermissionSweeper(checker PathChecker, homeDir string, servers set.Value
 		ToolSkill: NewToolSweeper(skill.ShouldSweep),
 	}
 
-	return &PermissionSweeper{tools: tools}, nil
+	dirSweeper := *re
+	dirSweeper.dirsOnly = true
+	return &PermissionSweeper{tools: tools, dirs: &dirSweeper, plugins: plugins, jobs: cfg.jobs}, nil
 }
 
-// Sweep removes stale allow/ask permission entries from obj.
+// Sweep removes stale allow/ask permission entries and missing
+// additionalDirectories from obj. A nil PermissionSweeper sweeps
+// nothing.
 func (p *PermissionSweeper) Sweep(ctx context.Context, obj map[string]any) *SweepResult {
 	result := &SweepResult{}
+	if p == nil {
+		return result
+	}
 
 	raw, ok := obj["permissions"]
 	if !ok {
@@ -524,10 +923,23 @@ func (p *PermissionSweeper) Sweep(ctx context.Context, obj map[string]any) *Swee
 	}
 
 	categories := []sweepCategory{
-		{key: "allow"},
-		{key: "ask"},
+		{key: "allow", eval: p.shouldSweep},
+		{key: "ask", eval: p.shouldSweep},
+		{key: "additionalDirectories", eval: p.shouldSweepDirectory},
+	}
+
+	var entries []pendingEntry
+	for _, cat := range categories {
+		arr, _ := perms[cat.key].([]any)
+		for _, v := range arr {
+			if entry, ok := v.(string); ok {
+				entries = append(entries, pendingEntry{entry: entry, eval: cat.eval})
+			}
+		}
 	}
+	decisions := p.evaluate(ctx, entries)
 
+	next := 0
 	for i, cat := range categories {
 		raw, ok := perms[cat.key]
 		if !ok {
@@ -546,8 +958,28 @@ func (p *PermissionSweeper) Sweep(ctx context.Context, obj map[string]any) *Swee
 				kept = append(kept, v)
 				continue
 			}
-			r := p.shouldSweep(ctx, entry, result)
-			if r.Sweep && (!r.AllowOnly || cat.key == "allow") {
+			tool, r := decisions[next].tool, decisions[next].result
+			next++
+			rec := SweepRecord{
+				Category: cat.key,
+				Entry:    entry,
+				Tool:     tool,
+				Decision: DecisionKept,
+				Reason:   r.Reason,
+				Scope:    r.Scope,
+			}
+			switch {
+			case r.Warn != "":
+				result.Warns = append(result.Warns, entry)
+				rec.Decision = DecisionWarned
+				rec.Detail = r.Warn
+			case r.Sweep && r.AllowOnly && cat.key != "allow":
+				rec.Reason = ReasonAllowOnly
+			case r.Sweep:
+				rec.Decision = DecisionSwept
+			}
+			result.Records = append(result.Records, rec)
+			if rec.Decision == DecisionSwept {
 				categories[i].count++
 				continue
 			}
@@ -558,24 +990,81 @@ func (p *PermissionSweeper) Sweep(ctx context.Context, obj map[string]any) *Swee
 
 	result.SweptAllow = categories[0].count
 	result.SweptAsk = categories[1].count
+	result.SweptDirectories = categories[2].count
 	return result
 }
 
-func (p *PermissionSweeper) shouldSweep(ctx context.Context, entry string, result *SweepResult) ToolSweepResult {
+// entryDecision is the tool sweeper outcome for a single entry.
+type entryDecision struct {
+	tool   ToolName
+	result ToolSweepResult
+	done   bool
+}
+
+// evaluate runs each entry's eval with at most p.jobs entries in
+// flight and returns the outcomes in input order. Entries not
+// started because ctx ended are kept with a warning, without
+// calling their tool sweeper.
+func (p *PermissionSweeper) evaluate(ctx context.Context, entries []pendingEntry) []entryDecision {
+	decisions := make([]entryDecision, len(entries))
+	_ = pool.Run(ctx, p.jobs, len(entries), func(ctx context.Context, i int) error {
+		tool, r := entries[i].eval(ctx, entries[i].entry)
+		decisions[i] = entryDecision{tool: tool, result: r, done: true}
+		return nil
+	})
+	for i := range decisions {
+		if !decisions[i].done {
+			decisions[i] = entryDecision{
+				tool:   entryTool(entries[i].entry),
+				result: ToolSweepResult{Warn: ctx.Err().Error(), Reason: ReasonContextCanceled},
+				done:   true,
+			}
+		}
+	}
+	return decisions
+}
+
+// entryTool returns the tool name shouldSweep reports for entry,
+// without evaluating it.
+func entryTool(entry string) ToolName {
+	if te := extractToolEntry(entry); te != nil {
+		return te.Name()
+	}
+	if strings.HasPrefix(entry, "mcp__plugin_") {
+		return ToolMCP
+	}
+	return ""
+}
+
+// shouldSweepDirectory decides an additionalDirectories entry. It
+// is resolved like a Read/Edit specifier, except that an absolute
+// path is used as is rather than relative to the settings file, as
+// Claude Code does for additional directories, and the path must be
+// a directory.
+func (p *PermissionSweeper) shouldSweepDirectory(ctx context.Context, dir string) (ToolName, ToolSweepResult) {
+	if filepath.IsAbs(dir) {
+		return "", p.dirs.sweepAbsolute(ctx, dir)
+	}
+	return "", p.dirs.ShouldSweep(ctx, StandardEntry{Specifier: dir})
+}
+
+// shouldSweep routes entry to its tool sweeper and returns the
+// tool name alongside the result. Unrecognized entries and
+// unregistered tools are kept with an explanatory reason.
+func (p *PermissionSweeper) shouldSweep(ctx context.Context, entry string) (ToolName, ToolSweepResult) {
 	te := extractToolEntry(entry)
 	if te == nil {
-		return ToolSweepResult{}
+		// Plugin MCP entries are checked against installed plugins.
+		if strings.HasPrefix(entry, "mcp__plugin_") {
+			return ToolMCP, p.plugins.mcpResult(entry)
+		}
+		return "", ToolSweepResult{Reason: ReasonUnrecognized}
 	}
 
 	tool, ok := p.tools[te.Name()]
 	if !ok {
-		return ToolSweepResult{}
+		return te.Name(), ToolSweepResult{Reason: ReasonNotSwept}
 	}
 
-	r := tool.ShouldSweep(ctx, te)
-	if r.Warn != "" {
-		result.Warns = append(result.Warns, entry)
-		return ToolSweepResult{}
-	}
-	return r
+	return te.Name(), tool.ShouldSweep(ctx, te)
 }
@@ -1,8 +1,12 @@
 package cctidy
 
 import (
+	"context"
+	"errors"
 	"os"
 	"path/filepath"
+	"reflect"
+	"slices"
 	"testing"
 
 	"github.com/708u/cctidy/internal/set"
@@ -111,174 +115,14 @@ func TestContainsGlob(t *testing.T) {
 	}
 }
 
-func TestExtractAbsolutePaths(t *testing.T) {
-	t.Parallel()
-	tests := []struct {
-		name  string
-		input string
-		want  []string
-	}{
-		{
-			name:  "single absolute path",
-			input: "git -C /home/user/repo status",
-			want:  []string{"/home/user/repo"},
-		},
-		{
-			name:  "glob stops extraction",
-			input: "npm run *",
-			want:  nil,
-		},
-		{
-			name:  "shell metachar stops at &&",
-			input: "cd /path && make",
-			want:  []string{"/path"},
-		},
-		{
-			name:  "semicolon stops extraction",
-			input: "cd /path;make",
-			want:  []string{"/path"},
-		},
-		{
-			name:  "equals-prefixed path",
-			input: "--config=/etc/app.conf",
-			want:  []string{"/etc/app.conf"},
-		},
-		{
-			name:  "multiple paths",
-			input: "cp /src/a /dst/b",
-			want:  []string{"/src/a", "/dst/b"},
-		},
-		{
-			name:  "no absolute paths",
-			input: "echo hello",
-			want:  nil,
-		},
-		{
-			name:  "trailing slash trimmed",
-			input: "ls /some/dir/",
-			want:  []string{"/some/dir"},
-		},
-		{
-			name:  "trailing dot preserved",
-			input: "ls /some/path.",
-			want:  []string{"/some/path."},
-		},
-		{
-			name:  "root only path filtered",
-			input: "ls /",
-			want:  nil,
-		},
-		{
-			name:  "path with underscores and dots",
-			input: "cat /home/user/.config/app_v2/settings.json",
-			want:  []string{"/home/user/.config/app_v2/settings.json"},
-		},
-		{
-			name:  "dot-slash relative path is not extracted",
-			input: "./bin/run",
-			want:  nil,
-		},
-		{
-			name:  "dot-dot-slash relative path is not extracted",
-			input: "../src/main.go",
-			want:  nil,
-		},
-		{
-			name:  "tilde home path is not extracted",
-			input: "cat ~/config.json",
-			want:  nil,
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			t.Parallel()
-			got := extractAbsolutePaths(tt.input)
-			if len(got) != len(tt.want) {
-				t.Fatalf("extractAbsolutePaths(%q) = %v, want %v", tt.input, got, tt.want)
-			}
-			for i := range got {
-				if got[i] != tt.want[i] {
-					t.Errorf("extractAbsolutePaths(%q)[%d] = %q, want %q", tt.input, i, got[i], tt.want[i])
-				}
-			}
-		})
-	}
-}
-
-func TestExtractRelativePaths(t *testing.T) {
-	t.Parallel()
-	tests := []struct {
-		name  string
-		input string
-		want  []string
-	}{
-		{
-			name:  "dot-slash path",
-			input: "cat ./src/main.go",
-			want:  []string{"./src/main.go"},
-		},
-		{
-			name:  "dot-dot-slash path",
-			input: "cat ../other/file",
-			want:  []string{"../other/file"},
-		},
-		{
-			name:  "tilde path",
-			input: "cat ~/config.json",
-			want:  []string{"~/config.json"},
-		},
-		{
-			name:  "mixed relative paths",
-			input: "cat ~/file ./local ../parent",
-			want:  []string{"~/file", "./local", "../parent"},
-		},
-		{
-			name:  "bare relative path not extracted",
-			input: "cat bare/path",
-			want:  nil,
-		},
-		{
-			name:  "no paths",
-			input: "echo hello",
-			want:  nil,
-		},
-		{
-			name:  "trailing slash trimmed",
-			input: "ls ./dir/",
-			want:  []string{"./dir"},
-		},
-		{
-			name:  "trailing dot preserved",
-			input: "ls ./path.",
-			want:  []string{"./path."},
-		},
-		{
-			name:  "equals-prefixed dot-slash",
-			input: "--config=./app.conf",
-			want:  []string{"./app.conf"},
-		},
-		{
-			name:  "equals-prefixed tilde",
-			input: "--config=~/app.conf",
-			want:  []string{"~/app.conf"},
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			t.Parallel()
-			got := extractRelativePaths(tt.input)
-			if len(got) != len(tt.want) {
-				t.Fatalf("extractRelativePaths(%q) = %v, want %v", tt.input, got, tt.want)
-			}
-			for i := range got {
-				if got[i] != tt.want[i] {
-					t.Errorf("extractRelativePaths(%q)[%d] = %q, want %q", tt.input, i, got[i], tt.want[i])
-				}
-			}
-		})
+// absPaths returns the absolute paths extracted from a Bash specifier.
+func absPaths(t *testing.T, specifier string) []string {
+	t.Helper()
+	paths, err := extractCommandPaths(specifier, nil)
+	if err != nil {
+		t.Fatalf("extractCommandPaths(%q): %v", specifier, err)
 	}
+	return paths.Abs
 }
 
 var noExcludes = NewBashExcluder(BashPermissionConfig{})
@@ -385,6 +229,36 @@ func TestBashToolSweeperShouldSweep(t *testing.T) {
 			specifier: "cat ./local ../parent ~/home",
 			wantSweep: false,
 		},
+		{
+			name:      "quoted path with spaces dead",
+			sweeper:   mustNewBashToolSweeper(t, testutil.NoPathsExist{}, "", "", UserLevel, noExcludes, true),
+			specifier: "cat '/dead/my dir/file'",
+			wantSweep: true,
+		},
+		{
+			name:      "quoted path with spaces alive",
+			sweeper:   mustNewBashToolSweeper(t, testutil.CheckerFor("/alive/my dir/file"), "", "", UserLevel, noExcludes, true),
+			specifier: `cat "/alive/my dir/file" /dead/other`,
+			wantSweep: false,
+		},
+		{
+			name:      "URL is not a dead path",
+			sweeper:   mustNewBashToolSweeper(t, testutil.NoPathsExist{}, "", "", UserLevel, noExcludes, true),
+			specifier: "curl https://example.com/api/v1",
+			wantSweep: false,
+		},
+		{
+			name:      "path in command substitution",
+			sweeper:   mustNewBashToolSweeper(t, testutil.NoPathsExist{}, "", "", UserLevel, noExcludes, true),
+			specifier: "echo $(cat /dead/version)",
+			wantSweep: true,
+		},
+		{
+			name:      "unparsable command keeps entry",
+			sweeper:   mustNewBashToolSweeper(t, testutil.NoPathsExist{}, "", "", UserLevel, noExcludes, true),
+			specifier: `cat "/dead/unterminated`,
+			wantSweep: false,
+		},
 		{
 			name: "remove command sweeps entry without paths",
 			sweeper: mustNewBashToolSweeper(t, testutil.AllPathsExist{}, "", "", UserLevel,
@@ -502,7 +376,7 @@ func TestBashExcluderIsExcluded(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			t.Parallel()
-			if got := excl.IsExcluded(tt.specifier, extractAbsolutePaths(tt.specifier)); got != tt.want {
+			if got := excl.IsExcluded(tt.specifier, absPaths(t, tt.specifier)); got != tt.want {
 				t.Errorf("IsExcluded(%q) = %v, want %v", tt.specifier, got, tt.want)
 			}
 		})
@@ -538,7 +412,7 @@ func TestBashExcluderPathBoundary(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			t.Parallel()
-			if got := excl.IsExcluded(tt.specifier, extractAbsolutePaths(tt.specifier)); got != tt.want {
+			if got := excl.IsExcluded(tt.specifier, absPaths(t, tt.specifier)); got != tt.want {
 				t.Errorf("IsExcluded(%q) = %v, want %v", tt.specifier, got, tt.want)
 			}
 		})
@@ -548,7 +422,7 @@ func TestBashExcluderPathBoundary(t *testing.T) {
 func TestBashExcluderEmpty(t *testing.T) {
 	t.Parallel()
 	excl := NewBashExcluder(BashPermissionConfig{})
-	if excl.IsExcluded("git -C /dead/repo status", extractAbsolutePaths("git -C /dead/repo status")) {
+	if excl.IsExcluded("git -C /dead/repo status", absPaths(t, "git -C /dead/repo status")) {
 		t.Error("empty excluder should not exclude anything")
 	}
 }
@@ -608,6 +482,88 @@ func TestBashToolSweeperWithExcluder(t *testing.T) {
 	}
 }
 
+func TestBashToolSweeperPrograms(t *testing.T) {
+	t.Parallel()
+	obj := map[string]any{
+		"permissions": map[string]any{
+			"allow": []any{
+				"Bash(/opt/tools/bin/lint:*)",
+				"Bash(mytool build:*)",
+				"Bash(go vet)",
+				"Bash(cd /alive && gone-tool run)",
+				"Bash(./bin/dev serve)",
+				"Bash(./bin/gone serve)",
+				"Bash(echo hello)",
+				"Bash(internal-cli sync)",
+				"Bash(flaky-tool run)",
+				"Bash($TOOL run)",
+				"Bash(\\time make)",
+				"Bash(\"export\" FOO=bar)",
+				"Bash(exec gone-tool)",
+			},
+		},
+	}
+	checker := testutil.UnknownSet{
+		Known:   testutil.CheckerFor("/usr/bin/go", "/alive", "/project/bin/dev"),
+		Unknown: set.New("/usr/bin/flaky-tool"),
+		Err:     errors.New("stat /usr/bin/flaky-tool: input/output error"),
+	}
+	cfg := &BashPermissionConfig{
+		Enabled:          true,
+		CheckExecutables: true,
+		KeepExecutables:  []string{"internal-cli"},
+	}
+	result := mustNewPermissionSweeper(t, checker, "/home/user", nil,
+		WithProjectLevel("/project"),
+		WithBashConfig(cfg),
+		WithSearchPath([]string{"/usr/bin", ""}),
+	).Sweep(t.Context(), obj)
+
+	want := map[string]SweepReason{
+		"Bash(/opt/tools/bin/lint:*)":      ReasonProgramMissing,
+		"Bash(mytool build:*)":             ReasonProgramMissing,
+		"Bash(go vet)":                     ReasonNoPaths,
+		"Bash(cd /alive && gone-tool run)": ReasonProgramMissing,
+		"Bash(./bin/dev serve)":            ReasonPathExists,
+		"Bash(./bin/gone serve)":           ReasonProgramMissing,
+		"Bash(echo hello)":                 ReasonNoPaths,
+		"Bash(internal-cli sync)":          ReasonNoPaths,
+		"Bash(flaky-tool run)":             ReasonNoPaths,
+		"Bash($TOOL run)":                  ReasonNoPaths,
+		"Bash(\\time make)":                ReasonNoPaths,
+		`Bash("export" FOO=bar)`:           ReasonNoPaths,
+		"Bash(exec gone-tool)":             ReasonNoPaths,
+	}
+	for _, rec := range result.Records {
+		if rec.Reason != want[rec.Entry] {
+			t.Errorf("%s: reason = %s, want %s", rec.Entry, rec.Reason, want[rec.Entry])
+		}
+		wantSwept := want[rec.Entry] == ReasonProgramMissing
+		if (rec.Decision == DecisionSwept) != wantSwept {
+			t.Errorf("%s: decision = %s, want swept %v", rec.Entry, rec.Decision, wantSwept)
+		}
+	}
+	if result.SweptAllow != 4 {
+		t.Errorf("SweptAllow = %d, want 4", result.SweptAllow)
+	}
+}
+
+func TestBashToolSweeperProgramsDisabled(t *testing.T) {
+	t.Parallel()
+	obj := map[string]any{
+		"permissions": map[string]any{
+			"allow": []any{"Bash(mytool build:*)"},
+		},
+	}
+	result := mustNewPermissionSweeper(t, testutil.NoPathsExist{}, "", nil,
+		WithUnsafe(),
+		WithSearchPath([]string{"/usr/bin"}),
+	).Sweep(t.Context(), obj)
+	if result.SweptAllow != 0 {
+		t.Errorf("SweptAllow = %d, want 0 without check_executables", result.SweptAllow)
+	}
+}
+
 func TestNewBashToolSweeperInvalidLevel(t *testing.T) {
 	t.Parallel()
 	_, err := NewBashToolSweeper(testutil.NoPathsExist{}, "", "", 0, noExcludes, true)
@@ -619,73 +575,109 @@ func TestNewBashToolSweeperInvalidLevel(t *testing.T) {
 func TestTaskToolSweeperShouldSweep(t *testing.T) {
 	t.Parallel()
 
-	agentsWithMyAgent := set.New("my-agent")
-	agentsWithProjAgent := set.New("proj-agent")
-	emptyAgents := set.New[string]()
+	agentsWithMyAgent := ScopedNames{"my-agent": ScopeProject}
+	agentsWithProjAgent := ScopedNames{"proj-agent": ScopeProject}
+	emptyAgents := ScopedNames{}
+	scopedAgents := ScopedNames{
+		"proj-agent":   ScopeProject,
+		"user-agent":   ScopeUser,
+		"review:agent": ScopePlugin,
+	}
+	pluginOnly := ScopedNames{"review:agent": ScopePlugin}
 
 	tests := []struct {
 		name      string
 		sweeper   *TaskToolSweeper
 		specifier string
 		wantSweep bool
+		wantScope Scope
 	}{
 		{
 			name:      "built-in Explore is kept",
-			sweeper:   NewTaskToolSweeper(emptyAgents),
+			sweeper:   NewTaskToolSweeper(emptyAgents, nil),
 			specifier: "Explore",
 			wantSweep: false,
 		},
 		{
 			name:      "built-in statusline-setup is kept",
-			sweeper:   NewTaskToolSweeper(emptyAgents),
+			sweeper:   NewTaskToolSweeper(emptyAgents, nil),
 			specifier: "statusline-setup",
 			wantSweep: false,
 		},
 		{
 			name:      "plugin agent with colon is kept",
-			sweeper:   NewTaskToolSweeper(emptyAgents),
+			sweeper:   NewTaskToolSweeper(emptyAgents, nil),
 			specifier: "plugin:agent",
 			wantSweep: false,
 		},
 		{
 			name:      "agent in name set is kept",
-			sweeper:   NewTaskToolSweeper(agentsWithMyAgent),
+			sweeper:   NewTaskToolSweeper(agentsWithMyAgent, nil),
 			specifier: "my-agent",
 			wantSweep: false,
+			wantScope: ScopeProject,
 		},
 		{
 			name:      "agent not in name set is swept",
-			sweeper:   NewTaskToolSweeper(agentsWithProjAgent),
+			sweeper:   NewTaskToolSweeper(agentsWithProjAgent, nil),
 			specifier: "my-agent",
 			wantSweep: true,
 		},
 		{
 			name:      "agent with project .md file is kept",
-			sweeper:   NewTaskToolSweeper(agentsWithProjAgent),
+			sweeper:   NewTaskToolSweeper(agentsWithProjAgent, nil),
 			specifier: "proj-agent",
 			wantSweep: false,
+			wantScope: ScopeProject,
 		},
 		{
 			name:      "dead agent is swept when agents set non-empty",
-			sweeper:   NewTaskToolSweeper(set.New("other-agent")),
+			sweeper:   NewTaskToolSweeper(ScopedNames{"other-agent": ScopeUser}, nil),
 			specifier: "dead-agent",
 			wantSweep: true,
 		},
 		{
 			name:      "frontmatter name is kept",
-			sweeper:   NewTaskToolSweeper(set.New("custom-name")),
+			sweeper:   NewTaskToolSweeper(ScopedNames{"custom-name": ScopeProject}, nil),
 			specifier: "custom-name",
 			wantSweep: false,
+			wantScope: ScopeProject,
 		},
 		{
 			name:      "nil agents keeps entry conservatively",
-			sweeper:   NewTaskToolSweeper(nil),
+			sweeper:   NewTaskToolSweeper(nil, nil),
 			specifier: "unknown",
 			wantSweep: false,
 		},
 		{
 			name:      "empty agents keeps unknown conservatively",
-			sweeper:   NewTaskToolSweeper(emptyAgents),
+			sweeper:   NewTaskToolSweeper(emptyAgents, nil),
+			specifier: "unknown",
+			wantSweep: false,
+		},
+		{
+			name:      "user agent reports user scope",
+			sweeper:   NewTaskToolSweeper(scopedAgents, nil),
+			specifier: "user-agent",
+			wantSweep: false,
+			wantScope: ScopeUser,
+		},
+		{
+			name:      "installed plugin agent reports plugin scope",
+			sweeper:   NewTaskToolSweeper(scopedAgents, nil),
+			specifier: "review:agent",
+			wantSweep: false,
+			wantScope: ScopePlugin,
+		},
+		{
+			name:      "agent missing from every scope is swept",
+			sweeper:   NewTaskToolSweeper(scopedAgents, nil),
+			specifier: "gone-agent",
+			wantSweep: true,
+		},
+		{
+			name:      "plugin agents alone keep unknown conservatively",
+			sweeper:   NewTaskToolSweeper(pluginOnly, nil),
 			specifier: "unknown",
 			wantSweep: false,
 		},
@@ -699,6 +691,9 @@ func TestTaskToolSweeperShouldSweep(t *testing.T) {
 			if result.Sweep != tt.wantSweep {
 				t.Errorf("ShouldSweep(%q) = %v, want %v", tt.specifier, result.Sweep, tt.wantSweep)
 			}
+			if result.Scope != tt.wantScope {
+				t.Errorf("ShouldSweep(%q).Scope = %q, want %q", tt.specifier, result.Scope, tt.wantScope)
+			}
 		})
 	}
 }
@@ -766,6 +761,36 @@ func TestReadEditToolSweeperShouldSweep(t *testing.T) {
 			specifier: "../other/file.go",
 			wantSweep: true,
 		},
+		{
+			name:      "slash-prefixed path at user level resolves against baseDir",
+			sweeper:   ReadEditToolSweeper{checker: testutil.NoPathsExist{}, baseDir: "/home/user", level: UserLevel},
+			specifier: "/notes/todo.md",
+			wantSweep: true,
+		},
+		{
+			name:      "bare path at user level is cwd relative and kept",
+			sweeper:   ReadEditToolSweeper{checker: testutil.NoPathsExist{}, baseDir: "/home/user", level: UserLevel},
+			specifier: "package.json",
+			wantSweep: false,
+		},
+		{
+			name:      "dot-slash path at user level is cwd relative and kept",
+			sweeper:   ReadEditToolSweeper{checker: testutil.NoPathsExist{}, baseDir: "/home/user", level: UserLevel},
+			specifier: "./src/**",
+			wantSweep: false,
+		},
+		{
+			name:      "baseDir takes precedence over projectDir for slash-prefixed path",
+			sweeper:   ReadEditToolSweeper{checker: testutil.CheckerFor("/elsewhere/src"), projectDir: "/project", baseDir: "/elsewhere", level: ProjectLevel},
+			specifier: "/src",
+			wantSweep: false,
+		},
+		{
+			name:      "dot-slash path at project level resolves against projectDir",
+			sweeper:   ReadEditToolSweeper{checker: testutil.CheckerFor("/project/src"), projectDir: "/project", baseDir: "/elsewhere", level: ProjectLevel},
+			specifier: "./src",
+			wantSweep: false,
+		},
 		{
 			name:      "slash-prefixed path with projectDir is resolved",
 			sweeper:   ReadEditToolSweeper{checker: testutil.CheckerFor("/project/src/file.go"), projectDir: "/project", level: ProjectLevel},
@@ -786,6 +811,23 @@ func TestReadEditToolSweeperShouldSweep(t *testing.T) {
 	}
 }
 
+func TestSettingsBaseDir(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: "/home/user/.claude/settings.json", want: "/home/user"},
+		{path: "/work/app/.claude/settings.local.json", want: "/work/app"},
+		{path: "/tmp/shared/settings.json", want: "/tmp/shared"},
+	}
+	for _, tt := range tests {
+		if got := SettingsBaseDir(tt.path); got != tt.want {
+			t.Errorf("SettingsBaseDir(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
 func TestSweepPermissions(t *testing.T) {
 	t.Parallel()
 
@@ -1405,3 +1447,441 @@ func TestSweepPermissions(t *testing.T) {
 	})
 
 }
+
+func TestSweepRecords(t *testing.T) {
+	t.Parallel()
+	obj := map[string]any{
+		"permissions": map[string]any{
+			"allow": []any{
+				"Read(//dead/path)",
+				"Read(//alive/path)",
+				"Read(**/*.ts)",
+				"Bash(npm install foo)",
+				"Bash(mkdir /dead/logs)",
+				"Task(Explore)",
+				"Task(plugin:agent)",
+				"mcp__gone__tool",
+				"mcp__plugin_github_github__search",
+				"WebFetch(domain:example.com)",
+				"Read",
+			},
+			"ask": []any{
+				"Bash(npm run build)",
+			},
+		},
+	}
+	cfg := &BashPermissionConfig{
+		Enabled:         true,
+		Allow:           BashAllowConfig{RemoveCommands: []string{"npm"}},
+		ExcludeCommands: []string{"mkdir"},
+	}
+	result := mustNewPermissionSweeper(t, testutil.CheckerFor("/alive/path"), "", set.New("github"), WithBashConfig(cfg)).Sweep(t.Context(), obj)
+
+	want := []SweepRecord{
+		{Category: "allow", Entry: "Read(//dead/path)", Tool: ToolRead, Decision: DecisionSwept, Reason: ReasonPathMissing},
+		{Category: "allow", Entry: "Read(//alive/path)", Tool: ToolRead, Decision: DecisionKept, Reason: ReasonPathExists},
+		{Category: "allow", Entry: "Read(**/*.ts)", Tool: ToolRead, Decision: DecisionKept, Reason: ReasonGlobSkipped},
+		{Category: "allow", Entry: "Bash(npm install foo)", Tool: ToolBash, Decision: DecisionSwept, Reason: ReasonRemoveCommands},
+		{Category: "allow", Entry: "Bash(mkdir /dead/logs)", Tool: ToolBash, Decision: DecisionKept, Reason: ReasonExcludedByConfig},
+		{Category: "allow", Entry: "Task(Explore)", Tool: ToolTask, Decision: DecisionKept, Reason: ReasonBuiltinAgent},
+		{Category: "allow", Entry: "Task(plugin:agent)", Tool: ToolTask, Decision: DecisionKept, Reason: ReasonPluginKept},
+		{Category: "allow", Entry: "mcp__gone__tool", Tool: ToolMCP, Decision: DecisionSwept, Reason: ReasonMCPServerUnknown},
+		{Category: "allow", Entry: "mcp__plugin_github_github__search", Tool: ToolMCP, Decision: DecisionKept, Reason: ReasonPluginKept},
+		{Category: "allow", Entry: "WebFetch(domain:example.com)", Tool: "WebFetch", Decision: DecisionKept, Reason: ReasonNotSwept},
+		{Category: "allow", Entry: "Read", Decision: DecisionKept, Reason: ReasonUnrecognized},
+		{Category: "ask", Entry: "Bash(npm run build)", Tool: ToolBash, Decision: DecisionKept, Reason: ReasonAllowOnly},
+	}
+	if !slices.Equal(result.Records, want) {
+		t.Errorf("Records mismatch:\ngot:  %+v\nwant: %+v", result.Records, want)
+	}
+}
+
+func TestSweepConcurrentMatchesSequential(t *testing.T) {
+	t.Parallel()
+	newObj := func() map[string]any {
+		return map[string]any{
+			"permissions": map[string]any{
+				"allow": []any{
+					"Read(//dead/a)",
+					"Read(//alive/path)",
+					"Edit(//dead/b)",
+					"mcp__gone__tool",
+					"Write",
+					42,
+				},
+				"ask": []any{
+					"Read(//dead/c)",
+					"Edit(//alive/path)",
+				},
+			},
+		}
+	}
+	checker := testutil.CheckerFor("/alive/path")
+
+	seqObj := newObj()
+	seq := mustNewPermissionSweeper(t, checker, "", nil).Sweep(t.Context(), seqObj)
+	parObj := newObj()
+	par := mustNewPermissionSweeper(t, checker, "", nil, WithJobs(4)).Sweep(t.Context(), parObj)
+
+	if !slices.Equal(seq.Records, par.Records) {
+		t.Errorf("Records mismatch:\nsequential: %+v\nconcurrent: %+v", seq.Records, par.Records)
+	}
+	if seq.SweptAllow != par.SweptAllow || seq.SweptAsk != par.SweptAsk {
+		t.Errorf("counts mismatch: sequential %d/%d, concurrent %d/%d",
+			seq.SweptAllow, seq.SweptAsk, par.SweptAllow, par.SweptAsk)
+	}
+	if !reflect.DeepEqual(seqObj, parObj) {
+		t.Errorf("swept object mismatch:\nsequential: %v\nconcurrent: %v", seqObj, parObj)
+	}
+}
+
+func TestSweepCanceledSkipsChecks(t *testing.T) {
+	t.Parallel()
+	obj := map[string]any{
+		"permissions": map[string]any{
+			"allow":                 []any{"Read(//dead/a)", "Edit(//dead/b)"},
+			"additionalDirectories": []any{"/dead/c"},
+		},
+	}
+	checker := &countingChecker{}
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	result := mustNewPermissionSweeper(t, checker, "", nil, WithJobs(2)).Sweep(ctx, obj)
+
+	if n := checker.calls.Load(); n != 0 {
+		t.Errorf("checker called %d times after cancellation", n)
+	}
+	if result.SweptAllow != 0 || result.SweptDirectories != 0 {
+		t.Errorf("swept %d allow, %d directories; want none", result.SweptAllow, result.SweptDirectories)
+	}
+	want := []SweepRecord{
+		{Category: "allow", Entry: "Read(//dead/a)", Tool: ToolRead, Decision: DecisionWarned, Reason: ReasonContextCanceled, Detail: context.Canceled.Error()},
+		{Category: "allow", Entry: "Edit(//dead/b)", Tool: ToolEdit, Decision: DecisionWarned, Reason: ReasonContextCanceled, Detail: context.Canceled.Error()},
+		{Category: "additionalDirectories", Entry: "/dead/c", Decision: DecisionWarned, Reason: ReasonContextCanceled, Detail: context.Canceled.Error()},
+	}
+	if !slices.Equal(result.Records, want) {
+		t.Errorf("Records = %+v\nwant %+v", result.Records, want)
+	}
+}
+
+func TestSweepUnknownPaths(t *testing.T) {
+	t.Parallel()
+	errDenied := errors.New("stat /denied/x: permission denied")
+	obj := map[string]any{
+		"permissions": map[string]any{
+			"allow": []any{
+				"Read(//denied/x)",
+				"Bash(cp /denied/x /dead/y)",
+				"Bash(cp /denied/x /alive/y)",
+			},
+		},
+	}
+	checker := testutil.UnknownSet{
+		Known:   testutil.CheckerFor("/alive/y"),
+		Unknown: set.New("/denied/x"),
+		Err:     errDenied,
+	}
+	result := mustNewPermissionSweeper(t, checker, "", nil, WithUnsafe()).Sweep(t.Context(), obj)
+
+	want := []SweepRecord{
+		{Category: "allow", Entry: "Read(//denied/x)", Tool: ToolRead, Decision: DecisionWarned, Reason: ReasonPathUnknown, Detail: errDenied.Error()},
+		{Category: "allow", Entry: "Bash(cp /denied/x /dead/y)", Tool: ToolBash, Decision: DecisionWarned, Reason: ReasonPathUnknown, Detail: errDenied.Error()},
+		{Category: "allow", Entry: "Bash(cp /denied/x /alive/y)", Tool: ToolBash, Decision: DecisionKept, Reason: ReasonPathExists},
+	}
+	if !slices.Equal(result.Records, want) {
+		t.Errorf("Records mismatch:\ngot:  %+v\nwant: %+v", result.Records, want)
+	}
+	if result.SweptAllow != 0 {
+		t.Errorf("SweptAllow = %d, want 0", result.SweptAllow)
+	}
+	if len(result.Warns) != 2 {
+		t.Errorf("Warns = %v, want 2 entries", result.Warns)
+	}
+}
+
+func TestSweepExpandVars(t *testing.T) {
+	t.Parallel()
+	obj := map[string]any{
+		"permissions": map[string]any{
+			"allow": []any{
+				"Read($HOME/.zshrc)",
+				"Edit(${XDG_CONFIG_HOME}/gone.toml)",
+				"Read(${PWD}/src/main.go)",
+				"Read($SECRET/key)",
+				"Bash(cat $XDG_CONFIG_HOME/git/config)",
+				"Bash(cat $SECRET/key /dead/file)",
+				"Bash(cat $SECRET/key /alive/file)",
+			},
+		},
+	}
+	env := map[string]string{
+		"XDG_CONFIG_HOME": "/home/user/.config",
+		"SECRET":          "/vault",
+	}
+	lookup := func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	}
+	checker := testutil.CheckerFor("/home/user/.zshrc", "/home/user/.config/git/config", "/alive/file")
+	result := mustNewPermissionSweeper(t, checker, "/home/user", nil,
+		WithProjectLevel("/project"),
+		WithExpandVars([]string{"XDG_CONFIG_HOME"}, lookup),
+		WithUnsafe(),
+	).Sweep(t.Context(), obj)
+
+	want := []SweepRecord{
+		{Category: "allow", Entry: "Read($HOME/.zshrc)", Tool: ToolRead, Decision: DecisionKept, Reason: ReasonPathExists},
+		{Category: "allow", Entry: "Edit(${XDG_CONFIG_HOME}/gone.toml)", Tool: ToolEdit, Decision: DecisionSwept, Reason: ReasonPathMissing},
+		{Category: "allow", Entry: "Read(${PWD}/src/main.go)", Tool: ToolRead, Decision: DecisionSwept, Reason: ReasonPathMissing},
+		{Category: "allow", Entry: "Read($SECRET/key)", Tool: ToolRead, Decision: DecisionWarned, Reason: ReasonUnknownVar, Detail: "unknown variable: $SECRET"},
+		{Category: "allow", Entry: "Bash(cat $XDG_CONFIG_HOME/git/config)", Tool: ToolBash, Decision: DecisionKept, Reason: ReasonPathExists},
+		{Category: "allow", Entry: "Bash(cat $SECRET/key /dead/file)", Tool: ToolBash, Decision: DecisionWarned, Reason: ReasonUnknownVar, Detail: "unknown variable: $SECRET"},
+		{Category: "allow", Entry: "Bash(cat $SECRET/key /alive/file)", Tool: ToolBash, Decision: DecisionKept, Reason: ReasonPathExists},
+	}
+	if !slices.Equal(result.Records, want) {
+		t.Errorf("Records mismatch:\ngot:  %+v\nwant: %+v", result.Records, want)
+	}
+}
+
+func TestSweepAdditionalDirectoriesAbsolute(t *testing.T) {
+	t.Parallel()
+	obj := map[string]any{
+		"permissions": map[string]any{
+			"additionalDirectories": []any{
+				"/abs/dir",
+				"/abs/other/../dir",
+				"$DATA/dir",
+				"/abs/gone",
+			},
+		},
+	}
+	lookup := func(name string) (string, bool) {
+		if name == "DATA" {
+			return "/abs", true
+		}
+		return "", false
+	}
+	checker := testutil.CheckerFor("/abs/dir/")
+	result := mustNewPermissionSweeper(t, checker, "/home/user", nil,
+		WithBaseDir("/settings"),
+		WithExpandVars([]string{"DATA"}, lookup),
+	).Sweep(t.Context(), obj)
+
+	want := []SweepRecord{
+		{Category: "additionalDirectories", Entry: "/abs/dir", Decision: DecisionKept, Reason: ReasonPathExists},
+		{Category: "additionalDirectories", Entry: "/abs/other/../dir", Decision: DecisionKept, Reason: ReasonPathExists},
+		{Category: "additionalDirectories", Entry: "$DATA/dir", Decision: DecisionKept, Reason: ReasonPathExists},
+		{Category: "additionalDirectories", Entry: "/abs/gone", Decision: DecisionSwept, Reason: ReasonPathMissing},
+	}
+	if !slices.Equal(result.Records, want) {
+		t.Errorf("Records mismatch:\ngot:  %+v\nwant: %+v", result.Records, want)
+	}
+}
+
+func TestSweepGlobs(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "src", "legacy"), 0o755)
+	os.WriteFile(filepath.Join(dir, "src", "legacy", "old.js"), nil, 0o644)
+	os.WriteFile(filepath.Join(dir, "src", "main.go"), nil, 0o644)
+
+	newObj := func() map[string]any {
+		return map[string]any{
+			"permissions": map[string]any{
+				"allow": []any{
+					"Edit(//" + dir + "/gone-repo/**)",
+					"Read(/src/**)",
+					"Read(/src/legacy/**/*.ts)",
+					"Read(*.go)",
+					"Read(/src/*.rs)",
+				},
+			},
+		}
+	}
+	reasons := func(r *SweepResult) []SweepReason {
+		var got []SweepReason
+		for _, rec := range r.Records {
+			got = append(got, rec.Reason)
+		}
+		return got
+	}
+
+	t.Run("disabled keeps globs", func(t *testing.T) {
+		t.Parallel()
+		result := mustNewPermissionSweeper(t, OSPathChecker{}, "", nil, WithProjectLevel(dir)).Sweep(t.Context(), newObj())
+		if result.SweptAllow != 0 {
+			t.Errorf("SweptAllow = %d, want 0", result.SweptAllow)
+		}
+	})
+
+	t.Run("missing base directory", func(t *testing.T) {
+		t.Parallel()
+		result := mustNewPermissionSweeper(t, OSPathChecker{}, "", nil,
+			WithProjectLevel(dir),
+			WithGlobConfig(&GlobPermissionConfig{Enabled: true}),
+		).Sweep(t.Context(), newObj())
+		want := []SweepReason{ReasonPathMissing, ReasonPathExists, ReasonPathExists, ReasonPathExists, ReasonPathExists}
+		if got := reasons(result); !slices.Equal(got, want) {
+			t.Errorf("reasons = %v, want %v", got, want)
+		}
+	})
+
+	t.Run("unmatched patterns", func(t *testing.T) {
+		t.Parallel()
+		result := mustNewPermissionSweeper(t, OSPathChecker{}, "", nil,
+			WithProjectLevel(dir),
+			WithGlobConfig(&GlobPermissionConfig{Enabled: true, SweepUnmatched: true}),
+		).Sweep(t.Context(), newObj())
+		want := []SweepReason{ReasonPathMissing, ReasonPathExists, ReasonGlobUnmatched, ReasonPathExists, ReasonGlobUnmatched}
+		if got := reasons(result); !slices.Equal(got, want) {
+			t.Errorf("reasons = %v, want %v", got, want)
+		}
+		if result.SweptAllow != 3 {
+			t.Errorf("SweptAllow = %d, want 3", result.SweptAllow)
+		}
+	})
+
+	t.Run("walk budget keeps entry", func(t *testing.T) {
+		t.Parallel()
+		obj := map[string]any{
+			"permissions": map[string]any{
+				"allow": []any{"Read(**/*.rs)"},
+			},
+		}
+		result := mustNewPermissionSweeper(t, OSPathChecker{}, "", nil,
+			WithProjectLevel(dir),
+			WithGlobConfig(&GlobPermissionConfig{Enabled: true, SweepUnmatched: true, WalkBudget: 2}),
+		).Sweep(t.Context(), obj)
+		if got := reasons(result); !slices.Equal(got, []SweepReason{ReasonWalkBudget}) {
+			t.Errorf("reasons = %v, want [%s]", got, ReasonWalkBudget)
+		}
+		if result.Records[0].Decision != DecisionWarned {
+			t.Errorf("decision = %s, want %s", result.Records[0].Decision, DecisionWarned)
+		}
+	})
+
+	t.Run("unmounted base directory is not walked", func(t *testing.T) {
+		t.Parallel()
+		mountPoint := filepath.Join(dir, "mnt")
+		os.Mkdir(mountPoint, 0o755)
+		obj := map[string]any{
+			"permissions": map[string]any{
+				"allow": []any{"Read(/mnt/**/*.rs)"},
+			},
+		}
+		mounts := NewMountAwareChecker(OSPathChecker{}, nil, MountTable{Expected: []string{mountPoint}})
+		result := mustNewPermissionSweeper(t, OSPathChecker{}, "", nil,
+			WithProjectLevel(dir),
+			WithGlobConfig(&GlobPermissionConfig{Enabled: true, SweepUnmatched: true}),
+			WithMountChecker(mounts),
+		).Sweep(t.Context(), obj)
+		if got := reasons(result); !slices.Equal(got, []SweepReason{ReasonMountUnavailable}) {
+			t.Errorf("reasons = %v, want [%s]", got, ReasonMountUnavailable)
+		}
+	})
+}
+
+func TestSweepScopes(t *testing.T) {
+	t.Parallel()
+	home := t.TempDir()
+	project := t.TempDir()
+	writeAgent := func(dir, name string) {
+		agentsDir := filepath.Join(dir, "agents")
+		os.MkdirAll(agentsDir, 0o755)
+		os.WriteFile(filepath.Join(agentsDir, name+".md"), []byte("---\nname: "+name+"\n---\n"), 0o644)
+	}
+	writeAgent(filepath.Join(project, ".claude"), "shared")
+	writeAgent(filepath.Join(home, ".claude"), "shared")
+	writeAgent(filepath.Join(home, ".claude"), "personal")
+	skillDir := filepath.Join(home, ".claude", "skills", "deploy")
+	os.MkdirAll(skillDir, 0o755)
+	os.WriteFile(filepath.Join(skillDir, "SKILL.md"), []byte("# Deploy"), 0o644)
+
+	obj := map[string]any{
+		"permissions": map[string]any{
+			"allow": []any{
+				"Task(shared)",
+				"Task(personal)",
+				"Task(tools:reviewer)",
+				"Task(gone)",
+				"Task(old:agent)",
+				"Skill(deploy)",
+				"mcp__plugin_tools_github__search",
+			},
+		},
+	}
+	plugins := []InstalledPlugin{{
+		Name:       "tools",
+		Agents:     set.New("reviewer"),
+		Skills:     set.New[string](),
+		MCPServers: set.New("github"),
+	}}
+	result := mustNewPermissionSweeper(t, testutil.NoPathsExist{}, home, nil,
+		WithProjectLevel(project),
+		WithPlugins(plugins),
+	).Sweep(t.Context(), obj)
+
+	want := map[string]Scope{
+		"Task(shared)":         ScopeProject,
+		"Task(personal)":       ScopeUser,
+		"Task(tools:reviewer)": ScopePlugin,
+		"Task(gone)":           "",
+		"Task(old:agent)":      "",
+		"Skill(deploy)":        ScopeUser,
+
+		"mcp__plugin_tools_github__search": ScopePlugin,
+	}
+	for _, rec := range result.Records {
+		if rec.Scope != want[rec.Entry] {
+			t.Errorf("%s: scope = %q, want %q", rec.Entry, rec.Scope, want[rec.Entry])
+		}
+	}
+	if result.SweptAllow != 2 {
+		t.Errorf("SweptAllow = %d, want 2", result.SweptAllow)
+	}
+}
+
+func TestSweepAdditionalDirectories(t *testing.T) {
+	t.Parallel()
+	home := t.TempDir()
+	project := t.TempDir()
+	os.MkdirAll(filepath.Join(home, "notes"), 0o755)
+	os.MkdirAll(filepath.Join(project, "docs"), 0o755)
+	outside := t.TempDir()
+	file := filepath.Join(outside, "file")
+	os.WriteFile(file, nil, 0o644)
+
+	obj := map[string]any{
+		"permissions": map[string]any{
+			"additionalDirectories": []any{
+				outside,
+				filepath.Join(outside, "gone"),
+				file,
+				"/" + filepath.Join(outside, "gone-too"),
+				"~/notes",
+				"~/old-notes",
+				"./docs",
+				"../" + filepath.Base(project) + "/missing",
+			},
+		},
+	}
+	result := mustNewPermissionSweeper(t, OSPathChecker{}, home, nil, WithProjectLevel(project)).Sweep(t.Context(), obj)
+
+	dirs := obj["permissions"].(map[string]any)["additionalDirectories"].([]any)
+	want := []any{outside, "~/notes", "./docs"}
+	if !slices.Equal(dirs, want) {
+		t.Errorf("additionalDirectories = %v, want %v", dirs, want)
+	}
+	if result.SweptDirectories != 5 {
+		t.Errorf("SweptDirectories = %d, want 5", result.SweptDirectories)
+	}
+	if result.SweptAllow != 0 {
+		t.Errorf("SweptAllow = %d, want 0", result.SweptAllow)
+	}
+	for _, rec := range result.Records {
+		if rec.Category != "additionalDirectories" || rec.Tool != "" {
+			t.Errorf("unexpected record %+v", rec)
+		}
+	}
+}
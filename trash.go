@@ -0,0 +1,256 @@
+package cctidy
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// TrashItem is a single removed value recorded in the trash journal.
+// Category and Key locate the value in File using the same
+// convention as SweepRecord. Value holds the removed JSON value
+// for categories whose entries are object keys (see
+// IsObjectCategory).
+type TrashItem struct {
+	ID       string          `json:"id"`
+	Time     time.Time       `json:"time"`
+	File     string          `json:"file"`
+	Category string          `json:"category"`
+	Key      string          `json:"key,omitempty"`
+	Entry    string          `json:"entry"`
+	Value    json.RawMessage `json:"value,omitempty"`
+	Reason   SweepReason     `json:"reason"`
+
+	// Restored is set when the journal contains a restore event
+	// for this item. It is never written with the item itself.
+	Restored *time.Time `json:"restored,omitempty"`
+}
+
+// trashRestore is the journal line recorded when an item is restored.
+type trashRestore struct {
+	Restore string    `json:"restore"`
+	Time    time.Time `json:"time"`
+}
+
+// TrashJournal is an append-only JSON Lines file of removed items
+// and restore events. It is safe for concurrent use within a process.
+type TrashJournal struct {
+	path string
+	mu   sync.Mutex
+}
+
+// DefaultTrashPath returns trash.jsonl in StateDir.
+func DefaultTrashPath(homeDir string) string {
+	return filepath.Join(StateDir(homeDir), "trash.jsonl")
+}
+
+// NewTrashJournal returns a journal stored at path.
+// The file is created on the first append.
+func NewTrashJournal(path string) *TrashJournal {
+	return &TrashJournal{path: path}
+}
+
+// NewTrashID returns a random identifier for a trash item.
+func NewTrashID() string {
+	var b [6]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// Append writes items to the journal.
+func (j *TrashJournal) Append(items []TrashItem) error {
+	lines := make([]any, len(items))
+	for i, it := range items {
+		it.Restored = nil
+		lines[i] = it
+	}
+	return j.append(lines)
+}
+
+// MarkRestored records that the items with the given IDs were
+// restored at t.
+func (j *TrashJournal) MarkRestored(ids []string, t time.Time) error {
+	lines := make([]any, len(ids))
+	for i, id := range ids {
+		lines[i] = trashRestore{Restore: id, Time: t}
+	}
+	return j.append(lines)
+}
+
+func (j *TrashJournal) append(lines []any) error {
+	if len(lines) == 0 {
+		return nil
+	}
+	var buf []byte
+	for _, l := range lines {
+		data, err := json.Marshal(l)
+		if err != nil {
+			return fmt.Errorf("encoding trash item: %w", err)
+		}
+		buf = append(buf, data...)
+		buf = append(buf, '\n')
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(j.path), 0o700); err != nil {
+		return fmt.Errorf("creating state directory: %w", err)
+	}
+	f, err := os.OpenFile(j.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return fmt.Errorf("opening trash journal: %w", err)
+	}
+	if _, err := f.Write(buf); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("writing trash journal: %w", err)
+	}
+	return f.Close()
+}
+
+// Load returns all items in journal order with Restored set from
+// restore events. A missing journal yields no items. Malformed
+// lines (e.g. a truncated final line) are skipped.
+func (j *TrashJournal) Load() ([]TrashItem, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	f, err := os.Open(j.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("reading trash journal: %w", err)
+	}
+	defer f.Close()
+
+	var items []TrashItem
+	index := map[string]int{}
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for sc.Scan() {
+		var line struct {
+			TrashItem
+			Restore string `json:"restore"`
+		}
+		if err := json.Unmarshal(sc.Bytes(), &line); err != nil {
+			continue
+		}
+		if line.Restore != "" {
+			if i, ok := index[line.Restore]; ok {
+				t := line.Time
+				items[i].Restored = &t
+			}
+			continue
+		}
+		if line.ID == "" {
+			continue
+		}
+		line.TrashItem.Restored = nil
+		index[line.ID] = len(items)
+		items = append(items, line.TrashItem)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("reading trash journal: %w", err)
+	}
+	return items, nil
+}
+
+// RestoreTrash inserts items back into data, a JSON object, and
+// returns the re-encoded document. Items already present are left
+// alone. Restored array entries are appended; callers run the
+// file's formatter, without sweeping, to put them in order.
+func RestoreTrash(data []byte, items []TrashItem) ([]byte, error) {
+	obj, err := decodeJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	for _, it := range items {
+		if err := restoreItem(obj, it); err != nil {
+			return nil, fmt.Errorf("restoring %s: %w", it.ID, err)
+		}
+	}
+	return encodeJSON(obj)
+}
+
+// IsObjectCategory reports whether entries of category are keys of
+// a JSON object, whose values are kept in TrashItem.Value, rather
+// than array elements.
+func IsObjectCategory(category string) bool {
+	switch category {
+	case "projects", "enabledPlugins", "extraKnownMarketplaces":
+		return true
+	}
+	return false
+}
+
+func restoreItem(obj map[string]any, it TrashItem) error {
+	switch it.Category {
+	case "allow", "ask", "additionalDirectories":
+		perms, err := childObject(obj, "permissions")
+		if err != nil {
+			return err
+		}
+		perms[it.Category] = appendUnique(perms[it.Category], it.Entry)
+	case "projects", "enabledPlugins", "extraKnownMarketplaces":
+		parent, err := childObject(obj, it.Category)
+		if err != nil {
+			return err
+		}
+		if _, ok := parent[it.Entry]; ok {
+			return nil
+		}
+		var v any = map[string]any{}
+		if it.Category == "enabledPlugins" {
+			v = true
+		}
+		if len(it.Value) > 0 {
+			dec := json.NewDecoder(bytes.NewReader(it.Value))
+			dec.UseNumber()
+			if err := dec.Decode(&v); err != nil {
+				return fmt.Errorf("decoding %s value: %w", it.Category, err)
+			}
+		}
+		parent[it.Entry] = v
+	case "githubRepoPaths":
+		repos, err := childObject(obj, "githubRepoPaths")
+		if err != nil {
+			return err
+		}
+		repos[it.Key] = appendUnique(repos[it.Key], it.Entry)
+	default:
+		return fmt.Errorf("unsupported category %q", it.Category)
+	}
+	return nil
+}
+
+// childObject returns obj[key] as an object, creating it if absent.
+func childObject(obj map[string]any, key string) (map[string]any, error) {
+	raw, ok := obj[key]
+	if !ok {
+		m := map[string]any{}
+		obj[key] = m
+		return m, nil
+	}
+	m, ok := raw.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%s is not an object", key)
+	}
+	return m, nil
+}
+
+// appendUnique appends s to raw (expected to be a JSON array)
+// unless it is already present.
+func appendUnique(raw any, s string) []any {
+	arr, _ := raw.([]any)
+	if slices.Contains(arr, any(s)) {
+		return arr
+	}
+	return append(arr, s)
+}
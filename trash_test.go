@@ -0,0 +1,163 @@
+package cctidy
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTrashJournal(t *testing.T) {
+	t.Parallel()
+
+	t.Run("missing journal loads empty", func(t *testing.T) {
+		t.Parallel()
+		j := NewTrashJournal(filepath.Join(t.TempDir(), "trash.jsonl"))
+		items, err := j.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(items) != 0 {
+			t.Errorf("items = %+v, want none", items)
+		}
+	})
+
+	t.Run("restore events fold into items", func(t *testing.T) {
+		t.Parallel()
+		path := filepath.Join(t.TempDir(), "state", "trash.jsonl")
+		j := NewTrashJournal(path)
+		removed := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+		items := []TrashItem{
+			{ID: "a", Time: removed, File: "/s.json", Category: "allow", Entry: "Read(/gone)", Reason: ReasonPathMissing},
+			{ID: "b", Time: removed, File: "/s.json", Category: "ask", Entry: "Edit(/gone)", Reason: ReasonPathMissing},
+		}
+		if err := j.Append(items); err != nil {
+			t.Fatal(err)
+		}
+		restored := removed.Add(time.Hour)
+		if err := j.MarkRestored([]string{"b"}, restored); err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := j.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 2 {
+			t.Fatalf("len = %d, want 2", len(got))
+		}
+		if got[0].Restored != nil {
+			t.Errorf("item a restored = %v, want nil", got[0].Restored)
+		}
+		if got[1].Restored == nil || !got[1].Restored.Equal(restored) {
+			t.Errorf("item b restored = %v, want %v", got[1].Restored, restored)
+		}
+	})
+
+	t.Run("malformed lines are skipped", func(t *testing.T) {
+		t.Parallel()
+		path := filepath.Join(t.TempDir(), "trash.jsonl")
+		j := NewTrashJournal(path)
+		if err := j.Append([]TrashItem{{ID: "a", Category: "allow", Entry: "Write"}}); err != nil {
+			t.Fatal(err)
+		}
+		f, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+		f.WriteString(`{"id":"trunc`)
+		f.Close()
+
+		got, err := j.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != 1 || got[0].ID != "a" {
+			t.Errorf("items = %+v, want only a", got)
+		}
+	})
+}
+
+func TestRestoreTrash(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		input string
+		items []TrashItem
+		want  string
+	}{
+		{
+			name:  "permission entry appended",
+			input: `{"permissions":{"allow":["Write","Bash"]}}`,
+			items: []TrashItem{{Category: "allow", Entry: "Read(/a)"}},
+			want:  "{\n  \"permissions\": {\n    \"allow\": [\n      \"Write\",\n      \"Bash\",\n      \"Read(/a)\"\n    ]\n  }\n}\n",
+		},
+		{
+			name:  "missing permissions object is created",
+			input: `{}`,
+			items: []TrashItem{{Category: "ask", Entry: "Edit(/a)"}},
+			want:  "{\n  \"permissions\": {\n    \"ask\": [\n      \"Edit(/a)\"\n    ]\n  }\n}\n",
+		},
+		{
+			name:  "additional directory restored",
+			input: `{"permissions":{"additionalDirectories":["/a"]}}`,
+			items: []TrashItem{{Category: "additionalDirectories", Entry: "/b"}},
+			want:  "{\n  \"permissions\": {\n    \"additionalDirectories\": [\n      \"/a\",\n      \"/b\"\n    ]\n  }\n}\n",
+		},
+		{
+			name:  "entry already present is not duplicated",
+			input: `{"permissions":{"allow":["Read(/a)"]}}`,
+			items: []TrashItem{{Category: "allow", Entry: "Read(/a)"}},
+			want:  "{\n  \"permissions\": {\n    \"allow\": [\n      \"Read(/a)\"\n    ]\n  }\n}\n",
+		},
+		{
+			name:  "project restored with its value",
+			input: `{"projects":{}}`,
+			items: []TrashItem{{Category: "projects", Entry: "/p", Value: json.RawMessage(`{"allowedTools":[],"history":1}`)}},
+			want:  "{\n  \"projects\": {\n    \"/p\": {\n      \"allowedTools\": [],\n      \"history\": 1\n    }\n  }\n}\n",
+		},
+		{
+			name:  "existing project is left alone",
+			input: `{"projects":{"/p":{"history":2}}}`,
+			items: []TrashItem{{Category: "projects", Entry: "/p", Value: json.RawMessage(`{"history":1}`)}},
+			want:  "{\n  \"projects\": {\n    \"/p\": {\n      \"history\": 2\n    }\n  }\n}\n",
+		},
+		{
+			name:  "enabled plugin restored with its value",
+			input: `{"enabledPlugins":{"a@m":true}}`,
+			items: []TrashItem{{Category: "enabledPlugins", Entry: "b@m", Value: json.RawMessage(`false`)}},
+			want:  "{\n  \"enabledPlugins\": {\n    \"a@m\": true,\n    \"b@m\": false\n  }\n}\n",
+		},
+		{
+			name:  "marketplace declaration restored",
+			input: `{}`,
+			items: []TrashItem{{Category: "extraKnownMarketplaces", Entry: "team", Value: json.RawMessage(`{"source":{"source":"github","repo":"o/r"}}`)}},
+			want:  "{\n  \"extraKnownMarketplaces\": {\n    \"team\": {\n      \"source\": {\n        \"repo\": \"o/r\",\n        \"source\": \"github\"\n      }\n    }\n  }\n}\n",
+		},
+		{
+			name:  "repo path appended without sorting",
+			input: `{"githubRepoPaths":{"o/r":["/z"]}}`,
+			items: []TrashItem{{Category: "githubRepoPaths", Key: "o/r", Entry: "/a"}},
+			want:  "{\n  \"githubRepoPaths\": {\n    \"o/r\": [\n      \"/z\",\n      \"/a\"\n    ]\n  }\n}\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := RestoreTrash([]byte(tt.input), tt.items)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("unsupported category", func(t *testing.T) {
+		t.Parallel()
+		_, err := RestoreTrash([]byte(`{}`), []TrashItem{{ID: "x", Category: "deny", Entry: "Write"}})
+		if err == nil {
+			t.Error("expected error")
+		}
+	})
+}
@@ -0,0 +1,106 @@
+package cctidy
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrUnknownVar is returned when a specifier references a variable
+// that cannot be expanded: it is not in the allowlist, it is unset,
+// or the reference uses a form other than $NAME or ${NAME}.
+// Callers keep such entries and report them.
+var ErrUnknownVar = errors.New("unknown variable")
+
+// DefaultExpandVars lists the environment variables expanded in
+// path specifiers when [paths] expand_vars is not configured.
+var DefaultExpandVars = []string{
+	"XDG_CONFIG_HOME",
+	"XDG_DATA_HOME",
+	"XDG_STATE_HOME",
+	"XDG_CACHE_HOME",
+}
+
+// pathVars maps variable names to the values substituted when
+// resolving path specifiers.
+type pathVars map[string]string
+
+// newPathVars builds the variables available to path resolution.
+// Allowlisted names are read with lookup; unset or empty variables
+// are left out. HOME is always homeDir. At project level, PWD and
+// CLAUDE_PROJECT_DIR are the project root, since that is the working
+// directory Claude Code runs commands in. A nil lookup reads no
+// environment variables.
+func newPathVars(homeDir, projectDir string, level SettingsLevel, names []string, lookup func(string) (string, bool)) pathVars {
+	vars := pathVars{}
+	if lookup != nil {
+		for _, name := range names {
+			if v, ok := lookup(name); ok && v != "" {
+				vars[name] = v
+			}
+		}
+	}
+	if homeDir != "" {
+		vars["HOME"] = homeDir
+	}
+	if level == ProjectLevel && projectDir != "" {
+		vars["PWD"] = projectDir
+		vars["CLAUDE_PROJECT_DIR"] = projectDir
+	}
+	return vars
+}
+
+// expand replaces $NAME and ${NAME} references in s. A $ not
+// followed by a name or { is kept literally. Unknown names and
+// other ${...} forms return an error wrapping ErrUnknownVar.
+func (v pathVars) expand(s string) (string, error) {
+	if !strings.Contains(s, "$") {
+		return s, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' {
+			b.WriteByte(s[i])
+			continue
+		}
+		rest := s[i+1:]
+		var name string
+		if strings.HasPrefix(rest, "{") {
+			end := strings.IndexByte(rest, '}')
+			if end < 0 || varNameLen(rest[1:end]) != end-1 {
+				return "", fmt.Errorf("%w: %s", ErrUnknownVar, s[i:])
+			}
+			name = rest[1:end]
+			i += end + 1
+		} else {
+			n := varNameLen(rest)
+			if n == 0 {
+				b.WriteByte('$')
+				continue
+			}
+			name = rest[:n]
+			i += n
+		}
+		val, ok := v[name]
+		if !ok {
+			return "", fmt.Errorf("%w: $%s", ErrUnknownVar, name)
+		}
+		b.WriteString(val)
+	}
+	return b.String(), nil
+}
+
+// varNameLen returns the length of the shell variable name at the
+// start of s, or 0 when s does not start with one.
+func varNameLen(s string) int {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '_', 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z':
+		case '0' <= c && c <= '9' && i > 0:
+		default:
+			return i
+		}
+	}
+	return len(s)
+}
@@ -0,0 +1,81 @@
+package cctidy
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPathVarsExpand(t *testing.T) {
+	t.Parallel()
+	vars := pathVars{"HOME": "/home/user", "XDG_CONFIG_HOME": "/home/user/.config"}
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{name: "no variables", input: "/etc/hosts", want: "/etc/hosts"},
+		{name: "short form", input: "$HOME/.zshrc", want: "/home/user/.zshrc"},
+		{name: "braced form", input: "${XDG_CONFIG_HOME}/git", want: "/home/user/.config/git"},
+		{name: "braced form joined to text", input: "${HOME}x", want: "/home/userx"},
+		{name: "lone dollar kept", input: "/tmp/$/x", want: "/tmp/$/x"},
+		{name: "positional parameter kept", input: "/tmp/$1", want: "/tmp/$1"},
+		{name: "unknown variable", input: "$SECRET/key", wantErr: true},
+		{name: "default value form", input: "${HOME:-/root}/x", wantErr: true},
+		{name: "unterminated brace", input: "${HOME/x", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := vars.expand(tt.input)
+			if tt.wantErr {
+				if !errors.Is(err, ErrUnknownVar) {
+					t.Errorf("expand(%q) error = %v, want ErrUnknownVar", tt.input, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expand(%q) unexpected error: %v", tt.input, err)
+			}
+			if got != tt.want {
+				t.Errorf("expand(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewPathVars(t *testing.T) {
+	t.Parallel()
+	env := map[string]string{"XDG_CONFIG_HOME": "/xdg", "HOME": "/env/home", "EMPTY": "", "OTHER": "/other"}
+	lookup := func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	}
+
+	t.Run("user level", func(t *testing.T) {
+		t.Parallel()
+		vars := newPathVars("/home/user", "", UserLevel, []string{"XDG_CONFIG_HOME", "HOME", "EMPTY"}, lookup)
+		want := pathVars{"XDG_CONFIG_HOME": "/xdg", "HOME": "/home/user"}
+		if len(vars) != len(want) {
+			t.Fatalf("got %v, want %v", vars, want)
+		}
+		for k, v := range want {
+			if vars[k] != v {
+				t.Errorf("vars[%q] = %q, want %q", k, vars[k], v)
+			}
+		}
+	})
+
+	t.Run("project level adds project root", func(t *testing.T) {
+		t.Parallel()
+		vars := newPathVars("/home/user", "/project", ProjectLevel, nil, lookup)
+		for _, name := range []string{"PWD", "CLAUDE_PROJECT_DIR"} {
+			if vars[name] != "/project" {
+				t.Errorf("vars[%q] = %q, want /project", name, vars[name])
+			}
+		}
+		if _, ok := vars["OTHER"]; ok {
+			t.Error("non-allowlisted variable should not be read")
+		}
+	})
+}